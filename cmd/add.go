@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add [title]",
+	Short: "Quickly add a task to the current workspace and list (see todo use)",
+	Long: `Add a task to the current context, or to --workspace/--project. Same as "todo task create",
+including quick-add markup: todo add "Call the bank @phone !high ^fri"
+Several words are joined, so quotes are only needed for markup the shell would interpret (like #list).`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTaskCreate(cmd, []string{strings.Join(args, " ")})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+	addCmd.Flags().StringVarP(&taskWorkspace, "workspace", "w", "", "Workspace name (default: current context)")
+	addCmd.Flags().StringVarP(&taskProject, "project", "p", "", "Project/list name (default: the context's list)")
+	addCmd.Flags().StringVarP(&description, "description", "d", "", "Task description")
+	addCmd.Flags().StringVar(&priority, "priority", "", "Priority: low, medium, high")
+	addCmd.Flags().StringVar(&dueDate, "due", "", "Due date (YYYY-MM-DD)")
+	addCmd.Flags().StringVar(&estimate, "estimate", "", "Estimate: duration (e.g. 1h30m) or points (e.g. 3pt)")
+	addCmd.Flags().StringSliceVar(&tags, "tag", nil, "Tag (repeat or comma-separate for several)")
+	addCmd.Flags().BoolVar(&literalTitle, "literal", false, "Use the title as is, without quick-add markup")
+}
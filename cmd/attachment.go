@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	attachStore string
+	attachName  string
+)
+
+var taskAttachCmd = &cobra.Command{
+	Use:   "attach [id] [url-or-path]",
+	Short: "Attach a URL or a file to a task",
+	Long: `Attach a URL or a local file to a task. Files are referenced where they are unless --store is set:
+--store db copies the file into the database (up to 1 MB), --store dir copies it into the
+attachments directory next to the database (up to 50 MB).`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		if attachStore != "" && isURL(args[1]) {
+			return fmt.Errorf("only files can be stored; attach URLs without --store")
+		}
+		kind := store.AttachPath
+		switch attachStore {
+		case "":
+			if isURL(args[1]) {
+				kind = store.AttachURL
+			}
+		case "db":
+			kind = store.AttachBlob
+		case "dir":
+			kind = store.AttachFile
+		default:
+			return fmt.Errorf("--store %q: use db or dir", attachStore)
+		}
+		a, err := store.AddAttachment(ctx, db, id, kind, args[1], attachName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Attached %s to task %d (attachment %d)\n", a.Name, id, a.ID)
+		return nil
+	},
+}
+
+var taskDetachCmd = &cobra.Command{
+	Use:   "detach [attachment-id]",
+	Short: "Remove an attachment (copies are deleted, referenced files are kept)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var id int64
+		if _, err := fmt.Sscanf(args[0], "%d", &id); err != nil {
+			return fmt.Errorf("attachment id must be a number")
+		}
+		if err := store.DeleteAttachment(ctx, db, id); err != nil {
+			return err
+		}
+		fmt.Printf("Removed attachment %d\n", id)
+		return nil
+	},
+}
+
+var taskAttachmentsCmd = &cobra.Command{
+	Use:   "attachments [id]",
+	Short: "List the attachments of a task",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		list, err := store.ListAttachments(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No attachments.")
+			return nil
+		}
+		for _, a := range list {
+			line := fmt.Sprintf("  %d  [%s]  %s", a.ID, a.Kind, a.Name)
+			switch a.Kind {
+			case store.AttachURL, store.AttachPath:
+				if a.Target != a.Name {
+					line += "  " + a.Target
+				}
+			default:
+				line += "  (" + store.FormatBytes(a.Size) + ")"
+			}
+			fmt.Println(line)
+		}
+		return nil
+	},
+}
+
+func isURL(s string) bool {
+	return strings.Contains(s, "://") || strings.HasPrefix(s, "mailto:")
+}
+
+func init() {
+	taskAttachCmd.Flags().StringVar(&attachStore, "store", "", "Copy the file: db (in the database) or dir (next to the database)")
+	taskAttachCmd.Flags().StringVar(&attachName, "name", "", "Display name (default: file name or URL)")
+	taskCmd.AddCommand(taskAttachCmd, taskDetachCmd, taskAttachmentsCmd)
+}
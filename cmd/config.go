@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cli-todo/internal/config"
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change settings of the config file",
+	Long: `Settings come from, in order: command-line flags, environment variables (TODO_ and the setting
+in upper case, e.g. TODO_WEEK_START or TODO_KEYS_ADD), the config file, then the defaults.
+The config file is cli-todo/config.yaml in the user config directory ($XDG_CONFIG_HOME on Linux),
+or $TODO_CONFIG.`,
+	// The config commands don't need the database, so a broken db setting can still be fixed.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		var err error
+		cfg, err = config.Load()
+		return err
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the config file location",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println(cfg.Path)
+		return nil
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every setting with its value and where it comes from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, k := range config.Keys {
+			value, source := cfg.Lookup(k.Name)
+			fmt.Printf("  %-14s %-24s (%s)\n", k.Name, configValue(k.Name, value), configSource(k, source))
+		}
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, ok := config.LookupKey(args[0])
+		if !ok {
+			return fmt.Errorf("unknown setting %q (see todo config list)", args[0])
+		}
+		value, _ := cfg.Lookup(k.Name)
+		fmt.Println(configValue(k.Name, value))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: `Save a setting in the config file ("" removes it)`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := cfg.Set(args[0], args[1]); err != nil {
+			return err
+		}
+		k, _ := config.LookupKey(args[0])
+		value, source := cfg.Lookup(k.Name)
+		if source == config.SourceEnv {
+			fmt.Printf("Saved %s, but %s overrides it: %s\n", k.Name, k.Env(), value)
+			return nil
+		}
+		fmt.Printf("%s = %s\n", k.Name, configValue(k.Name, value))
+		return nil
+	},
+}
+
+// configValue shows the default database path instead of an empty db setting.
+func configValue(name, value string) string {
+	if name == "db" && value == "" {
+		if path, err := store.DBPath(); err == nil {
+			return path
+		}
+	}
+	if name == "db" {
+		return config.ExpandHome(value)
+	}
+	return value
+}
+
+func configSource(k config.Key, source config.Source) string {
+	if source == config.SourceEnv {
+		return k.Env()
+	}
+	return string(source)
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configPathCmd, configListCmd, configGetCmd, configSetCmd)
+	var keys []string
+	for _, k := range config.Keys {
+		if !strings.HasPrefix(k.Name, "keys.") {
+			keys = append(keys, fmt.Sprintf("  %-12s %s", k.Name, k.Help))
+		}
+	}
+	keys = append(keys, fmt.Sprintf("  %-12s %s", "keys.ACTION", "TUI key of an action: "+strings.Join(configActions(), ", ")))
+	configCmd.Long += "\n\nSettings:\n" + strings.Join(keys, "\n")
+}
+
+func configActions() []string {
+	var actions []string
+	for _, k := range config.Keys {
+		if a, ok := strings.CutPrefix(k.Name, "keys."); ok {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/cli-todo/internal/config"
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Back up, restore or encrypt the database",
+	Long: `Back up the database and restore snapshots of it. Snapshots are kept in <db name>.snapshots next
+to the database; besides those of todo db backup, one is taken before a migration, an import, a
+workspace delete, a restore or todo doctor --fix, keeping the newest 10 (the snapshots config key):
+  todo db backup                # a snapshot that is never rotated away
+  todo db backup --to ~/todo-2026-10-19.db
+  todo db snapshots
+  todo db restore 20261019-1530 # a snapshot's name, a unique prefix of it, or a path
+
+Encrypt the database file with a passphrase (AES-256-GCM, with the key derived by PBKDF2-SHA256).
+todo then asks for the passphrase when it starts, and keeps the data decrypted in memory only.
+Snapshots taken before are encrypted along with it:
+  todo db encrypt
+  todo db rekey                 # change the passphrase
+  todo db decrypt
+Scripts set TODO_PASSPHRASE, or TODO_KEY from todo db unlock, which also skips the slow key
+derivation on every run:
+  eval "$(todo db unlock)"
+Encrypt and rekey read the new passphrase from stdin when it isn't a terminal. Attachments kept
+as files next to the database are not encrypted; attach them as blobs instead.`,
+}
+
+var dbEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the database with a new passphrase",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := store.DatabaseFile(ctx, db)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(path); path == "" || err != nil || info.IsDir() {
+			return errors.New("only a database file can be encrypted")
+		}
+		if encrypted, err := store.IsEncrypted(path); err != nil {
+			return err
+		} else if encrypted {
+			return fmt.Errorf("%s is already encrypted (change the passphrase with: todo db rekey)", path)
+		}
+		passphrase, err := newPassphrase()
+		if err != nil {
+			return err
+		}
+		// Closed first, so the WAL is moved into the file and nothing writes to it afterwards.
+		err = store.Close(db)
+		db = nil
+		if err != nil {
+			return err
+		}
+		snapshots, err := store.EncryptFile(path, passphrase)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Encrypted %s\n", path)
+		if snapshots > 0 {
+			fmt.Printf("Encrypted %d snapshot(s) with the same passphrase\n", snapshots)
+		}
+		return nil
+	},
+}
+
+var dbDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt the database, storing it in plain text again",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := store.DatabaseFile(ctx, db)
+		if err != nil {
+			return err
+		}
+		if err := store.Rekey(ctx, db, ""); err != nil {
+			return err
+		}
+		fmt.Printf("Decrypted %s\n", path)
+		return nil
+	},
+}
+
+var dbRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Change the passphrase of the encrypted database",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := store.DatabaseFile(ctx, db)
+		if err != nil {
+			return err
+		}
+		if _, err := store.CachedKey(db); err != nil {
+			return err
+		}
+		passphrase, err := newPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := store.Rekey(ctx, db, passphrase); err != nil {
+			return err
+		}
+		fmt.Printf("Changed the passphrase of %s\n", path)
+		return nil
+	},
+}
+
+var dbUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Print a shell command caching the database's key in TODO_KEY",
+	Long: `Print a shell command that sets TODO_KEY to the key of the encrypted database, so later commands
+in this shell don't ask for the passphrase:
+  eval "$(todo db unlock)"
+Anyone who can read the variable can decrypt the database; it stops working after todo db rekey.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := store.CachedKey(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("export %s=%s\n", store.KeyEnv, key)
+		return nil
+	},
+}
+
+var dbBackupTo string
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Copy the database to --to, or to a snapshot that is kept until removed",
+	Long: `Copy the database while it is in use (VACUUM INTO). Without --to the copy is a snapshot, listed
+by todo db snapshots and never rotated away. The copy of an encrypted database is encrypted too.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := config.ExpandHome(dbBackupTo)
+		var err error
+		if path == "" {
+			path, err = store.TakeSnapshot(ctx, db)
+		} else {
+			err = store.Backup(ctx, db, path)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backed up to %s\n", path)
+		return nil
+	},
+}
+
+var dbSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "List the database's snapshots, newest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		snaps, err := store.Snapshots(ctx, db)
+		if err != nil {
+			return err
+		}
+		if len(snaps) == 0 {
+			fmt.Println("No snapshots. Take one with: todo db backup")
+			return nil
+		}
+		dir, _ := store.SnapshotDir(ctx, db)
+		fmt.Printf("In %s:\n", dir)
+		width := 0
+		for _, s := range snaps {
+			width = max(width, len(s.Name))
+		}
+		for _, s := range snaps {
+			suffix := ""
+			if s.Encrypted {
+				suffix = "  encrypted"
+			}
+			fmt.Printf("  %-*s  %s  %8s%s\n", width, s.Name, s.Taken.Format(dateLayout+" 15:04:05"), store.FormatBytes(s.Size), suffix)
+		}
+		return nil
+	},
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore SNAPSHOT",
+	Short: "Replace the database's content with a snapshot or backup, after checking it is intact",
+	Long: `Replace the database's content with a snapshot (its name as listed by todo db snapshots, or a
+unique prefix of it) or a backup file. The file must pass SQLite's integrity check first. The
+current content is snapshotted before it is replaced, so a restore can be undone the same way.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := store.FindSnapshot(ctx, db, config.ExpandHome(args[0]))
+		if err != nil {
+			return err
+		}
+		if err := store.Restore(ctx, db, path); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbBackupCmd, dbSnapshotsCmd, dbRestoreCmd, dbEncryptCmd, dbDecryptCmd, dbRekeyCmd, dbUnlockCmd)
+	dbBackupCmd.Flags().StringVar(&dbBackupTo, "to", "", "File to write the copy to (must not exist)")
+}
+
+// promptPassphrase asks for the passphrase of the encrypted database at path on the terminal.
+func promptPassphrase(path string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("%s is encrypted: set %s or %s", path, store.PassphraseEnv, store.KeyEnv)
+	}
+	return readPassphrase("Passphrase for " + path + ": ")
+}
+
+// newPassphrase asks for a new passphrase twice on the terminal, or reads it from stdin's first line.
+func newPassphrase() (string, error) {
+	var passphrase string
+	if term.IsTerminal(os.Stdin.Fd()) {
+		var err error
+		if passphrase, err = readPassphrase("New passphrase: "); err != nil {
+			return "", err
+		}
+		again, err := readPassphrase("Repeat it: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("the passphrases don't match")
+		}
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("no passphrase on stdin")
+		}
+		passphrase = strings.TrimRight(line, "\r\n")
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase can't be empty")
+	}
+	return passphrase, nil
+}
+
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the database for corruption, schema drift and inconsistent data",
+	Long: `Check the database: SQLite's integrity and foreign key checks, the schema version and the tables,
+columns, indexes and triggers this version of todo expects, and rules the schema doesn't enforce,
+such as a task's list being in the task's workspace. The database is checked as it is, before todo
+migrates it to this version's schema. Exits with an error if it finds problems.
+
+With --fix, the database is snapshotted, then every problem that can be is fixed: missing columns
+and indexes are added, an old schema is migrated, rows pointing at rows that don't exist are deleted (or their reference
+cleared), tasks in a list of another workspace move to their workspace's default list, unknown
+statuses become the workflow's first. Undo it with todo db restore.`,
+	Args: cobra.NoArgs,
+	// Check the database as it is: migrating it first would hide a stale schema, and a database
+	// whose migration fails couldn't be checked at all. --fix migrates it.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setup(cmd, store.OpenUnmigrated)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		findings, err := store.CheckDatabase(ctx, db)
+		if err != nil {
+			return err
+		}
+		if len(findings) == 0 {
+			fmt.Println("No problems found")
+			return nil
+		}
+		printFindings(findings)
+		if !doctorFix || fixable(findings) == 0 {
+			return doctorResult(findings)
+		}
+		if err := store.SnapshotBefore(ctx, db, "doctor"); err != nil {
+			return err
+		}
+		total := 0
+		// Fixing the schema lets the checks that need it run, which may find more to fix.
+		for pass := 0; pass < 3 && fixable(findings) > 0; pass++ {
+			if pass > 0 {
+				printFindings(findings)
+			}
+			n, err := store.FixDatabase(ctx, db, findings)
+			if err != nil {
+				return err
+			}
+			total += n
+			if findings, err = store.CheckDatabase(ctx, db); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Fixed %d problem(s)\n", total)
+		if len(findings) == 0 {
+			return nil
+		}
+		fmt.Println("Remaining:")
+		printFindings(findings)
+		return doctorResult(findings)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Fix what can be fixed, after taking a snapshot")
+}
+
+func printFindings(findings []store.Finding) {
+	for _, f := range findings {
+		fix := ""
+		if f.Fixable() {
+			fix = " (fix: " + f.Fix + ")"
+		}
+		fmt.Printf("  %-11s  %s%s\n", f.Check, f.Problem, fix)
+	}
+}
+
+func fixable(findings []store.Finding) int {
+	n := 0
+	for _, f := range findings {
+		if f.Fixable() {
+			n++
+		}
+	}
+	return n
+}
+
+// doctorResult is the error todo doctor exits with when problems are left.
+func doctorResult(findings []store.Finding) error {
+	n := fixable(findings)
+	switch {
+	case n > 0 && !doctorFix:
+		return fmt.Errorf("%d problem(s) found, %d fixable with: todo doctor --fix", len(findings), n)
+	case n < len(findings):
+		for _, f := range findings {
+			if f.Check == "integrity" && !f.Fixable() {
+				return fmt.Errorf("%d problem(s) can't be fixed automatically; the database is damaged, restore a snapshot with: todo db restore", len(findings)-n)
+			}
+		}
+		return fmt.Errorf("%d problem(s) can't be fixed automatically", len(findings)-n)
+	}
+	return fmt.Errorf("%d problem(s) left", len(findings))
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportWorkspaces []string
+	exportOutput     string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export workspaces as JSON (for backups or moving tasks between databases)",
+	Long: `Write workspaces with their workflows, custom fields, lists and tasks (tags, field values,
+dependencies and notes) as JSON. Workspaces, lists and tasks carry their UUIDs, so importing the
+file again, or into a database that already has some of it, updates instead of duplicating.
+Time entries and attachments are not exported.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var ids []int64
+		for _, name := range exportWorkspaces {
+			w, err := store.GetWorkspaceByName(ctx, db, name)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, w.ID)
+		}
+		data, err := store.Export(ctx, db, ids)
+		if err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		out = append(out, '\n')
+		if exportOutput == "" || exportOutput == "-" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		if err := os.WriteFile(exportOutput, out, 0o644); err != nil {
+			return err
+		}
+		tasks := 0
+		for _, w := range data.Workspaces {
+			tasks += len(w.Tasks)
+		}
+		fmt.Printf("Exported %d workspaces, %d tasks to %s\n", len(data.Workspaces), tasks, exportOutput)
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import a file written by todo export (\"-\" reads stdin)",
+	Long: `Merge an export into this database. Workspaces and lists are matched by UUID, then by name;
+tasks by UUID. A task that exists already is replaced only when the file's copy was updated later.
+Missing statuses and custom fields are added; notes are added unless the task already has them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var raw []byte
+		var err error
+		if args[0] == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		var data models.Export
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		if err := store.SnapshotBefore(ctx, db, "import"); err != nil {
+			return err
+		}
+		res, err := store.Import(ctx, db, data)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported: %d tasks added, %d updated, %d unchanged; %d new workspaces, %d new lists\n",
+			res.TasksAdded, res.TasksUpdated, res.TasksSkipped, res.Workspaces, res.Projects)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd, importCmd)
+	exportCmd.Flags().StringArrayVarP(&exportWorkspaces, "workspace", "w", nil, "Workspace to export (repeat for several; default: all)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fieldWorkspace string
+	fieldType      string
+	fieldOptions   []string
+	fieldNewName   string
+)
+
+var fieldCmd = &cobra.Command{
+	Use:   "field",
+	Short: "Manage the custom fields of a workspace",
+	Long: `Custom fields are typed metadata on the tasks of one workspace (e.g. ticket and customer for work,
+author for books). Types: text, number, date (YYYY-MM-DD), enum (one of --options) and bool.
+Set values with: todo task create|edit --field name=value`,
+}
+
+var fieldListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List custom fields",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(fieldWorkspace)
+		if err != nil {
+			return err
+		}
+		list, err := store.ListFields(ctx, db, w.ID)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No custom fields.")
+			return nil
+		}
+		for _, f := range list {
+			extra := ""
+			if len(f.Options) > 0 {
+				extra = " (" + strings.Join(f.Options, ", ") + ")"
+			}
+			fmt.Printf("  %s  %s%s\n", f.Name, f.Type, extra)
+		}
+		return nil
+	},
+}
+
+var fieldAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add a custom field",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(fieldWorkspace)
+		if err != nil {
+			return err
+		}
+		f, err := store.CreateField(ctx, db, w.ID, args[0], fieldType, fieldOptions)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added %s field %q to %s\n", f.Type, f.Name, w.Name)
+		return nil
+	},
+}
+
+var fieldEditCmd = &cobra.Command{
+	Use:   "edit [name]",
+	Short: "Rename a custom field or change the options of an enum",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(fieldWorkspace)
+		if err != nil {
+			return err
+		}
+		f, err := store.GetFieldByName(ctx, db, w.ID, args[0])
+		if err != nil {
+			return err
+		}
+		name := f.Name
+		if fieldNewName != "" {
+			name = fieldNewName
+		}
+		var options []string
+		if cmd.Flags().Changed("options") {
+			options = fieldOptions
+		}
+		if _, err := store.UpdateField(ctx, db, f.ID, name, options); err != nil {
+			return err
+		}
+		fmt.Printf("Updated field %q\n", name)
+		return nil
+	},
+}
+
+var fieldDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a custom field and its values on all tasks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(fieldWorkspace)
+		if err != nil {
+			return err
+		}
+		f, err := store.GetFieldByName(ctx, db, w.ID, args[0])
+		if err != nil {
+			return err
+		}
+		if err := store.DeleteField(ctx, db, f.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted field %q\n", f.Name)
+		return nil
+	},
+}
+
+// parseFieldAssignments turns --field name=value flags into a map (empty value = clear).
+func parseFieldAssignments(list []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, s := range list {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("--field %q: use name=value", s)
+		}
+		values[strings.TrimSpace(name)] = value
+	}
+	return values, nil
+}
+
+func init() {
+	rootCmd.AddCommand(fieldCmd)
+	fieldCmd.PersistentFlags().StringVarP(&fieldWorkspace, "workspace", "w", "", "Workspace name (default: current context)")
+
+	fieldAddCmd.Flags().StringVar(&fieldType, "type", "text", "Type: text, number, date, enum, bool")
+	fieldAddCmd.Flags().StringSliceVar(&fieldOptions, "options", nil, "Allowed values of an enum (comma-separated)")
+
+	fieldEditCmd.Flags().StringVar(&fieldNewName, "name", "", "New name")
+	fieldEditCmd.Flags().StringSliceVar(&fieldOptions, "options", nil, "New allowed values of an enum")
+
+	fieldCmd.AddCommand(fieldListCmd, fieldAddCmd, fieldEditCmd, fieldDeleteCmd)
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gitRemote  string
+	gitMessage string
+)
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Share the database through a git repository of text files",
+	Long: `Keep workspaces, lists and tasks as text files in a git repository, one file per item, so a team
+can share them through any git remote. Fields sit on lines of their own, so teammates' edits to
+different fields of the same task merge cleanly; notes and time entries are merged line by line.
+
+  todo git init ~/todo-repo --remote git@example.com:team/todo.git
+  todo git commit -m "Plan the release"
+  todo git pull      # commits local changes, merges the remote's, loads the result
+  todo git push      # commits local changes and pushes
+
+When git can't merge a field both sides changed, pull stops: edit the conflicted files in the
+repository (keep one "field = value" line), commit with git, then run todo git pull again.`,
+}
+
+var gitInitCmd = &cobra.Command{
+	Use:   "init [path]",
+	Short: "Create (or clone with --remote) the repository and write the database to it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+			if gitRemote != "" && isEmptyDir(dir) {
+				if err := runGit("", "clone", gitRemote, dir); err != nil {
+					return err
+				}
+			} else {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					return err
+				}
+				if err := runGit(dir, "init", "--quiet"); err != nil {
+					return err
+				}
+			}
+		}
+		if gitRemote != "" {
+			if _, err := gitOutput(dir, "remote", "get-url", "origin"); err != nil {
+				if err := runGit(dir, "remote", "add", "origin", gitRemote); err != nil {
+					return err
+				}
+			}
+		}
+		if err := store.SetSetting(ctx, db, store.SettingGitRepo, dir); err != nil {
+			return err
+		}
+		// Merge what the repository has already; the database wins where both have an item.
+		changes, err := store.LoadTree(ctx, db, dir, "")
+		if err != nil {
+			return err
+		}
+		if err := saveGitHead(dir, store.TreeClock()); err != nil {
+			return err
+		}
+		if _, err := commitTree(dir, "todo: export database"); err != nil {
+			return err
+		}
+		fmt.Printf("Repository: %s\n", dir)
+		if changes != (store.SyncChanges{}) {
+			fmt.Printf("Loaded from it: %s\n", formatSyncChanges(changes))
+		}
+		return nil
+	},
+}
+
+var gitCommitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "Write the database to the repository and commit",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := gitRepo()
+		if err != nil {
+			return err
+		}
+		committed, err := commitTree(dir, gitMessage)
+		if err != nil {
+			return err
+		}
+		if !committed {
+			fmt.Println("Nothing to commit.")
+		}
+		return nil
+	},
+}
+
+var gitPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Commit local changes, merge the remote's and load the result",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := gitRepo()
+		if err != nil {
+			return err
+		}
+		if _, err := commitTree(dir, "todo: local changes"); err != nil {
+			return err
+		}
+		if _, err := gitOutput(dir, "rev-parse", "--abbrev-ref", "@{upstream}"); err != nil {
+			return fmt.Errorf("the branch has no upstream yet: run todo git push first")
+		}
+		synced, _ := store.GetSetting(ctx, db, store.SettingGitSynced)
+		if err := runGit(dir, "pull", "--no-rebase", "--no-edit"); err != nil {
+			if conflicts, _ := gitOutput(dir, "diff", "--name-only", "--diff-filter=U"); conflicts != "" {
+				return fmt.Errorf("merge conflicts in:\n  %s\nresolve them in %s (keep one \"field = value\" line), commit with git, then run: todo git pull",
+					strings.ReplaceAll(conflicts, "\n", "\n  "), dir)
+			}
+			return err
+		}
+		return loadTree(dir, synced)
+	},
+}
+
+var gitPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Commit local changes and push them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := gitRepo()
+		if err != nil {
+			return err
+		}
+		if _, err := commitTree(dir, "todo: local changes"); err != nil {
+			return err
+		}
+		if _, err := gitOutput(dir, "rev-parse", "--abbrev-ref", "@{upstream}"); err != nil {
+			return runGit(dir, "push", "--quiet", "--set-upstream", "origin", "HEAD")
+		}
+		return runGit(dir, "push", "--quiet")
+	},
+}
+
+// gitRepo returns the repository set by todo git init.
+func gitRepo() (string, error) {
+	dir, err := store.GetSetting(ctx, db, store.SettingGitRepo)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return "", fmt.Errorf("no repository: run todo git init PATH")
+	}
+	return dir, nil
+}
+
+// commitTree writes the database to the repository and commits it, first loading commits made
+// outside todo (e.g. a merge resolved by hand). It reports whether there was anything to commit.
+func commitTree(dir, message string) (bool, error) {
+	if _, err := gitOutput(dir, "rev-parse", "-q", "--verify", "MERGE_HEAD"); err == nil {
+		return false, fmt.Errorf("%s is in the middle of a merge: resolve the conflicts and commit with git first", dir)
+	}
+	head, _ := gitOutput(dir, "rev-parse", "-q", "--verify", "HEAD")
+	saved, err := store.GetSetting(ctx, db, store.SettingGitHead)
+	if err != nil {
+		return false, err
+	}
+	if head != "" && head != saved {
+		synced, err := store.GetSetting(ctx, db, store.SettingGitSynced)
+		if err != nil {
+			return false, err
+		}
+		if err := loadTree(dir, synced); err != nil {
+			return false, err
+		}
+	}
+	synced := store.TreeClock()
+	if err := store.WriteTree(ctx, db, dir); err != nil {
+		return false, err
+	}
+	if err := runGit(dir, "add", "--all"); err != nil {
+		return false, err
+	}
+	committed := false
+	if _, err := gitOutput(dir, "diff", "--cached", "--quiet"); err != nil {
+		if message == "" {
+			message = "todo: update tasks"
+		}
+		if err := runGit(dir, "commit", "--quiet", "-m", message); err != nil {
+			return false, err
+		}
+		committed = true
+	}
+	return committed, saveGitHead(dir, synced)
+}
+
+// loadTree loads the repository's files into the database and reports what changed.
+func loadTree(dir, synced string) error {
+	changes, err := store.LoadTree(ctx, db, dir, synced)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Loaded %s: %s\n", dir, formatSyncChanges(changes))
+	// Edits made here meanwhile were kept; commit them so the tree has everything again.
+	if err := saveGitHead(dir, synced); err != nil {
+		return err
+	}
+	_, err = commitTree(dir, "todo: local changes")
+	return err
+}
+
+func saveGitHead(dir, synced string) error {
+	head, _ := gitOutput(dir, "rev-parse", "-q", "--verify", "HEAD")
+	if err := store.SetSetting(ctx, db, store.SettingGitHead, head); err != nil {
+		return err
+	}
+	return store.SetSetting(ctx, db, store.SettingGitSynced, synced)
+}
+
+// runGit runs git in dir ("" = the working directory), passing its output through.
+func runGit(dir string, args ...string) error {
+	name := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	c := exec.CommandContext(ctx, "git", args...)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("git %s: %w", name, err)
+	}
+	return nil
+}
+
+// gitOutput runs git in dir and returns its trimmed output.
+func gitOutput(dir string, args ...string) (string, error) {
+	var out, stderr bytes.Buffer
+	c := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	c.Stdout, c.Stderr = &out, &stderr
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+func isEmptyDir(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return err != nil || len(entries) == 0
+}
+
+func init() {
+	rootCmd.AddCommand(gitCmd)
+	gitInitCmd.Flags().StringVar(&gitRemote, "remote", "", "Clone this remote (or add it as origin to an existing repository)")
+	gitCommitCmd.Flags().StringVarP(&gitMessage, "message", "m", "", "Commit message")
+	gitCmd.AddCommand(gitInitCmd, gitCommitCmd, gitPullCmd, gitPushCmd)
+}
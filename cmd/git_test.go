@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+)
+
+// runTodo runs todo with args like Execute does, with a context that isn't cancelled afterwards:
+// cobra keeps a subcommand's context from its first run.
+func runTodo(t *testing.T, args ...string) {
+	t.Helper()
+	rootCmd.SetArgs(args)
+	err := rootCmd.ExecuteContext(context.Background())
+	if db != nil {
+		err = errors.Join(err, store.Close(db))
+		db = nil
+	}
+	if err != nil {
+		t.Fatalf("todo %s: %v", strings.Join(args, " "), err)
+	}
+}
+
+// withDB opens the database at path for fn.
+func withDB(t *testing.T, path string, fn func(ctx context.Context, db *sql.DB)) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := store.Open(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close(db)
+	fn(ctx, db)
+}
+
+// editTask opens the database at path and changes the task with fn.
+func editTask(t *testing.T, path, uuid string, fn func(task *models.Task)) {
+	t.Helper()
+	withDB(t, path, func(ctx context.Context, db *sql.DB) {
+		task := readTaskFrom(t, ctx, db, uuid)
+		fn(&task)
+		if _, err := store.UpdateTask(ctx, db, task.ID, task.Title, task.Description, task.Status, task.Priority, task.DueDate); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func readTask(t *testing.T, path, uuid string) (task models.Task) {
+	t.Helper()
+	withDB(t, path, func(ctx context.Context, db *sql.DB) { task = readTaskFrom(t, ctx, db, uuid) })
+	return task
+}
+
+func readTaskFrom(t *testing.T, ctx context.Context, db *sql.DB, uuid string) models.Task {
+	t.Helper()
+	id, err := store.ResolveTaskID(ctx, db, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := store.GetTask(ctx, db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestGitMergesEditsToDifferentFields(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmp := t.TempDir()
+	t.Setenv("TODO_CONFIG", filepath.Join(tmp, "config.yaml"))
+	for _, k := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(k, "todo test")
+	}
+	for _, k := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(k, "todo@example.com")
+	}
+	remote := filepath.Join(tmp, "remote.git")
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	dbA, dbB := filepath.Join(tmp, "a.db"), filepath.Join(tmp, "b.db")
+
+	ctx := context.Background()
+	db, err := store.Open(ctx, dbA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := store.CreateWorkspace(ctx, db, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := store.CreateTask(ctx, db, w.ID, nil, "Plan", "", "", models.PriorityNone, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(db); err != nil {
+		t.Fatal(err)
+	}
+
+	runTodo(t, "--db", dbA, "git", "init", filepath.Join(tmp, "repo-a"), "--remote", remote)
+	runTodo(t, "--db", dbA, "git", "push")
+	runTodo(t, "--db", dbB, "git", "init", filepath.Join(tmp, "repo-b"), "--remote", remote)
+	if got := readTask(t, dbB, task.UUID); got.Title != "Plan" {
+		t.Fatalf("clone: title = %q, want Plan", got.Title)
+	}
+
+	editTask(t, dbA, task.UUID, func(task *models.Task) { task.Title = "Plan the release" })
+	editTask(t, dbB, task.UUID, func(task *models.Task) { task.Priority = models.PriorityHigh })
+	// Workflow and field changes used to break every pull after them.
+	runTodo(t, "--db", dbA, "status", "add", "-w", "Team", "blocked")
+	runTodo(t, "--db", dbB, "field", "add", "-w", "Team", "size", "--type", "enum", "--options", "s,m,l")
+	runTodo(t, "--db", dbA, "git", "push")
+	runTodo(t, "--db", dbB, "git", "pull")
+	runTodo(t, "--db", dbB, "git", "push")
+	runTodo(t, "--db", dbA, "git", "pull")
+
+	for _, path := range []string{dbA, dbB} {
+		got := readTask(t, path, task.UUID)
+		if got.Title != "Plan the release" || got.Priority != models.PriorityHigh {
+			t.Errorf("%s: title %q, priority %q; want both edits", filepath.Base(path), got.Title, got.Priority)
+		}
+		withDB(t, path, func(ctx context.Context, db *sql.DB) {
+			if _, err := store.GetStatusByName(ctx, db, got.WorkspaceID, "blocked"); err != nil {
+				t.Errorf("%s: status blocked: %v", filepath.Base(path), err)
+			}
+			if _, err := store.GetFieldByName(ctx, db, got.WorkspaceID, "size"); err != nil {
+				t.Errorf("%s: field size: %v", filepath.Base(path), err)
+			}
+		})
+	}
+}
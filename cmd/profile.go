@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cli-todo/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var profileDefaultClear bool
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named databases (e.g. personal and team)",
+	Long: `A profile names a database, e.g. a personal one and one shared with the team:
+  todo profile add team /srv/shared/team.db
+  todo --profile team task list
+  todo profile default team      # used when no --profile is given
+Profiles are saved in the config file (see todo config). --db and TODO_DB win over profiles;
+TODO_PROFILE picks one per shell and "profile:" in a .todo.yaml per directory tree.`,
+	// Profiles live in the config file; the database is not needed.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		var err error
+		if cfg, err = config.Load(); err != nil {
+			return err
+		}
+		marker, err = findMarker()
+		return err
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles (* = in use here)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := cfg.ProfileNames()
+		if len(names) == 0 {
+			fmt.Println("No profiles. Add one with: todo profile add NAME PATH")
+			return nil
+		}
+		_, active, _ := resolveDB()
+		defaultProfile := cfg.Saved("profile")
+		profiles := cfg.Profiles()
+		width := 0
+		for _, name := range names {
+			width = max(width, len(name))
+		}
+		for _, name := range names {
+			mark := " "
+			if name == active {
+				mark = "*"
+			}
+			suffix := ""
+			if name == defaultProfile {
+				suffix = "  (default)"
+			}
+			fmt.Printf("%s %-*s  %s%s\n", mark, width, name, profiles[name], suffix)
+		}
+		return nil
+	},
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:   "add NAME PATH",
+	Short: "Add a profile for the database at PATH (created on first use)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := cfg.AddProfile(args[0], args[1]); err != nil {
+			return err
+		}
+		path, _ := cfg.ProfileDB(args[0])
+		fmt.Printf("Added profile %s: %s\n", args[0], path)
+		return nil
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove a profile (the database file is kept)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := cfg.RemoveProfile(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Removed profile %s\n", args[0])
+		return nil
+	},
+}
+
+var profileDefaultCmd = &cobra.Command{
+	Use:   "default [NAME]",
+	Short: "Show or set the profile used when --profile is not given",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if profileDefaultClear {
+			if err := cfg.Set("profile", ""); err != nil {
+				return err
+			}
+			fmt.Println("Cleared the default profile")
+			return nil
+		}
+		if len(args) == 0 {
+			if name := cfg.Saved("profile"); name != "" {
+				fmt.Println(name)
+			} else {
+				fmt.Println("No default profile")
+			}
+			return nil
+		}
+		if err := cfg.Set("profile", args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Default profile: %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd, profileAddCmd, profileRemoveCmd, profileDefaultCmd)
+	profileDefaultCmd.Flags().BoolVar(&profileDefaultClear, "clear", false, "Forget the default profile")
+}
@@ -3,11 +3,15 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/cli-todo/internal/models"
 	"github.com/cli-todo/internal/store"
 	"github.com/spf13/cobra"
 )
 
-var projectWorkspace string
+var (
+	projectWorkspace string
+	projectTemplate  string
+)
 
 var projectCmd = &cobra.Command{
 	Use:   "project",
@@ -19,15 +23,27 @@ var projectCreateCmd = &cobra.Command{
 	Short: "Create a project in a workspace",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		w, err := store.GetWorkspaceByName(db, projectWorkspace)
+		w, err := lookupWorkspace(projectWorkspace)
 		if err != nil {
-			return fmt.Errorf("workspace %q: %w", projectWorkspace, err)
+			return err
 		}
-		p, err := store.CreateProject(db, w.ID, args[0])
+		if projectTemplate != "" {
+			tpl, vars, start, err := templateArgs(projectTemplate)
+			if err != nil {
+				return err
+			}
+			p, err := store.CreateProjectFromTemplate(ctx, db, w.ID, args[0], tpl, vars, start)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Created project %q in %s (id %d) with %d tasks from template %q\n", p.Name, w.Name, p.ID, len(tpl.Tasks), tpl.Name)
+			return nil
+		}
+		p, err := store.CreateProject(ctx, db, w.ID, args[0])
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Created project %q in %s (id %d)\n", p.Name, projectWorkspace, p.ID)
+		fmt.Printf("Created project %q in %s (id %d)\n", p.Name, w.Name, p.ID)
 		return nil
 	},
 }
@@ -36,16 +52,16 @@ var projectListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List projects in a workspace",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		w, err := store.GetWorkspaceByName(db, projectWorkspace)
+		w, err := lookupWorkspace(projectWorkspace)
 		if err != nil {
-			return fmt.Errorf("workspace %q: %w", projectWorkspace, err)
+			return err
 		}
-		list, err := store.ListProjects(db, w.ID)
+		list, err := store.ListProjects(ctx, db, w.ID)
 		if err != nil {
 			return err
 		}
 		if len(list) == 0 {
-			fmt.Printf("No projects in %q. Tasks without a project go to the default list.\n", projectWorkspace)
+			fmt.Printf("No projects in %q. Tasks without a project go to the default list.\n", w.Name)
 			return nil
 		}
 		for _, p := range list {
@@ -60,35 +76,70 @@ var projectDeleteCmd = &cobra.Command{
 	Short: "Delete a project (tasks move to default list)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		w, err := store.GetWorkspaceByName(db, projectWorkspace)
+		w, err := lookupWorkspace(projectWorkspace)
 		if err != nil {
-			return fmt.Errorf("workspace %q: %w", projectWorkspace, err)
+			return err
 		}
-		projects, err := store.ListProjects(db, w.ID)
+		p, err := findProject(w, args[0])
 		if err != nil {
 			return err
 		}
-		var targetID int64 = -1
-		for _, p := range projects {
-			if p.Name == args[0] {
-				targetID = p.ID
-				break
-			}
+		if err := store.DeleteProject(ctx, db, p.ID); err != nil {
+			return err
 		}
-		if targetID < 0 {
-			return fmt.Errorf("project %q not found in workspace %q", args[0], projectWorkspace)
+		fmt.Printf("Deleted project %q\n", args[0])
+		return nil
+	},
+}
+
+var projectCapacityCmd = &cobra.Command{
+	Use:   "capacity",
+	Short: "Show remaining estimates of open tasks per project/list",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(projectWorkspace)
+		if err != nil {
+			return err
 		}
-		if err := store.DeleteProject(db, targetID); err != nil {
+		totals, err := store.EstimateTotals(ctx, db, w.ID)
+		if err != nil {
 			return err
 		}
-		fmt.Printf("Deleted project %q\n", args[0])
+		if len(totals) == 0 {
+			fmt.Printf("No open tasks in %q.\n", w.Name)
+			return nil
+		}
+		var sum models.EstimateTotal
+		for _, t := range totals {
+			fmt.Printf("  %-20s %3d open  %s\n", t.Name, t.OpenTasks, store.FormatCapacity(t.Minutes, t.Points, t.LoggedSeconds))
+			sum.OpenTasks += t.OpenTasks
+			sum.Minutes += t.Minutes
+			sum.Points += t.Points
+			sum.LoggedSeconds += t.LoggedSeconds
+		}
+		fmt.Printf("  %-20s %3d open  %s\n", "Total", sum.OpenTasks, store.FormatCapacity(sum.Minutes, sum.Points, sum.LoggedSeconds))
 		return nil
 	},
 }
 
+// findProject looks up a project by name within a workspace.
+func findProject(w models.Workspace, name string) (models.Project, error) {
+	projects, err := store.ListProjects(ctx, db, w.ID)
+	if err != nil {
+		return models.Project{}, err
+	}
+	for _, p := range projects {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return models.Project{}, fmt.Errorf("project %q %w in workspace %q", name, store.ErrNotFound, w.Name)
+}
+
 func init() {
 	rootCmd.AddCommand(projectCmd)
-	projectCmd.PersistentFlags().StringVarP(&projectWorkspace, "workspace", "w", "", "Workspace name (required)")
-	projectCmd.MarkPersistentFlagRequired("workspace")
-	projectCmd.AddCommand(projectCreateCmd, projectListCmd, projectDeleteCmd)
+	projectCmd.PersistentFlags().StringVarP(&projectWorkspace, "workspace", "w", "", "Workspace name (default: current context)")
+	projectCreateCmd.Flags().StringVar(&projectTemplate, "from-template", "", "Create the project with the tasks of a template (name or .json file)")
+	projectCreateCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Template variable as name=value (repeat for several)")
+	projectCreateCmd.Flags().StringVar(&templateStart, "start", "", "Date due offsets count from (YYYY-MM-DD, default: today)")
+	projectCmd.AddCommand(projectCreateCmd, projectListCmd, projectCapacityCmd, projectDeleteCmd)
 }
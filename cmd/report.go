@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportFrom      string
+	reportTo        string
+	reportGroupBy   string
+	reportFormat    string
+	reportRound     time.Duration
+	reportRoundMode string
+	reportTimesheet bool
+	reportWorkspace string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports over logged data",
+}
+
+var reportTimeCmd = &cobra.Command{
+	Use:   "time",
+	Short: "Summarize logged time by workspace, project, tag or day",
+	Long: `Summarize time entries started between --from and --to (inclusive, default: this week).
+With --round, each group's time per day is rounded (e.g. --round 15m --round-mode up).
+With --timesheet, the week containing --from is shown with one column per day.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reportFormat == "" {
+			reportFormat = cfg.Get("output")
+		}
+		from, to, err := reportRange()
+		if err != nil {
+			return err
+		}
+		var workspaceID *int64
+		if reportWorkspace != "" {
+			w, err := store.GetWorkspaceByName(ctx, db, reportWorkspace)
+			if err != nil {
+				return err
+			}
+			workspaceID = &w.ID
+		}
+		rows, err := store.TimeReport(ctx, db, from, to, reportGroupBy, workspaceID)
+		if err != nil {
+			return err
+		}
+		for i := range rows {
+			rows[i].Seconds, err = roundSeconds(rows[i].Seconds, reportRound, reportRoundMode)
+			if err != nil {
+				return err
+			}
+		}
+		if reportTimesheet {
+			return printTimesheet(rows, from)
+		}
+		return printTimeSummary(rows)
+	},
+}
+
+// reportRange returns [from, to) in local time. A timesheet always covers the week containing --from.
+func reportRange() (time.Time, time.Time, error) {
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	from := weekStart(today)
+	if reportFrom != "" {
+		d, err := parseDate("--from", reportFrom)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.Local)
+	}
+	if reportTimesheet {
+		from = weekStart(from)
+		return from, from.AddDate(0, 0, 7), nil
+	}
+	to := from.AddDate(0, 0, 7)
+	if reportTo != "" {
+		d, err := parseDate("--to", reportTo)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--to must not be before --from")
+	}
+	return from, to, nil
+}
+
+// weekStart returns the first day (week_start, Monday by default) of the week containing day.
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) - int(cfg.WeekStart()) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func roundSeconds(s int64, unit time.Duration, mode string) (int64, error) {
+	u := int64(unit.Seconds())
+	if u <= 0 {
+		return s, nil
+	}
+	switch mode {
+	case "up":
+		return (s + u - 1) / u * u, nil
+	case "down":
+		return s / u * u, nil
+	case "nearest":
+		return (s + u/2) / u * u, nil
+	}
+	return 0, fmt.Errorf("--round-mode %q: use up, down or nearest", mode)
+}
+
+func hours(s int64) string {
+	return strconv.FormatFloat(float64(s)/3600, 'f', 2, 64)
+}
+
+func printTimeSummary(rows []models.TimeReportRow) error {
+	var groups []string
+	totals := map[string]int64{}
+	var sum int64
+	for _, r := range rows {
+		if _, ok := totals[r.Group]; !ok {
+			groups = append(groups, r.Group)
+		}
+		totals[r.Group] += r.Seconds
+		sum += r.Seconds
+	}
+	switch reportFormat {
+	case "json":
+		type item struct {
+			Group   string `json:"group"`
+			Seconds int64  `json:"seconds"`
+			Hours   string `json:"hours"`
+		}
+		out := make([]item, 0, len(groups))
+		for _, g := range groups {
+			out = append(out, item{g, totals[g], hours(totals[g])})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{reportGroupBy, "seconds", "hours"})
+		for _, g := range groups {
+			w.Write([]string{g, strconv.FormatInt(totals[g], 10), hours(totals[g])})
+		}
+		w.Flush()
+		return w.Error()
+	case "table":
+		if len(groups) == 0 {
+			fmt.Println("No time logged in this period.")
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, g := range groups {
+			fmt.Fprintf(tw, "  %s\t%8s\n", g, formatSeconds(totals[g]))
+		}
+		fmt.Fprintf(tw, "  Total\t%8s\n", formatSeconds(sum))
+		return tw.Flush()
+	}
+	return fmt.Errorf("--format %q: use table, csv or json", reportFormat)
+}
+
+func printTimesheet(rows []models.TimeReportRow, from time.Time) error {
+	days := make([]string, 7)
+	for i := range days {
+		days[i] = from.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	var groups []string
+	cells := map[string]map[string]int64{}
+	for _, r := range rows {
+		if cells[r.Group] == nil {
+			groups = append(groups, r.Group)
+			cells[r.Group] = map[string]int64{}
+		}
+		cells[r.Group][r.Day] += r.Seconds
+	}
+	rowTotal := func(g string) int64 {
+		var s int64
+		for _, d := range days {
+			s += cells[g][d]
+		}
+		return s
+	}
+	switch reportFormat {
+	case "json":
+		type item struct {
+			Group string           `json:"group"`
+			Days  map[string]int64 `json:"days"`
+			Total int64            `json:"total_seconds"`
+		}
+		out := make([]item, 0, len(groups))
+		for _, g := range groups {
+			out = append(out, item{g, cells[g], rowTotal(g)})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(append(append([]string{reportGroupBy}, days...), "total"))
+		for _, g := range groups {
+			rec := []string{g}
+			for _, d := range days {
+				rec = append(rec, hours(cells[g][d]))
+			}
+			w.Write(append(rec, hours(rowTotal(g))))
+		}
+		w.Flush()
+		return w.Error()
+	case "table":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprint(tw, "  \t")
+		for i := range days {
+			fmt.Fprint(tw, from.AddDate(0, 0, i).Format("Mon 01-02")+"\t")
+		}
+		fmt.Fprintln(tw, "Total\t")
+		dayTotals := make([]int64, len(days))
+		for _, g := range groups {
+			fmt.Fprintf(tw, "  %s\t", g)
+			for i, d := range days {
+				fmt.Fprint(tw, cellDuration(cells[g][d])+"\t")
+				dayTotals[i] += cells[g][d]
+			}
+			fmt.Fprintln(tw, formatSeconds(rowTotal(g))+"\t")
+		}
+		fmt.Fprint(tw, "  Total\t")
+		var sum int64
+		for _, s := range dayTotals {
+			fmt.Fprint(tw, cellDuration(s)+"\t")
+			sum += s
+		}
+		fmt.Fprintln(tw, formatSeconds(sum)+"\t")
+		return tw.Flush()
+	}
+	return fmt.Errorf("--format %q: use table, csv or json", reportFormat)
+}
+
+func cellDuration(s int64) string {
+	if s == 0 {
+		return "-"
+	}
+	return formatSeconds(s)
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportTimeCmd)
+
+	reportTimeCmd.Flags().StringVar(&reportFrom, "from", "", "First day (YYYY-MM-DD, default: start of this week)")
+	reportTimeCmd.Flags().StringVar(&reportTo, "to", "", "Last day, inclusive (YYYY-MM-DD, default: 6 days after --from)")
+	reportTimeCmd.Flags().StringVar(&reportGroupBy, "group-by", "project", "Group by: workspace, project, tag, day")
+	reportTimeCmd.Flags().StringVar(&reportFormat, "format", "", "Output format: table, csv, json (default: output setting, see todo config)")
+	reportTimeCmd.Flags().DurationVar(&reportRound, "round", 0, "Round each group's time per day to this unit (e.g. 15m)")
+	reportTimeCmd.Flags().StringVar(&reportRoundMode, "round-mode", "up", "Rounding: up, down, nearest")
+	reportTimeCmd.Flags().BoolVar(&reportTimesheet, "timesheet", false, "Weekly timesheet: one column per day of the week containing --from")
+	reportTimeCmd.Flags().StringVarP(&reportWorkspace, "workspace", "w", "", "Only this workspace (default: all)")
+}
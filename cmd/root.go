@@ -1,40 +1,137 @@
 package cmd
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/cli-todo/internal/config"
 	"github.com/cli-todo/internal/store"
 	"github.com/spf13/cobra"
 )
 
 var dbPath string
+var profileFlag string
 var db *sql.DB
 
+// ctx is the command's context, cancelled on ctrl+c or SIGTERM so running store calls stop.
+var ctx = context.Background()
+
+// activeProfile is the profile the open database belongs to ("" = none).
+var activeProfile string
+
+// cfg is the config file (see todo config); marker is the .todo.yaml found above the working directory (nil = none).
+var cfg *config.Config
+var marker *config.Marker
+
+// dateLayout is how dates are shown and typed, from date_format.
+var dateLayout = "2006-01-02"
+
 var rootCmd = &cobra.Command{
 	Use:   "todo",
 	Short: "CLI todo app with workspaces and projects",
-	Long:  "Track tasks in workspaces (personal, work, daily, etc.) and optional projects/lists. Data stored locally in SQLite.",
+	Long:  "Track tasks in workspaces (personal, work, daily, etc.) and optional projects/lists. Data stored locally in SQLite.\nWithout a command, starts the interactive TUI.",
+	Args:  cobra.NoArgs,
+	// Errors are printed once, by main; usage is only shown for bad flags and arguments.
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		var err error
-		db, err = store.Open(dbPath)
-		if err != nil {
-			return fmt.Errorf("database: %w", err)
-		}
-		return nil
-	},
-	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if db != nil {
-			db.Close()
-		}
+		return setup(cmd, store.Open)
 	},
+	RunE: runTUI,
+}
+
+// setup loads the config and opens the database with open.
+func setup(cmd *cobra.Command, open func(ctx context.Context, path string) (*sql.DB, error)) error {
+	cmd.SilenceUsage = true
+	ctx = cmd.Context()
+	var err error
+	if cfg, err = config.Load(); err != nil {
+		return err
+	}
+	dateLayout = cfg.DateLayout()
+	store.SnapshotKeep = cfg.Snapshots()
+	if marker, err = findMarker(); err != nil {
+		return err
+	}
+	var path string
+	if path, activeProfile, err = resolveDB(); err != nil {
+		return err
+	}
+	db, err = open(ctx, path)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	return nil
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Path to SQLite database (default: config dir/cli-todo/todo.db)")
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Path to SQLite database, or dir:PATH for a directory of Markdown files (default: from TODO_DB, .todo.yaml or todo config, else todo.db next to the executable)")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Use the database of a profile (see todo profile)")
+	store.Passphrase = promptPassphrase
+}
+
+// resolveDB picks the database and the profile it belongs to: --db, --profile, TODO_DB, TODO_PROFILE,
+// the .todo.yaml's db or profile, the config file's default profile, its db, then "" for the default
+// next to the executable.
+func resolveDB() (path, profile string, err error) {
+	if dbPath != "" {
+		return config.ExpandHome(dbPath), "", nil
+	}
+	if profileFlag != "" {
+		return profileDB(profileFlag)
+	}
+	path, source := cfg.DB()
+	if source == config.SourceEnv {
+		return path, "", nil
+	}
+	defaultProfile, profileSource := cfg.Lookup("profile")
+	if profileSource == config.SourceEnv {
+		return profileDB(defaultProfile)
+	}
+	if marker != nil && marker.DB != "" {
+		return marker.DB, "", nil
+	}
+	if marker != nil && marker.Profile != "" {
+		return profileDB(marker.Profile)
+	}
+	if defaultProfile != "" {
+		return profileDB(defaultProfile)
+	}
+	return path, "", nil
+}
+
+func profileDB(name string) (string, string, error) {
+	path, err := cfg.ProfileDB(name)
+	return path, name, err
+}
+
+// findMarker returns the .todo.yaml marker above the working directory, or nil.
+func findMarker() (*config.Marker, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return config.FindMarker(dir)
 }
 
-// Execute runs the root command.
+// Execute runs the root command. An interrupted command reports "interrupted"; its transaction is rolled back.
 func Execute() error {
-	return rootCmd.Execute()
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := rootCmd.ExecuteContext(sigCtx)
+	// Closed here rather than in a post-run hook so it also happens when the command fails: closing
+	// writes a directory store back to its files.
+	if db != nil {
+		if cerr := store.Close(db); cerr != nil && err == nil {
+			err = fmt.Errorf("database: %w", cerr)
+		}
+	}
+	if err != nil && sigCtx.Err() != nil {
+		return errors.New("interrupted")
+	}
+	return err
 }
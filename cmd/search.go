@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchWorkspace string
+	searchProject   string
+	searchLimit     int
+	searchRaw       bool
+	searchFormat    string
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search [terms]",
+	Short: "Full-text search over task titles and descriptions",
+	Long: `Search tasks, best matches first. Every word must match, as a prefix ("depl mig" finds "deploy migration").
+Use --raw to pass an FTS5 query as is (e.g. 'title:deploy OR "code review"').`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if searchFormat == "" {
+			searchFormat = cfg.Get("output")
+		}
+		if searchFormat != "table" && searchFormat != "json" {
+			return fmt.Errorf("--format %q: use table or json", searchFormat)
+		}
+		var workspaceID, projectID *int64
+		if searchWorkspace != "" {
+			w, err := store.GetWorkspaceByName(ctx, db, searchWorkspace)
+			if err != nil {
+				return err
+			}
+			workspaceID = &w.ID
+			if searchProject != "" {
+				p, err := findProject(w, searchProject)
+				if err != nil {
+					return err
+				}
+				projectID = &p.ID
+			}
+		} else if searchProject != "" {
+			return fmt.Errorf("--project needs --workspace")
+		}
+		query := strings.Join(args, " ")
+		if !searchRaw {
+			query = store.SearchQuery(query)
+		}
+		hits, err := store.SearchTasks(ctx, db, query, workspaceID, projectID, searchLimit)
+		if err != nil {
+			return fmt.Errorf("search: %w", err)
+		}
+		if searchFormat == "json" {
+			for i := range hits {
+				hits[i].Title = highlight(hits[i].Title, "", "")
+				hits[i].Snippet = highlight(hits[i].Snippet, "", "")
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(hits)
+		}
+		if len(hits) == 0 {
+			fmt.Println("No matches.")
+			return nil
+		}
+		start, end := "*", "*"
+		if isTerminal(os.Stdout) {
+			start, end = "\x1b[1;33m", "\x1b[0m"
+		}
+		for _, h := range hits {
+			list := h.ProjectName
+			if list == "" {
+				list = "Default"
+			}
+			fmt.Printf("  %d  %s/%s  [%s]  %s\n", h.Task.ID, h.WorkspaceName, list, h.Task.Status, highlight(h.Title, start, end))
+			if h.Snippet != "" {
+				fmt.Printf("      %s\n", strings.ReplaceAll(highlight(h.Snippet, start, end), "\n", " "))
+			}
+		}
+		return nil
+	},
+}
+
+// highlight replaces the store's match markers with start/end.
+func highlight(s, start, end string) string {
+	return strings.NewReplacer(store.HighlightStart, start, store.HighlightEnd, end).Replace(s)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringVarP(&searchWorkspace, "workspace", "w", "", "Only this workspace")
+	searchCmd.Flags().StringVarP(&searchProject, "project", "p", "", "Only this project/list (needs --workspace)")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "Maximum number of results (0 = all)")
+	searchCmd.Flags().BoolVar(&searchRaw, "raw", false, "Pass the terms as an FTS5 query")
+	searchCmd.Flags().StringVar(&searchFormat, "format", "", "Output format: table, json (default: output setting, see todo config)")
+}
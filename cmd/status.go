@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusWorkspace string
+	statusColor     string
+	statusDone      bool
+	statusNewName   string
+	statusPosition  int
+	statusMoveTo    string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Manage the status workflow of a workspace",
+	Long:  "Each workspace has its own ordered list of statuses (default: todo, in_progress, done). Statuses marked as done count as finished.",
+}
+
+var statusListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List statuses in workflow order",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(statusWorkspace)
+		if err != nil {
+			return err
+		}
+		list, err := store.ListStatuses(ctx, db, w.ID)
+		if err != nil {
+			return err
+		}
+		for _, st := range list {
+			extra := ""
+			if st.IsDone {
+				extra += " (done)"
+			}
+			if st.Color != "" {
+				extra += " color:" + st.Color
+			}
+			fmt.Printf("  %d  %s%s\n", st.Position, st.Name, extra)
+		}
+		return nil
+	},
+}
+
+var statusAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add a status at the end of the workflow",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(statusWorkspace)
+		if err != nil {
+			return err
+		}
+		st, err := store.CreateStatus(ctx, db, w.ID, args[0], statusColor, statusDone)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("position") {
+			if err := store.MoveStatus(ctx, db, st.ID, statusPosition); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Added status %q to %s\n", st.Name, w.Name)
+		return nil
+	},
+}
+
+var statusEditCmd = &cobra.Command{
+	Use:   "edit [name]",
+	Short: "Rename, recolor, reorder or change the done flag of a status",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(statusWorkspace)
+		if err != nil {
+			return err
+		}
+		st, err := store.GetStatusByName(ctx, db, w.ID, args[0])
+		if err != nil {
+			return err
+		}
+		name, color, done := st.Name, st.Color, st.IsDone
+		if statusNewName != "" {
+			name = statusNewName
+		}
+		if cmd.Flags().Changed("color") {
+			color = statusColor
+		}
+		if cmd.Flags().Changed("done") {
+			done = statusDone
+		}
+		if _, err := store.UpdateStatus(ctx, db, st.ID, name, color, done); err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("position") {
+			if err := store.MoveStatus(ctx, db, st.ID, statusPosition); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Updated status %q\n", name)
+		return nil
+	},
+}
+
+var statusDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a status (its tasks move to --to, default: first other status)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(statusWorkspace)
+		if err != nil {
+			return err
+		}
+		st, err := store.GetStatusByName(ctx, db, w.ID, args[0])
+		if err != nil {
+			return err
+		}
+		to := statusMoveTo
+		if to == "" {
+			list, err := store.ListStatuses(ctx, db, w.ID)
+			if err != nil {
+				return err
+			}
+			for _, other := range list {
+				if other.ID != st.ID {
+					to = other.Name
+					break
+				}
+			}
+			if to == "" {
+				return fmt.Errorf("cannot delete the only status of workspace %q", w.Name)
+			}
+		}
+		if err := store.DeleteStatus(ctx, db, st.ID, to); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted status %q (tasks moved to %q)\n", st.Name, to)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.PersistentFlags().StringVarP(&statusWorkspace, "workspace", "w", "", "Workspace name (default: current context)")
+
+	statusAddCmd.Flags().StringVar(&statusColor, "color", "", "Color (e.g. green, #ff0000)")
+	statusAddCmd.Flags().BoolVar(&statusDone, "done", false, "Tasks in this status count as done")
+	statusAddCmd.Flags().IntVar(&statusPosition, "position", 0, "0-based position in the workflow (default: last)")
+
+	statusEditCmd.Flags().StringVar(&statusNewName, "name", "", "New name")
+	statusEditCmd.Flags().StringVar(&statusColor, "color", "", "New color (empty to clear)")
+	statusEditCmd.Flags().BoolVar(&statusDone, "done", false, "Tasks in this status count as done")
+	statusEditCmd.Flags().IntVar(&statusPosition, "position", 0, "New 0-based position in the workflow")
+
+	statusDeleteCmd.Flags().StringVar(&statusMoveTo, "to", "", "Status to move the deleted status's tasks to")
+
+	statusCmd.AddCommand(statusListCmd, statusAddCmd, statusEditCmd, statusDeleteCmd)
+}
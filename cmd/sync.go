@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cli-todo/internal/config"
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncWith        string
+	syncInteractive bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Merge changes with another todo database, in both directions",
+	Long: `Sync two databases, e.g. a laptop's and a desktop's, so both end up with the same workspaces,
+lists and tasks. --with is another database file, a profile name, or a directory of change files
+(e.g. in a folder shared by a file sync service): each database writes its changes there as
+<id>.json and merges everyone else's.
+
+Every field remembers when it last changed; the later change wins. A field changed on both sides
+since the last sync is a conflict: it is reported, and with --interactive you pick the value.
+Until two databases have synced once, the later change wins without conflicts.
+Deleted workspaces, lists and tasks are deleted on the other side too, unless they were changed
+there after the delete. Notes and finished time entries are merged; attachments, templates and
+settings are not synced. Clocks should be roughly right on both machines.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		with := syncWith
+		if with == "" {
+			with = config.ExpandHome(cfg.Get("sync_with"))
+		}
+		if with == "" {
+			return fmt.Errorf("nothing to sync with: pass --with PATH or run: todo config set sync_with PATH")
+		}
+		if path, ok := cfg.Profiles()[with]; ok {
+			if _, err := os.Stat(with); err != nil {
+				with = config.ExpandHome(path)
+			}
+		}
+		var resolve store.ConflictResolver
+		if syncInteractive {
+			resolve = askConflict(bufio.NewReader(os.Stdin))
+		}
+		var report store.SyncReport
+		if info, err := os.Stat(with); err == nil && info.IsDir() {
+			if report, err = store.SyncDir(ctx, db, with, resolve); err != nil {
+				return err
+			}
+			fmt.Printf("Synced with %s (%d change files read)\n", with, report.Peers)
+			fmt.Printf("  here: %s\n", formatSyncChanges(report.Local))
+		} else {
+			other, err := store.Open(ctx, with)
+			if err != nil {
+				return fmt.Errorf("%s: %w", with, err)
+			}
+			defer store.Close(other)
+			if report, err = store.SyncDatabases(ctx, db, other, resolve); err != nil {
+				return err
+			}
+			fmt.Printf("Synced with %s\n", with)
+			fmt.Printf("  here:  %s\n", formatSyncChanges(report.Local))
+			fmt.Printf("  there: %s\n", formatSyncChanges(report.Remote))
+		}
+		for _, c := range report.Conflicts {
+			kept, lost, side := c.Local, c.Remote, "here"
+			if c.UseRemote {
+				kept, lost, side = c.Remote, c.Local, "there"
+			}
+			fmt.Printf("  conflict: %s %q %s: kept %s (%s) over %s\n", c.Kind, c.Label, c.Field,
+				conflictValue(kept), side, conflictValue(lost))
+		}
+		return nil
+	},
+}
+
+func formatSyncChanges(c store.SyncChanges) string {
+	return fmt.Sprintf("%d added, %d updated, %d deleted", c.Added, c.Updated, c.Deleted)
+}
+
+func conflictValue(v *string) string {
+	if v == nil || *v == "" {
+		return "(empty)"
+	}
+	return fmt.Sprintf("%q", *v)
+}
+
+// conflictTime shortens a sync clock to the second.
+func conflictTime(at string) string {
+	if len(at) > 19 {
+		return at[:19]
+	}
+	return at
+}
+
+// askConflict prompts for each conflict on stderr; an empty answer keeps the later change.
+func askConflict(in *bufio.Reader) store.ConflictResolver {
+	return func(c store.Conflict) (bool, error) {
+		def := "h"
+		if c.UseRemote {
+			def = "t"
+		}
+		fmt.Fprintf(os.Stderr, "Conflict: %s %q, %s\n", c.Kind, c.Label, c.Field)
+		fmt.Fprintf(os.Stderr, "  [h]ere:  %s  (changed %s)\n", conflictValue(c.Local), conflictTime(c.LocalAt))
+		fmt.Fprintf(os.Stderr, "  [t]here: %s  (changed %s)\n", conflictValue(c.Remote), conflictTime(c.RemoteAt))
+		for {
+			fmt.Fprintf(os.Stderr, "Keep [h/t] (default %s): ", def)
+			line, err := in.ReadString('\n')
+			answer := strings.ToLower(strings.TrimSpace(line))
+			if answer == "" && err != nil {
+				return false, fmt.Errorf("sync cancelled: no answer for %s %q", c.Kind, c.Label)
+			}
+			if answer == "" {
+				answer = def
+			}
+			switch answer {
+			case "h", "here":
+				return false, nil
+			case "t", "there":
+				return true, nil
+			}
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVar(&syncWith, "with", "", "Other database, profile or change-file directory (default: sync_with from todo config)")
+	syncCmd.Flags().BoolVarP(&syncInteractive, "interactive", "i", false, "Ask which value to keep for each conflict")
+}
@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/quickadd"
 	"github.com/cli-todo/internal/store"
 	"github.com/spf13/cobra"
 )
@@ -21,89 +28,200 @@ var taskCmd = &cobra.Command{
 var taskCreateCmd = &cobra.Command{
 	Use:   "create [title]",
 	Short: "Create a task",
-	Long:  "Create a task in a workspace. Use --project to put it in a project/list, or omit for default list.",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		w, err := store.GetWorkspaceByName(db, taskWorkspace)
+	Long: `Create a task in a workspace. Use --project to put it in a project/list; without it the task goes to
+the current context's list (see todo use) or the default list.
+The title may contain quick-add markup (flags win over markup; --literal turns it off):
+  todo task create "Buy milk #groceries @errands !high ^tomorrow ~30m" -w personal
+#list picks the list (- or _ for spaces), @tag adds a tag, !low/!medium/!high sets the priority,
+^today/^tomorrow/^fri/^+3d/^2026-05-01 the due date and ~30m/~3pt the estimate.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTaskCreate,
+}
+
+// runTaskCreate creates the task titled args[0]; shared by "task create" and "add".
+func runTaskCreate(cmd *cobra.Command, args []string) error {
+	qa := quickadd.Task{Title: args[0]}
+	if !literalTitle {
+		var err error
+		if qa, err = quickadd.Parse(args[0], time.Now()); err != nil {
+			return err
+		}
+	}
+	w, projectID, err := taskScope(qa.Project == "")
+	if err != nil {
+		return err
+	}
+	if taskProject == "" && qa.Project != "" {
+		projects, err := store.ListProjects(ctx, db, w.ID)
 		if err != nil {
-			return fmt.Errorf("workspace %q: %w", taskWorkspace, err)
+			return err
 		}
-		var projectID *int64
-		if taskProject != "" {
-			projects, err := store.ListProjects(db, w.ID)
-			if err != nil {
+		p, ok := quickadd.MatchProject(qa.Project, projects)
+		if !ok {
+			return fmt.Errorf("no list matches #%s in workspace %q", qa.Project, w.Name)
+		}
+		projectID = &p.ID
+	}
+	pri := qa.Priority
+	if priority != "" {
+		if pri, err = store.ParsePriority(priority); err != nil {
+			return err
+		}
+	}
+	due := qa.Due
+	if dueDate != "" {
+		if due, err = parseDate("due", dueDate); err != nil {
+			return err
+		}
+	}
+	est := qa.Estimate
+	if estimate != "" {
+		est = estimate
+	}
+	minutes, points, err := store.ParseEstimate(est)
+	if err != nil {
+		return err
+	}
+	fields, err := parseFieldAssignments(fieldValues)
+	if err != nil {
+		return err
+	}
+	if err := store.CheckTaskFields(ctx, db, w.ID, fields); err != nil {
+		return err
+	}
+	allTags := append(qa.Tags, tags...)
+	if marker != nil && (marker.Workspace == "" || marker.Workspace == w.Name) {
+		allTags = append(allTags, marker.Tags...)
+	}
+	// A failing field, tag or estimate leaves no half-created task behind.
+	var t models.Task
+	err = store.WithTx(ctx, db, func(tx store.DBTX) error {
+		var err error
+		if t, err = store.CreateTask(ctx, tx, w.ID, projectID, qa.Title, description, status, pri, due); err != nil {
+			return err
+		}
+		if len(fields) > 0 {
+			if t, err = store.SetTaskFields(ctx, tx, t.ID, fields); err != nil {
 				return err
 			}
-			for _, p := range projects {
-				if p.Name == taskProject {
-					projectID = &p.ID
-					break
-				}
-			}
-			if projectID == nil {
-				return fmt.Errorf("project %q not found in workspace %q", taskProject, taskWorkspace)
+		}
+		if len(allTags) > 0 {
+			if t, err = store.AddTaskTags(ctx, tx, t.ID, allTags); err != nil {
+				return err
 			}
 		}
-		due := parseDue(dueDate)
-		t, err := store.CreateTask(db, w.ID, projectID, args[0], description, status, priority, due)
-		if err != nil {
-			return err
+		if minutes != nil || points != nil {
+			if t, err = store.SetTaskEstimate(ctx, tx, t.ID, minutes, points); err != nil {
+				return err
+			}
 		}
-		fmt.Printf("Created task %d: %s [%s]\n", t.ID, t.Title, t.Status)
 		return nil
-	},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created task %d (%s): %s [%s]\n", t.ID, shortID(t), t.Title, t.Status)
+	return nil
 }
 
 var (
-	description string
-	status      string
-	priority    string
-	dueDate     string
+	description  string
+	status       string
+	priority     string
+	dueDate      string
+	estimate     string
+	tags         []string
+	fieldValues  []string
+	literalTitle bool
 )
 
 var taskListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List tasks",
-	Long:  "List tasks in a workspace. Use --project to filter by project, or omit for default list.",
+	Long:  "List tasks in a workspace. Use --project to filter by project; without it the current context's list (see todo use) or the default list is shown.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		w, err := store.GetWorkspaceByName(db, taskWorkspace)
+		if listFormat == "" {
+			listFormat = cfg.Get("output")
+		}
+		if listFormat != "table" && listFormat != "json" {
+			return fmt.Errorf("--format %q: use table or json", listFormat)
+		}
+		w, projectID, err := taskScope(true)
 		if err != nil {
-			return fmt.Errorf("workspace %q: %w", taskWorkspace, err)
+			return err
 		}
-		var projectID *int64
-		if taskProject != "" {
-			projects, err := store.ListProjects(db, w.ID)
+		list, err := store.ListTasks(ctx, db, w.ID, projectID)
+		if err != nil {
+			return err
+		}
+		if len(listWhere) > 0 || listSort != "" {
+			fields, err := store.ListFields(ctx, db, w.ID)
 			if err != nil {
 				return err
 			}
-			for _, p := range projects {
-				if p.Name == taskProject {
-					projectID = &p.ID
-					break
+			var filters []store.FieldFilter
+			for _, s := range listWhere {
+				f, err := store.ParseFieldFilter(s)
+				if err != nil {
+					return err
+				}
+				filters = append(filters, f)
+			}
+			if list, err = store.FilterTasks(list, fields, filters); err != nil {
+				return err
+			}
+			if listSort != "" {
+				name := strings.TrimPrefix(listSort, "-")
+				if _, err := store.GetFieldByName(ctx, db, w.ID, name); err != nil {
+					return err
 				}
+				store.SortTasksByField(list, name, strings.HasPrefix(listSort, "-"))
 			}
-			if projectID == nil {
-				return fmt.Errorf("project %q not found", taskProject)
+		}
+		if listFormat == "json" {
+			if list == nil {
+				list = []models.Task{}
 			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+		if len(list) == 0 {
+			fmt.Println("No tasks.")
+			return nil
 		}
-		list, err := store.ListTasks(db, w.ID, projectID)
+		for _, t := range list {
+			printTaskLine(t)
+		}
+		return printEstimateSummary(w.ID, projectID, list)
+	},
+}
+
+var (
+	listWhere  []string
+	listSort   string
+	listFormat string
+)
+
+var taskNextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "List next actions (not done, not blocked)",
+	Long:  "List tasks that can be worked on now: not in a done status and not waiting on unfinished dependencies. Searches all lists of the workspace unless --project is given.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, projectID, err := taskScope(true)
+		if err != nil {
+			return err
+		}
+		list, err := store.ListNextActions(ctx, db, w.ID, projectID)
 		if err != nil {
 			return err
 		}
 		if len(list) == 0 {
-			fmt.Println("No tasks.")
+			fmt.Println("No next actions.")
 			return nil
 		}
 		for _, t := range list {
-			due := ""
-			if t.DueDate != nil {
-				due = " due:" + t.DueDate.Format("2006-01-02")
-			}
-			pri := ""
-			if t.Priority != "" {
-				pri = " [" + t.Priority + "]"
-			}
-			fmt.Printf("  %d  [%s]%s  %s%s\n", t.ID, t.Status, pri, t.Title, due)
+			printTaskLine(t)
 		}
 		return nil
 	},
@@ -114,14 +232,25 @@ var taskEditCmd = &cobra.Command{
 	Short: "Edit a task",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var id int64
-		if _, err := fmt.Sscanf(args[0], "%d", &id); err != nil {
-			return fmt.Errorf("task id must be a number")
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		t, err := store.GetTask(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		estMinutes, estPoints, err := store.ParseEstimate(editEstimate)
+		if err != nil {
+			return err
 		}
-		t, err := store.GetTask(db, id)
+		fields, err := parseFieldAssignments(editFields)
 		if err != nil {
 			return err
 		}
+		if err := store.CheckTaskFields(ctx, db, t.WorkspaceID, fields); err != nil {
+			return err
+		}
 		title := t.Title
 		if editTitle != "" {
 			title = editTitle
@@ -136,13 +265,38 @@ var taskEditCmd = &cobra.Command{
 		}
 		pri := t.Priority
 		if editPriority != "" {
-			pri = editPriority
+			if pri, err = store.ParsePriority(editPriority); err != nil {
+				return err
+			}
 		}
 		due := t.DueDate
 		if editDue != "" {
-			due = parseDue(editDue)
+			if due, err = parseDate("due", editDue); err != nil {
+				return err
+			}
 		}
-		_, err = store.UpdateTask(db, id, title, desc, st, pri, due)
+		err = store.WithTx(ctx, db, func(tx store.DBTX) error {
+			if _, err := store.UpdateTask(ctx, tx, id, title, desc, st, pri, due); err != nil {
+				return err
+			}
+			if _, err := store.AddTaskTags(ctx, tx, id, editTags); err != nil {
+				return err
+			}
+			if _, err := store.RemoveTaskTags(ctx, tx, id, editUntags); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("estimate") {
+				if _, err := store.SetTaskEstimate(ctx, tx, id, estMinutes, estPoints); err != nil {
+					return err
+				}
+			}
+			if len(fields) > 0 {
+				if _, err := store.SetTaskFields(ctx, tx, id, fields); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
@@ -157,18 +311,147 @@ var (
 	editStatus      string
 	editPriority    string
 	editDue         string
+	editEstimate    string
+	editTags        []string
+	editUntags      []string
+	editFields      []string
 )
 
+var dependOn string
+
+var taskDependCmd = &cobra.Command{
+	Use:   "depend [id]",
+	Short: "Mark a task as blocked by another task (--on)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		on, err := parseTaskID(dependOn)
+		if err != nil {
+			return err
+		}
+		if err := store.AddDependency(ctx, db, id, on); err != nil {
+			return err
+		}
+		fmt.Printf("Task %d now depends on task %d\n", id, on)
+		return nil
+	},
+}
+
+var taskUndependCmd = &cobra.Command{
+	Use:   "undepend [id]",
+	Short: "Remove a dependency (--on) from a task",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		on, err := parseTaskID(dependOn)
+		if err != nil {
+			return err
+		}
+		if err := store.RemoveDependency(ctx, db, id, on); err != nil {
+			return err
+		}
+		fmt.Printf("Task %d no longer depends on task %d\n", id, on)
+		return nil
+	},
+}
+
+var taskStartCmd = &cobra.Command{
+	Use:   "start [id]",
+	Short: "Start a timer on a task (stops any running timer)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		if prev, err := store.RunningTimer(ctx, db); err != nil {
+			return err
+		} else if prev != nil && prev.TaskID != id {
+			fmt.Printf("Stopped timer on task %d after %s\n", prev.TaskID, formatSeconds(prev.Seconds))
+		}
+		if _, err := store.StartTimer(ctx, db, id); err != nil {
+			return err
+		}
+		fmt.Printf("Started timer on task %d\n", id)
+		return nil
+	},
+}
+
+var taskStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running timer",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		e, err := store.StopTimer(ctx, db)
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("no timer is running")
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Stopped timer on task %d: %s logged\n", e.TaskID, formatSeconds(e.Seconds))
+		return nil
+	},
+}
+
+var taskNoteCmd = &cobra.Command{
+	Use:   "note [id] [text]",
+	Short: "Append a note to a task",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		if _, err := store.AddNote(ctx, db, id, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Added note to task %d\n", id)
+		return nil
+	},
+}
+
+var taskNotesCmd = &cobra.Command{
+	Use:   "notes [id]",
+	Short: "Show the notes of a task, oldest first",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		notes, err := store.ListNotes(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if len(notes) == 0 {
+			fmt.Println("No notes.")
+			return nil
+		}
+		for _, n := range notes {
+			fmt.Printf("  %s\n", n.CreatedAt.Local().Format(dateLayout+" 15:04"))
+			fmt.Printf("    %s\n", strings.ReplaceAll(n.Body, "\n", "\n    "))
+		}
+		return nil
+	},
+}
+
 var taskDeleteCmd = &cobra.Command{
 	Use:   "delete [id]",
 	Short: "Delete a task",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var id int64
-		if _, err := fmt.Sscanf(args[0], "%d", &id); err != nil {
-			return fmt.Errorf("task id must be a number")
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
 		}
-		if err := store.DeleteTask(db, id); err != nil {
+		if err := store.DeleteTask(ctx, db, id); err != nil {
 			return err
 		}
 		fmt.Printf("Deleted task %d\n", id)
@@ -176,35 +459,143 @@ var taskDeleteCmd = &cobra.Command{
 	},
 }
 
-func parseDue(s string) *time.Time {
-	if s == "" {
+// parseTaskID reads a task ID: the number, or a unique prefix of the task's UUID.
+func parseTaskID(s string) (int64, error) {
+	return store.ResolveTaskID(ctx, db, s)
+}
+
+// shortIDLen is the length of the UUID prefixes shown for tasks, computed on first use.
+var shortIDLen int
+
+// shortID returns the task's UUID shortened to a prefix that is unique in the database.
+func shortID(t models.Task) string {
+	if shortIDLen == 0 {
+		n, err := store.ShortIDLength(ctx, db)
+		if err != nil {
+			n = 8
+		}
+		shortIDLen = n
+	}
+	return store.ShortID(t.UUID, shortIDLen)
+}
+
+func printTaskLine(t models.Task) {
+	due := ""
+	if t.DueDate != nil {
+		due = " due:" + t.DueDate.Format(dateLayout)
+	}
+	pri := ""
+	if t.Priority != "" {
+		pri = " [" + string(t.Priority) + "]"
+	}
+	est := ""
+	if e := store.FormatEstimate(t); e != "" {
+		est = " ~" + e
+	}
+	tags := ""
+	for _, tag := range t.Tags {
+		tags += " @" + tag
+	}
+	names := make([]string, 0, len(t.Fields))
+	for name := range t.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := ""
+	for _, name := range names {
+		fields += " " + name + ":" + store.FormatFieldValue(t.Fields[name])
+	}
+	blocked := ""
+	if t.Blocked {
+		blocked = " (blocked)"
+	}
+	fmt.Printf("  %d  %s  [%s]%s  %s%s%s%s%s%s\n", t.ID, shortID(t), t.Status, pri, t.Title, tags, due, est, fields, blocked)
+}
+
+// printEstimateSummary prints the remaining estimate of the open tasks in list, if any have one.
+func printEstimateSummary(workspaceID int64, projectID *int64, list []models.Task) error {
+	statuses, err := store.ListStatuses(ctx, db, workspaceID)
+	if err != nil {
+		return err
+	}
+	done := map[string]bool{}
+	for _, st := range statuses {
+		done[st.Name] = st.IsDone
+	}
+	logged, err := store.TaskTimeTotals(ctx, db, workspaceID, projectID)
+	if err != nil {
+		return err
+	}
+	var minutes, loggedSeconds int64
+	var points float64
+	estimated := false
+	for _, t := range list {
+		if done[t.Status] {
+			continue
+		}
+		if t.EstimateMinutes != nil {
+			minutes += *t.EstimateMinutes
+			loggedSeconds += logged[t.ID]
+			estimated = true
+		}
+		if t.EstimatePoints != nil {
+			points += *t.EstimatePoints
+			estimated = true
+		}
+	}
+	if !estimated {
 		return nil
 	}
-	for _, layout := range []string{"2006-01-02", "2006/01/02", time.RFC3339[:10]} {
+	fmt.Println("  Remaining: " + store.FormatCapacity(minutes, points, loggedSeconds))
+	return nil
+}
+
+// parseDate reads a date given for field (e.g. "due" or "--from") in date_format or YYYY-MM-DD; "" is no date.
+func parseDate(field, s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{dateLayout, "2006-01-02", "2006/01/02"} {
 		t, err := time.Parse(layout, s)
 		if err == nil {
-			return &t
+			return &t, nil
 		}
 	}
-	return nil
+	return nil, store.ErrInvalidField{Field: field, Value: s, Reason: "use " + cfg.Get("date_format")}
 }
 
 func init() {
 	rootCmd.AddCommand(taskCmd)
-	taskCmd.PersistentFlags().StringVarP(&taskWorkspace, "workspace", "w", "", "Workspace name (required)")
-	taskCmd.PersistentFlags().StringVarP(&taskProject, "project", "p", "", "Project/list name (optional; default list if omitted)")
-	taskCmd.MarkPersistentFlagRequired("workspace")
+	taskCmd.PersistentFlags().StringVarP(&taskWorkspace, "workspace", "w", "", "Workspace name (default: current context, see todo use)")
+	taskCmd.PersistentFlags().StringVarP(&taskProject, "project", "p", "", "Project/list name (default: the context's list, else the default list)")
 
 	taskCreateCmd.Flags().StringVarP(&description, "description", "d", "", "Task description")
-	taskCreateCmd.Flags().StringVarP(&status, "status", "s", "todo", "Status: todo, in_progress, done")
+	taskCreateCmd.Flags().StringVarP(&status, "status", "s", "", "Status from the workspace workflow (default: first status)")
 	taskCreateCmd.Flags().StringVarP(&priority, "priority", "", "", "Priority: low, medium, high")
 	taskCreateCmd.Flags().StringVar(&dueDate, "due", "", "Due date (YYYY-MM-DD)")
+	taskCreateCmd.Flags().StringVar(&estimate, "estimate", "", "Estimate: duration (e.g. 1h30m) or points (e.g. 3pt)")
+	taskCreateCmd.Flags().StringSliceVar(&tags, "tag", nil, "Tag (repeat or comma-separate for several)")
+	taskCreateCmd.Flags().StringArrayVar(&fieldValues, "field", nil, "Custom field value as name=value (repeat for several)")
+	taskCreateCmd.Flags().BoolVar(&literalTitle, "literal", false, "Use the title as is, without quick-add markup")
+
+	taskListCmd.Flags().StringArrayVar(&listWhere, "where", nil, "Filter on a custom field: name=value, or !=, <, <=, >, >=, ~ (contains); repeat to combine")
+	taskListCmd.Flags().StringVar(&listSort, "sort", "", "Sort by a custom field (prefix with - for descending)")
+	taskListCmd.Flags().StringVar(&listFormat, "format", "", "Output format: table, json (default: output setting, see todo config)")
 
 	taskEditCmd.Flags().StringVar(&editTitle, "title", "", "New title")
 	taskEditCmd.Flags().StringVar(&editDescription, "description", "", "New description")
-	taskEditCmd.Flags().StringVar(&editStatus, "status", "", "New status: todo, in_progress, done")
+	taskEditCmd.Flags().StringVar(&editStatus, "status", "", "New status from the workspace workflow (see: todo status list)")
 	taskEditCmd.Flags().StringVar(&editPriority, "priority", "", "New priority: low, medium, high")
 	taskEditCmd.Flags().StringVar(&editDue, "due", "", "New due date (YYYY-MM-DD)")
+	taskEditCmd.Flags().StringVar(&editEstimate, "estimate", "", "New estimate: duration (e.g. 1h30m) or points (e.g. 3pt); empty to clear")
+	taskEditCmd.Flags().StringSliceVar(&editTags, "tag", nil, "Add tag (repeat or comma-separate for several)")
+	taskEditCmd.Flags().StringSliceVar(&editUntags, "untag", nil, "Remove tag")
+	taskEditCmd.Flags().StringArrayVar(&editFields, "field", nil, "Set a custom field as name=value (empty value clears it; repeat for several)")
+
+	taskDependCmd.Flags().StringVar(&dependOn, "on", "", "ID of the task that must be done first (required)")
+	taskDependCmd.MarkFlagRequired("on")
+	taskUndependCmd.Flags().StringVar(&dependOn, "on", "", "ID of the task to no longer depend on (required)")
+	taskUndependCmd.MarkFlagRequired("on")
 
-	taskCmd.AddCommand(taskCreateCmd, taskListCmd, taskEditCmd, taskDeleteCmd)
+	taskCmd.AddCommand(taskCreateCmd, taskListCmd, taskNextCmd, taskEditCmd, taskDependCmd, taskUndependCmd, taskStartCmd, taskStopCmd, taskNoteCmd, taskNotesCmd, taskDeleteCmd)
 }
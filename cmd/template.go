@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	templateWorkspace string
+	templateProject   string
+	templateName      string
+	templateVars      []string
+	templateStart     string
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage project templates (reusable checklists)",
+	Long: `A template is a list of tasks with optional descriptions, priorities, estimates, tags and due
+offsets ("+3d", "+1w") counted from the start date. Text may contain {{variables}}, filled with
+--var name=value; {{project}} and {{date}} are always available. Templates are stored in the
+database; anywhere a template name is expected, a path to a .json file works too.
+
+Example file:
+  {"name": "release", "tasks": [
+    {"title": "Freeze v{{version}}", "due": "+0d", "priority": "high"},
+    {"title": "Publish v{{version}}", "due": "+3d", "estimate": "1h", "tags": ["release"]}]}`,
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored templates",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := store.ListTemplates(ctx, db)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No templates.")
+			return nil
+		}
+		for _, tpl := range list {
+			extra := ""
+			if vars := store.TemplateVars(tpl); len(vars) > 0 {
+				extra = "  vars: " + strings.Join(vars, ", ")
+			}
+			if tpl.Description != "" {
+				extra = "  " + tpl.Description + extra
+			}
+			fmt.Printf("  %s  (%d tasks)%s\n", tpl.Name, len(tpl.Tasks), extra)
+		}
+		return nil
+	},
+}
+
+var templateShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Print a template as JSON (save it to a file to edit and re-import)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tpl, err := findTemplate(args[0])
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tpl)
+	},
+}
+
+var templateImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Store a template from a JSON file (replaces a template with the same name)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tpl, err := store.LoadTemplateFile(args[0])
+		if err != nil {
+			return err
+		}
+		if templateName != "" {
+			tpl.Name = templateName
+		}
+		if tpl, err = store.SaveTemplate(ctx, db, tpl); err != nil {
+			return err
+		}
+		fmt.Printf("Saved template %q (%d tasks)\n", tpl.Name, len(tpl.Tasks))
+		return nil
+	},
+}
+
+var templateSaveCmd = &cobra.Command{
+	Use:   "save [name]",
+	Short: "Save the tasks of a list as a template",
+	Long:  "Capture the tasks of a list (--project, or the default list) as a template. Due dates become offsets from the earliest due date in the list; statuses are not kept.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(templateWorkspace)
+		if err != nil {
+			return err
+		}
+		var projectID *int64
+		color := ""
+		if templateProject != "" {
+			p, err := findProject(w, templateProject)
+			if err != nil {
+				return err
+			}
+			projectID, color = &p.ID, p.Color
+		}
+		tasks, err := store.ListTasks(ctx, db, w.ID, projectID)
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return fmt.Errorf("the list has no tasks to save")
+		}
+		tpl := store.TemplateFromTasks(args[0], tasks)
+		tpl.Color = color
+		if tpl, err = store.SaveTemplate(ctx, db, tpl); err != nil {
+			return err
+		}
+		fmt.Printf("Saved template %q (%d tasks)\n", tpl.Name, len(tpl.Tasks))
+		return nil
+	},
+}
+
+var templateApplyCmd = &cobra.Command{
+	Use:   "apply [name]",
+	Short: "Add a template's tasks to an existing list",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(templateWorkspace)
+		if err != nil {
+			return err
+		}
+		var projectID *int64
+		if templateProject != "" {
+			p, err := findProject(w, templateProject)
+			if err != nil {
+				return err
+			}
+			projectID = &p.ID
+		}
+		tpl, vars, start, err := templateArgs(args[0])
+		if err != nil {
+			return err
+		}
+		if err := store.ApplyTemplate(ctx, db, w.ID, projectID, tpl, vars, start); err != nil {
+			return err
+		}
+		fmt.Printf("Added %d tasks from template %q\n", len(tpl.Tasks), tpl.Name)
+		return nil
+	},
+}
+
+var templateDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a stored template",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := store.DeleteTemplate(ctx, db, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted template %q\n", args[0])
+		return nil
+	},
+}
+
+// findTemplate loads a stored template by name, or a template file when name looks like a path.
+func findTemplate(name string) (models.Template, error) {
+	if strings.HasSuffix(name, ".json") || strings.ContainsAny(name, `/\`) {
+		return store.LoadTemplateFile(name)
+	}
+	return store.GetTemplate(ctx, db, name)
+}
+
+// templateArgs resolves the template and the --var and --start flags shared by project create and template apply.
+func templateArgs(name string) (models.Template, map[string]string, time.Time, error) {
+	tpl, err := findTemplate(name)
+	if err != nil {
+		return models.Template{}, nil, time.Time{}, err
+	}
+	vars := map[string]string{}
+	for _, s := range templateVars {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return models.Template{}, nil, time.Time{}, fmt.Errorf("--var %q: use name=value", s)
+		}
+		vars[strings.TrimSpace(k)] = v
+	}
+	start := time.Now()
+	if templateStart != "" {
+		d, err := parseDate("--start", templateStart)
+		if err != nil {
+			return models.Template{}, nil, time.Time{}, err
+		}
+		start = *d
+	}
+	return tpl, vars, start, nil
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+
+	templateImportCmd.Flags().StringVar(&templateName, "name", "", "Store under this name (default: name in the file, or the file name)")
+
+	for _, c := range []*cobra.Command{templateSaveCmd, templateApplyCmd} {
+		c.Flags().StringVarP(&templateWorkspace, "workspace", "w", "", "Workspace name (default: current context)")
+		c.Flags().StringVarP(&templateProject, "project", "p", "", "Project/list name (default list if omitted)")
+	}
+	templateApplyCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Template variable as name=value (repeat for several)")
+	templateApplyCmd.Flags().StringVar(&templateStart, "start", "", "Date due offsets count from (YYYY-MM-DD, default: today)")
+
+	templateCmd.AddCommand(templateListCmd, templateShowCmd, templateImportCmd, templateSaveCmd, templateApplyCmd, templateDeleteCmd)
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	timeDate      string
+	timeNote      string
+	timeWorkspace string
+	timeProject   string
+)
+
+var timeCmd = &cobra.Command{
+	Use:   "time",
+	Short: "Log and review time spent on tasks",
+}
+
+var timeAddCmd = &cobra.Command{
+	Use:   "add [task-id] [duration]",
+	Short: "Log time on a task (e.g. 1h30m, 45m)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return fmt.Errorf("duration %q: use e.g. 1h30m or 45m", args[1])
+		}
+		start := time.Now().Add(-d)
+		if timeDate != "" {
+			day, err := parseDate("--date", timeDate)
+			if err != nil {
+				return err
+			}
+			start = time.Date(day.Year(), day.Month(), day.Day(), 9, 0, 0, 0, time.Local)
+		}
+		e, err := store.AddTimeEntry(ctx, db, id, start, d, timeNote)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Logged %s on task %d (entry %d)\n", formatSeconds(e.Seconds), id, e.ID)
+		return nil
+	},
+}
+
+var timeListCmd = &cobra.Command{
+	Use:   "list [task-id]",
+	Short: "List time entries of a task",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		entries, err := store.ListTimeEntries(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("No time logged.")
+			return nil
+		}
+		var total int64
+		for _, e := range entries {
+			running := ""
+			if e.EndedAt == nil {
+				running = "  (running)"
+			}
+			note := ""
+			if e.Note != "" {
+				note = "  " + e.Note
+			}
+			fmt.Printf("  %d  %s  %8s%s%s\n", e.ID, e.StartedAt.Local().Format(dateLayout+" 15:04"), formatSeconds(e.Seconds), note, running)
+			total += e.Seconds
+		}
+		fmt.Printf("  Total: %s\n", formatSeconds(total))
+		return nil
+	},
+}
+
+var timeDeleteCmd = &cobra.Command{
+	Use:   "delete [entry-id]",
+	Short: "Delete a time entry",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var id int64
+		if _, err := fmt.Sscanf(args[0], "%d", &id); err != nil {
+			return fmt.Errorf("entry id must be a number")
+		}
+		if err := store.DeleteTimeEntry(ctx, db, id); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted time entry %d\n", id)
+		return nil
+	},
+}
+
+var timeTotalsCmd = &cobra.Command{
+	Use:   "totals",
+	Short: "Show logged time per project, or per task with --project",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := lookupWorkspace(timeWorkspace)
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("project") {
+			totals, err := store.ProjectTimeTotals(ctx, db, w.ID)
+			if err != nil {
+				return err
+			}
+			var sum int64
+			for _, t := range totals {
+				fmt.Printf("  %8s  %s\n", formatSeconds(t.Seconds), t.Name)
+				sum += t.Seconds
+			}
+			fmt.Printf("  %8s  Total (%s)\n", formatSeconds(sum), w.Name)
+			return nil
+		}
+		// --project "" selects the default list, like task list without --project.
+		var projectID *int64
+		if timeProject != "" {
+			p, err := findProject(w, timeProject)
+			if err != nil {
+				return err
+			}
+			projectID = &p.ID
+		}
+		tasks, err := store.ListTasks(ctx, db, w.ID, projectID)
+		if err != nil {
+			return err
+		}
+		totals, err := store.TaskTimeTotals(ctx, db, w.ID, projectID)
+		if err != nil {
+			return err
+		}
+		var sum int64
+		for _, t := range tasks {
+			if totals[t.ID] == 0 {
+				continue
+			}
+			fmt.Printf("  %8s  %d  %s\n", formatSeconds(totals[t.ID]), t.ID, t.Title)
+			sum += totals[t.ID]
+		}
+		fmt.Printf("  %8s  Total\n", formatSeconds(sum))
+		return nil
+	},
+}
+
+// formatSeconds renders a duration as e.g. "1h30m", "45m" or "20s".
+func formatSeconds(s int64) string {
+	h, m := s/3600, s%3600/60
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	case m > 0:
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%ds", s)
+}
+
+func init() {
+	rootCmd.AddCommand(timeCmd)
+
+	timeAddCmd.Flags().StringVar(&timeDate, "date", "", "Day the work was done (YYYY-MM-DD, default: ending now)")
+	timeAddCmd.Flags().StringVar(&timeNote, "note", "", "What the time was spent on")
+
+	timeTotalsCmd.Flags().StringVarP(&timeWorkspace, "workspace", "w", "", "Workspace name (default: current context)")
+	timeTotalsCmd.Flags().StringVarP(&timeProject, "project", "p", "", "Project/list name: show per-task totals (empty = default list)")
+
+	timeCmd.AddCommand(timeAddCmd, timeListCmd, timeDeleteCmd, timeTotalsCmd)
+}
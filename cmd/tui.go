@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/cli-todo/internal/config"
+	"github.com/cli-todo/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+// runTUI starts the interactive TUI. It opens on TODO_WORKSPACE (with TODO_PROJECT), else the .todo.yaml's
+// workspace and list, else the config file's workspace.
+func runTUI(cmd *cobra.Command, args []string) error {
+	opts := tui.Options{
+		Theme:      cfg.Get("theme"),
+		Keys:       cfg.Bindings(),
+		DateLayout: dateLayout,
+		Profile:    activeProfile,
+		Profiles:   cfg.Profiles(),
+	}
+	workspace, source := cfg.Lookup("workspace")
+	switch {
+	case source == config.SourceEnv:
+		opts.Workspace, opts.Project = workspace, os.Getenv("TODO_PROJECT")
+	case marker != nil && marker.Workspace != "":
+		opts.Workspace, opts.Project = marker.Workspace, marker.Project
+	default:
+		opts.Workspace = workspace
+	}
+	if marker != nil {
+		opts.Tags = marker.Tags
+	}
+	return tui.Run(ctx, db, opts)
+}
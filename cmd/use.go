@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var useClear bool
+
+var useCmd = &cobra.Command{
+	Use:   "use [workspace[/project]]",
+	Short: "Set the current workspace (and list) used when --workspace is omitted",
+	Long: `Set the current context: task commands and "todo add" use it when --workspace is not given.
+  todo use work/backend   # workspace work, list backend
+  todo use personal       # workspace personal, default list
+  todo use                # show the current context
+  todo use --clear
+A .todo.yaml in the working directory or above (see README) overrides the saved context,
+and TODO_WORKSPACE and TODO_PROJECT override both (e.g. per shell).`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if useClear {
+			if err := store.SetSetting(ctx, db, store.SettingWorkspace, ""); err != nil {
+				return err
+			}
+			if err := store.SetSetting(ctx, db, store.SettingProject, ""); err != nil {
+				return err
+			}
+			fmt.Println("Cleared the current context")
+			return nil
+		}
+		if len(args) == 0 {
+			ws, project, source, err := contextNames()
+			if err != nil {
+				return err
+			}
+			if ws == "" {
+				fmt.Println("No current context (set one with: todo use WORKSPACE[/PROJECT])")
+				return nil
+			}
+			fmt.Printf("  %s  (%s)\n", contextLabel(ws, project), source)
+			return nil
+		}
+		name, project, _ := strings.Cut(args[0], "/")
+		w, err := store.GetWorkspaceByName(ctx, db, name)
+		if err != nil {
+			return err
+		}
+		if project != "" {
+			p, err := findProject(w, project)
+			if err != nil {
+				return err
+			}
+			project = p.Name
+		}
+		if err := store.SetSetting(ctx, db, store.SettingWorkspace, w.Name); err != nil {
+			return err
+		}
+		if err := store.SetSetting(ctx, db, store.SettingProject, project); err != nil {
+			return err
+		}
+		fmt.Printf("Now using %s\n", contextLabel(w.Name, project))
+		return nil
+	},
+}
+
+func contextLabel(workspace, project string) string {
+	if project == "" {
+		return workspace + " (default list)"
+	}
+	return workspace + "/" + project
+}
+
+// contextNames returns the current workspace and project names and where they come from:
+// TODO_WORKSPACE/TODO_PROJECT, a .todo.yaml above the working directory, the context saved by "todo use",
+// or the config file's workspace.
+// TODO_PROJECT alone only replaces the list.
+func contextNames() (workspace, project, source string, err error) {
+	if ws := os.Getenv("TODO_WORKSPACE"); ws != "" {
+		return ws, os.Getenv("TODO_PROJECT"), "from TODO_WORKSPACE", nil
+	}
+	if marker != nil && marker.Workspace != "" {
+		workspace, project, source = marker.Workspace, marker.Project, "from "+marker.Path
+	} else {
+		if workspace, err = store.GetSetting(ctx, db, store.SettingWorkspace); err != nil {
+			return "", "", "", err
+		}
+		if workspace == "" {
+			if workspace = cfg.Get("workspace"); workspace == "" {
+				return "", "", "", nil
+			}
+			source = "from " + cfg.Path
+		} else {
+			if project, err = store.GetSetting(ctx, db, store.SettingProject); err != nil {
+				return "", "", "", err
+			}
+			source = "saved by todo use"
+		}
+	}
+	if p := os.Getenv("TODO_PROJECT"); p != "" {
+		return workspace, p, source + ", list from TODO_PROJECT", nil
+	}
+	return workspace, project, source, nil
+}
+
+// lookupWorkspace returns the named workspace, or the current context's when name is empty.
+func lookupWorkspace(name string) (models.Workspace, error) {
+	if name == "" {
+		ws, _, _, err := contextNames()
+		if err != nil {
+			return models.Workspace{}, err
+		}
+		if ws == "" {
+			return models.Workspace{}, fmt.Errorf("no workspace: pass --workspace, set TODO_WORKSPACE, add a .todo.yaml or run: todo use WORKSPACE")
+		}
+		name = ws
+	}
+	w, err := store.GetWorkspaceByName(ctx, db, name)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	return w, nil
+}
+
+// taskScope resolves the workspace and list of task commands: --workspace and --project when given,
+// otherwise the current context. The context's list only applies when the workspace comes from it too.
+// useContextProject=false leaves the list to the caller when --project is not given (e.g. for #list markup).
+func taskScope(useContextProject bool) (models.Workspace, *int64, error) {
+	project := ""
+	if taskWorkspace == "" {
+		_, p, _, err := contextNames()
+		if err != nil {
+			return models.Workspace{}, nil, err
+		}
+		project = p
+	}
+	w, err := lookupWorkspace(taskWorkspace)
+	if err != nil {
+		return models.Workspace{}, nil, err
+	}
+	if taskProject != "" {
+		project = taskProject
+	} else if !useContextProject {
+		project = ""
+	}
+	if project == "" {
+		return w, nil, nil
+	}
+	p, err := findProject(w, project)
+	if err != nil {
+		return models.Workspace{}, nil, err
+	}
+	return w, &p.ID, nil
+}
+
+func init() {
+	rootCmd.AddCommand(useCmd)
+	useCmd.Flags().BoolVar(&useClear, "clear", false, "Forget the current context")
+}
@@ -17,9 +17,8 @@ var workspaceCreateCmd = &cobra.Command{
 	Short: "Create a workspace",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		w, err := store.CreateWorkspace(db, args[0])
+		w, err := store.CreateWorkspace(ctx, db, args[0])
 		if err != nil {
-			fmt.Printf("Error %s", err.Error())
 			return err
 		}
 		fmt.Printf("Created workspace %q (id %d)\n", w.Name, w.ID)
@@ -31,7 +30,7 @@ var workspaceListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all workspaces",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		list, err := store.ListWorkspaces(db)
+		list, err := store.ListWorkspaces(ctx, db)
 		if err != nil {
 			return err
 		}
@@ -51,11 +50,14 @@ var workspaceDeleteCmd = &cobra.Command{
 	Short: "Delete a workspace and all its projects and tasks",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		w, err := store.GetWorkspaceByName(db, args[0])
+		w, err := store.GetWorkspaceByName(ctx, db, args[0])
 		if err != nil {
-			return fmt.Errorf("workspace %q: %w", args[0], err)
+			return err
+		}
+		if err := store.SnapshotBefore(ctx, db, "workspace-delete"); err != nil {
+			return err
 		}
-		if err := store.DeleteWorkspace(db, w.ID); err != nil {
+		if err := store.DeleteWorkspace(ctx, db, w.ID); err != nil {
 			return err
 		}
 		fmt.Printf("Deleted workspace %q\n", w.Name)
@@ -0,0 +1,380 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Source tells where a setting's value comes from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "config"
+	SourceEnv     Source = "env"
+)
+
+// Key describes a setting of the config file.
+type Key struct {
+	Name    string
+	Default string
+	Help    string
+	check   func(string) error
+}
+
+// Env is the environment variable overriding the key: TODO_ and the name in upper case, dots as underscores.
+func (k Key) Env() string {
+	return "TODO_" + strings.ToUpper(strings.ReplaceAll(k.Name, ".", "_"))
+}
+
+// KeyBindings are the TUI actions that can be rebound with keys.<action>, and their default keys.
+var KeyBindings = map[string]string{
+	"quit":     "q",
+	"add":      "a",
+	"edit":     "e",
+	"delete":   "d",
+	"search":   "ctrl+f",
+	"color":    "c",
+	"status":   "s",
+	"priority": "p",
+	"due":      "u",
+	"move":     "m",
+	"timer":    "t",
+	"estimate": "E",
+	"note":     "n",
+	"open":     "o",
+	"profile":  "P",
+}
+
+// reservedKeys are used for navigation and text entry and can't be bound to an action.
+var reservedKeys = map[string]bool{
+	"up": true, "down": true, "left": true, "right": true, "j": true, "k": true,
+	"enter": true, "esc": true, "tab": true, "backspace": true, "ctrl+h": true, "ctrl+c": true, "ctrl+s": true,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// Keys lists the settings in display order; keys.<action> entries follow the fixed ones.
+var Keys = func() []Key {
+	keys := []Key{
+		{Name: "db", Help: "database path (default: todo.db next to the executable)"},
+		{Name: "profile", Help: "profile used when neither --profile nor TODO_PROFILE is given (see todo profile)"},
+		{Name: "workspace", Help: "workspace used when no flag, TODO_WORKSPACE, .todo.yaml or todo use picks one"},
+		{Name: "date_format", Default: "YYYY-MM-DD", Help: "how dates are shown and typed: YYYY, MM and DD with any separators", check: checkDateFormat},
+		{Name: "week_start", Default: "monday", Help: "first day of the week in time reports", check: checkWeekday},
+		{Name: "theme", Default: "dark", Help: "TUI colors: dark, light or mono", check: oneOf("dark", "light", "mono")},
+		{Name: "sync_with", Help: "database file or change-file directory todo sync uses without --with"},
+		{Name: "output", Default: "table", Help: "default --format of task list, report time and search: table or json", check: oneOf("table", "json")},
+		{Name: "snapshots", Default: "10", Help: "automatic snapshots kept per database, taken before migrations, imports and deletes (0 = off)", check: checkCount},
+	}
+	for _, a := range sortedActions() {
+		keys = append(keys, Key{Name: "keys." + a, Default: KeyBindings[a], Help: "TUI key for " + a, check: checkBinding})
+	}
+	return keys
+}()
+
+// LookupKey finds a setting by name.
+func LookupKey(name string) (Key, bool) {
+	for _, k := range Keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Config is the content of the config file.
+type Config struct {
+	Path   string
+	values map[string]string
+}
+
+// Path returns the config file location: $TODO_CONFIG, else cli-todo/config.yaml in the user config
+// directory ($XDG_CONFIG_HOME or ~/.config on Linux, %AppData% on Windows, ~/Library/Application Support on macOS).
+func Path() (string, error) {
+	if p := os.Getenv("TODO_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("config dir: %w", err)
+	}
+	return filepath.Join(dir, "cli-todo", "config.yaml"), nil
+}
+
+// Load reads the config file. A missing file is an empty config.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{Path: path, values: map[string]string{}}
+	for _, k := range Keys {
+		if v := os.Getenv(k.Env()); v != "" && k.check != nil {
+			if err := k.check(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", k.Env(), err)
+			}
+		}
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, c.checkBindings()
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name := range values {
+		if profile, ok := strings.CutPrefix(name, profilePrefix); ok {
+			v, err := stringValue(values, name)
+			if err == nil {
+				err = checkProfileName(profile)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+			c.values[name] = v
+			continue
+		}
+		k, ok := LookupKey(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown setting %q (see todo config list)", path, name)
+		}
+		v, err := stringValue(values, name)
+		if err == nil && k.check != nil && v != "" {
+			err = k.check(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+		c.values[name] = v
+	}
+	if err := c.checkBindings(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Lookup returns the value of a setting and where it comes from: the environment, the config file or the default.
+func (c *Config) Lookup(name string) (string, Source) {
+	k, ok := LookupKey(name)
+	if !ok {
+		return "", SourceDefault
+	}
+	if v := os.Getenv(k.Env()); v != "" {
+		return v, SourceEnv
+	}
+	if v := c.values[name]; v != "" {
+		return v, SourceFile
+	}
+	return k.Default, SourceDefault
+}
+
+// Saved returns the value of a setting in the config file, ignoring the environment and defaults.
+func (c *Config) Saved(name string) string {
+	return c.values[name]
+}
+
+// Get returns the value of a setting.
+func (c *Config) Get(name string) string {
+	v, _ := c.Lookup(name)
+	return v
+}
+
+// Set validates and saves a setting; an empty value removes it from the file. Comments and the
+// order of the other lines are kept.
+func (c *Config) Set(name, value string) error {
+	k, ok := LookupKey(name)
+	if !ok {
+		return fmt.Errorf("unknown setting %q (see todo config list)", name)
+	}
+	if value != "" && k.check != nil {
+		if err := k.check(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if name == "profile" && value != "" {
+		if _, ok := c.Profiles()[value]; !ok {
+			return fmt.Errorf("no profile %q (see todo profile list)", value)
+		}
+	}
+	old := c.values[name]
+	c.values[name] = value
+	if err := c.checkBindings(); err != nil {
+		c.values[name] = old
+		return err
+	}
+	return c.save(name, value)
+}
+
+// save writes one key of the config file.
+func (c *Config) save(name, value string) error {
+	c.values[name] = value
+	data, err := os.ReadFile(c.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.Path, []byte(setYAMLKey(string(data), name, value)), 0o644)
+}
+
+// DB returns the db setting with a leading ~/ expanded to the home directory ("" = default location).
+func (c *Config) DB() (string, Source) {
+	path, source := c.Lookup("db")
+	return ExpandHome(path), source
+}
+
+// dirPrefix marks a directory store ("dir:PATH", see store.Open); the path after it is expanded and
+// made absolute like a database file's.
+const dirPrefix = "dir:"
+
+// cutDirPrefix splits a database path into its dir: prefix, if any, and the path.
+func cutDirPrefix(path string) (prefix, rest string) {
+	if rest, ok := strings.CutPrefix(path, dirPrefix); ok {
+		return dirPrefix, rest
+	}
+	return "", path
+}
+
+// ExpandHome replaces a leading ~/ with the user's home directory (after dir: for a directory store).
+func ExpandHome(path string) string {
+	if prefix, rest := cutDirPrefix(path); prefix != "" {
+		return prefix + ExpandHome(rest)
+	}
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// DateLayout returns date_format as a Go time layout.
+func (c *Config) DateLayout() string {
+	return dateLayout(c.Get("date_format"))
+}
+
+// WeekStart returns week_start as a weekday.
+func (c *Config) WeekStart() time.Weekday {
+	return weekdays[fullWeekday(c.Get("week_start"))]
+}
+
+// Snapshots returns how many automatic snapshots to keep.
+func (c *Config) Snapshots() int {
+	n, _ := strconv.Atoi(c.Get("snapshots"))
+	return n
+}
+
+// Bindings returns the TUI key of every action in KeyBindings.
+func (c *Config) Bindings() map[string]string {
+	b := map[string]string{}
+	for action := range KeyBindings {
+		b[action] = c.Get("keys." + action)
+	}
+	return b
+}
+
+// checkBindings rejects two actions on the same key.
+func (c *Config) checkBindings() error {
+	seen := map[string]string{}
+	for _, action := range sortedActions() {
+		key := c.Get("keys." + action)
+		if other, dup := seen[key]; dup {
+			return fmt.Errorf("keys.%s and keys.%s are both bound to %q", other, action, key)
+		}
+		seen[key] = action
+	}
+	return nil
+}
+
+func sortedActions() []string {
+	actions := make([]string, 0, len(KeyBindings))
+	for a := range KeyBindings {
+		actions = append(actions, a)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(s string) error {
+		for _, v := range values {
+			if s == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+func checkCount(s string) error {
+	if n, err := strconv.Atoi(s); err != nil || n < 0 {
+		return fmt.Errorf("%q is not a number of 0 or more", s)
+	}
+	return nil
+}
+
+func checkBinding(s string) error {
+	if reservedKeys[s] {
+		return fmt.Errorf("%q is reserved for navigation", s)
+	}
+	return nil
+}
+
+func fullWeekday(s string) string {
+	s = strings.ToLower(s)
+	for name := range weekdays {
+		if len(s) >= 3 && strings.HasPrefix(name, s) {
+			return name
+		}
+	}
+	return ""
+}
+
+func checkWeekday(s string) error {
+	if fullWeekday(s) == "" {
+		return fmt.Errorf("%q is not a weekday", s)
+	}
+	return nil
+}
+
+// dateLayout turns YYYY, MM and DD into a Go layout.
+func dateLayout(format string) string {
+	return strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02").Replace(format)
+}
+
+func checkDateFormat(s string) error {
+	for _, token := range []string{"YYYY", "MM", "DD"} {
+		if strings.Count(s, token) != 1 {
+			return fmt.Errorf("%q needs YYYY, MM and DD once each", s)
+		}
+	}
+	if strings.ContainsFunc(strings.NewReplacer("YYYY", "", "MM", "", "DD", "").Replace(s), unicode.IsLetter) {
+		return fmt.Errorf("%q: only YYYY, MM, DD and separators are allowed", s)
+	}
+	layout := dateLayout(s)
+	day := time.Date(2024, 11, 23, 0, 0, 0, 0, time.UTC)
+	if parsed, err := time.Parse(layout, day.Format(layout)); err != nil || !parsed.Equal(day) {
+		return fmt.Errorf("%q can't be read back; separate YYYY, MM and DD with punctuation", s)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package config reads todo's configuration files.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// MarkerNames are the file names of a directory marker, looked up in this order.
+var MarkerNames = []string{".todo.yaml", ".todo.yml"}
+
+// Marker pins a directory tree (e.g. a git repository) to a workspace and list:
+//
+//	workspace: work
+//	project: backend
+//	tags: [backend, repo]
+//	db: ../todo.db
+//
+// Every field is optional; profile names a profile of the config file instead of db.
+type Marker struct {
+	Path      string   // the marker file
+	Workspace string   // workspace used when --workspace is not given
+	Project   string   // list used together with Workspace
+	Tags      []string // added to tasks created in Workspace
+	DB        string   // database path, absolute (~/ is the home directory, other relative paths start at the marker's directory)
+	Profile   string   // profile whose database to use
+}
+
+// FindMarker walks up from dir to the filesystem root and loads the first marker file found.
+// It returns nil when there is none.
+func FindMarker(dir string) (*Marker, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		for _, name := range MarkerNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return LoadMarker(path)
+			} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadMarker reads a marker file. Unknown keys are an error so typos don't go unnoticed.
+func LoadMarker(path string) (*Marker, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m := &Marker{Path: path}
+	for key := range values {
+		switch key {
+		case "workspace":
+			m.Workspace, err = stringValue(values, key)
+		case "project":
+			m.Project, err = stringValue(values, key)
+		case "db":
+			m.DB, err = stringValue(values, key)
+		case "profile":
+			m.Profile, err = stringValue(values, key)
+		case "tags":
+			m.Tags = listValue(values, key)
+		default:
+			err = fmt.Errorf("unknown key %q (use workspace, project, tags, db or profile)", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if m.Project != "" && m.Workspace == "" {
+		return nil, fmt.Errorf("%s: project needs a workspace", path)
+	}
+	if m.DB != "" && m.Profile != "" {
+		return nil, fmt.Errorf("%s: set either db or profile", path)
+	}
+	prefix, db := cutDirPrefix(ExpandHome(m.DB))
+	if db != "" && !filepath.IsAbs(db) {
+		m.DB = prefix + filepath.Join(filepath.Dir(path), db)
+	} else {
+		m.DB = prefix + db
+	}
+	return m, nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// profilePrefix starts the config keys of profiles: "profiles.team: /srv/team/todo.db".
+const profilePrefix = "profiles."
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func checkProfileName(name string) error {
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("profile name %q: use letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// Profiles returns the database path of every profile by name.
+func (c *Config) Profiles() map[string]string {
+	profiles := map[string]string{}
+	for key, path := range c.values {
+		if name, ok := strings.CutPrefix(key, profilePrefix); ok && path != "" {
+			profiles[name] = ExpandHome(path)
+		}
+	}
+	return profiles
+}
+
+// ProfileNames returns the profile names in order.
+func (c *Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddProfile saves a profile. Relative paths are made absolute so the profile works from any directory.
+func (c *Config) AddProfile(name, path string) error {
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	if _, ok := c.Profiles()[name]; ok {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	if path == "" {
+		return fmt.Errorf("profile %q needs a database path", name)
+	}
+	prefix, path := cutDirPrefix(ExpandHome(path))
+	if !filepath.IsAbs(path) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = abs
+	}
+	return c.save(profilePrefix+name, prefix+path)
+}
+
+// RemoveProfile deletes a profile, and the default profile setting when it pointed to it.
+func (c *Config) RemoveProfile(name string) error {
+	if _, ok := c.Profiles()[name]; !ok {
+		return fmt.Errorf("no profile %q", name)
+	}
+	if err := c.save(profilePrefix+name, ""); err != nil {
+		return err
+	}
+	if c.values["profile"] == name {
+		return c.save("profile", "")
+	}
+	return nil
+}
+
+// ProfileDB returns the database path of a profile.
+func (c *Config) ProfileDB(name string) (string, error) {
+	path, ok := c.Profiles()[name]
+	if !ok {
+		return "", fmt.Errorf("no profile %q (see todo profile list)", name)
+	}
+	return path, nil
+}
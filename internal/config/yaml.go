@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseYAML reads the flat subset of YAML used by todo's config files: "key: value" pairs, lists written
+// inline ([a, b]) or as "- item" lines under "key:", quoted strings and # comments. Values are string
+// or []string; nesting is rejected.
+func parseYAML(data string) (map[string]any, error) {
+	out := map[string]any{}
+	listKey := "" // key whose "- item" lines follow
+	for i, line := range strings.Split(data, "\n") {
+		n := i + 1
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if listKey == "" || line == trimmed {
+				return nil, fmt.Errorf("line %d: list item outside a list", n)
+			}
+			item, err := unquote(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			out[listKey] = append(out[listKey].([]string), item)
+			continue
+		}
+		if line != trimmed {
+			return nil, fmt.Errorf("line %d: nested values are not supported", n)
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected key: value", n)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", n, key)
+		}
+		value = strings.TrimSpace(value)
+		listKey = ""
+		switch {
+		case value == "":
+			out[key] = []string{}
+			listKey = key
+		case strings.HasPrefix(value, "["):
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("line %d: unterminated list", n)
+			}
+			items := []string{}
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				s, err := unquote(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				items = append(items, s)
+			}
+			out[key] = items
+		default:
+			s, err := unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			out[key] = s
+		}
+	}
+	return out, nil
+}
+
+// setYAMLKey sets the top-level key of data to value, replacing its line in place or appending it.
+// An empty value removes the line.
+func setYAMLKey(data, key, value string) string {
+	var lines []string
+	if data != "" {
+		lines = strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	}
+	line := key + ": " + quoteYAML(value)
+	found := false
+	for i := 0; i < len(lines); i++ {
+		k, _, ok := strings.Cut(lines[i], ":")
+		if !ok || strings.TrimRight(k, " ") != key {
+			continue
+		}
+		found = true
+		if value == "" {
+			lines = append(lines[:i], lines[i+1:]...)
+			i--
+		} else {
+			lines[i] = line
+		}
+	}
+	if !found && value != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// quoteYAML quotes values parseYAML would otherwise read differently.
+func quoteYAML(s string) string {
+	if s == strings.TrimSpace(s) && !strings.ContainsAny(s, "#:\"'") && !strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "-") {
+		return s
+	}
+	if strings.Contains(s, `"`) {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
+
+// stripComment drops a # comment that starts the line or follows whitespace, outside quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(s string) (string, error) {
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		if len(s) < 2 || s[len(s)-1] != s[0] {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+	return s, nil
+}
+
+// stringValue returns a scalar value; a list is an error.
+func stringValue(values map[string]any, key string) (string, error) {
+	switch v := values[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("%s must be a single value", key)
+}
+
+// listValue returns a list value; a scalar is split on commas.
+func listValue(values map[string]any, key string) []string {
+	switch v := values[key].(type) {
+	case []string:
+		return v
+	case string:
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return nil
+}
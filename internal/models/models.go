@@ -1,9 +1,13 @@
 package models
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 type Workspace struct {
 	ID        int64     `json:"id"`
+	UUID      string    `json:"uuid"` // stable across databases, used by export/import
 	Name      string    `json:"name"`
 	Color     string    `json:"color,omitempty"` // e.g. "green", "blue", "#ff0000"
 	CreatedAt time.Time `json:"created_at"`
@@ -11,21 +15,218 @@ type Workspace struct {
 
 type Project struct {
 	ID          int64     `json:"id"`
+	UUID        string    `json:"uuid"`
 	WorkspaceID int64     `json:"workspace_id"`
 	Name        string    `json:"name"`
 	Color       string    `json:"color,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// Priority is a task's priority; the empty value means none.
+type Priority string
+
+const (
+	PriorityNone   Priority = ""
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+)
+
+// Priorities lists the priorities a task can have, lowest first.
+var Priorities = []Priority{PriorityLow, PriorityMedium, PriorityHigh}
+
+// Valid reports whether p is none or one of Priorities.
+func (p Priority) Valid() bool {
+	return p == PriorityNone || slices.Contains(Priorities, p)
+}
+
 type Task struct {
 	ID          int64      `json:"id"`
+	UUID        string     `json:"uuid"` // stable across databases; a unique prefix of it works as a task ID
 	WorkspaceID int64      `json:"workspace_id"`
 	ProjectID   *int64     `json:"project_id,omitempty"`
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
-	Status      string     `json:"status"` // todo, in_progress, done
-	Priority    string     `json:"priority,omitempty"` // low, medium, high
+	Status      string     `json:"status"` // name of a status in the workspace workflow
+	Priority    Priority   `json:"priority,omitempty"`
 	DueDate     *time.Time `json:"due_date,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	// Estimate is either a duration in minutes or story points (at most one is set).
+	EstimateMinutes *int64   `json:"estimate_minutes,omitempty"`
+	EstimatePoints  *float64 `json:"estimate_points,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Blocked         bool     `json:"blocked"` // derived: depends on a task that is not done
+	// Fields holds custom field values by name: string (text, date, enum), float64 (number) or bool.
+	Fields    map[string]any `json:"fields,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Status is one step of a workspace's workflow (e.g. todo, review, blocked, done).
+type Status struct {
+	ID          int64     `json:"id"`
+	WorkspaceID int64     `json:"workspace_id"`
+	Name        string    `json:"name"`
+	Position    int       `json:"position"`
+	Color       string    `json:"color,omitempty"`
+	IsDone      bool      `json:"is_done"` // tasks in this status count as done
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TimeEntry is work logged on a task. A running timer has no EndedAt.
+type TimeEntry struct {
+	ID        int64      `json:"id"`
+	TaskID    int64      `json:"task_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Seconds   int64      `json:"seconds"` // logged duration; for a running timer, elapsed so far
+	Note      string     `json:"note,omitempty"`
+}
+
+// TimeTotal is the logged time of one project/list (ProjectID nil = default list).
+type TimeTotal struct {
+	ProjectID *int64 `json:"project_id,omitempty"`
+	Name      string `json:"name"`
+	Seconds   int64  `json:"seconds"`
+}
+
+// TimeReportRow is logged time for one group (workspace, project, tag or day) on one local day.
+type TimeReportRow struct {
+	Group   string `json:"group"`
+	Day     string `json:"day"` // YYYY-MM-DD, local time
+	Seconds int64  `json:"seconds"`
+}
+
+// EstimateTotal sums the estimates of the open (not done) tasks of one project/list.
+type EstimateTotal struct {
+	ProjectID *int64  `json:"project_id,omitempty"`
+	Name      string  `json:"name"`
+	OpenTasks int     `json:"open_tasks"`
+	Minutes   int64   `json:"minutes"`
+	Points    float64 `json:"points"`
+	// LoggedSeconds is the time logged on open tasks that have a duration estimate.
+	LoggedSeconds int64 `json:"logged_seconds"`
+}
+
+// SearchHit is a task matching a full-text search, with its location and highlighted text.
+type SearchHit struct {
+	Task          Task    `json:"task"`
+	WorkspaceName string  `json:"workspace"`
+	ProjectName   string  `json:"project,omitempty"` // empty = default list
+	Title         string  `json:"title_highlighted"`
+	Snippet       string  `json:"snippet,omitempty"` // matching part of the description
+	Rank          float64 `json:"rank"`              // bm25; lower is better
+}
+
+// Note is an append-only comment on a task.
+type Note struct {
+	ID        int64     `json:"id"`
+	TaskID    int64     `json:"task_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Attachment is a URL, path or file linked to a task. Target is the URL, the referenced
+// path, or (kind "file") the file name in the attachments directory; it is empty for blobs.
+type Attachment struct {
+	ID        int64     `json:"id"`
+	TaskID    int64     `json:"task_id"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Target    string    `json:"target,omitempty"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CustomField is a typed metadata field defined for the tasks of one workspace.
+type CustomField struct {
+	ID          int64     `json:"id"`
+	WorkspaceID int64     `json:"workspace_id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`              // text, number, date, enum, bool
+	Options     []string  `json:"options,omitempty"` // allowed values of an enum
+	Position    int       `json:"position"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Template is a reusable project checklist. Text fields may contain {{variables}}.
+type Template struct {
+	ID          int64          `json:"-"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Color       string         `json:"color,omitempty"` // color of projects created from it
+	Tasks       []TemplateTask `json:"tasks"`
+	CreatedAt   time.Time      `json:"-"`
+}
+
+// TemplateTask is one task of a template. Due is an offset from the start date ("+3d", "+1w", "-2d")
+// or a fixed date (YYYY-MM-DD); Estimate uses the task estimate syntax ("1h30m", "3pt").
+type TemplateTask struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	Priority    Priority `json:"priority,omitempty"`
+	Due         string   `json:"due,omitempty"`
+	Estimate    string   `json:"estimate,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Export is the file written by "todo export" and read by "todo import". Workspaces, projects and
+// tasks carry their UUIDs so an import recognizes what it already has instead of duplicating it.
+type Export struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Workspaces []ExportWorkspace `json:"workspaces"`
+}
+
+// ExportWorkspace is a workspace with its workflow, custom fields, projects and tasks.
+type ExportWorkspace struct {
+	UUID     string          `json:"uuid"`
+	Name     string          `json:"name"`
+	Color    string          `json:"color,omitempty"`
+	Statuses []ExportStatus  `json:"statuses"`
+	Fields   []ExportField   `json:"fields,omitempty"`
+	Projects []ExportProject `json:"projects,omitempty"`
+	Tasks    []ExportTask    `json:"tasks,omitempty"`
+}
+
+type ExportStatus struct {
+	Name   string `json:"name"`
+	Color  string `json:"color,omitempty"`
+	IsDone bool   `json:"is_done,omitempty"`
+}
+
+type ExportField struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"`
+}
+
+type ExportProject struct {
+	UUID  string `json:"uuid"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// ExportTask is a task; Project and DependsOn refer to UUIDs (Project "" = default list).
+type ExportTask struct {
+	UUID            string         `json:"uuid"`
+	Project         string         `json:"project,omitempty"`
+	Title           string         `json:"title"`
+	Description     string         `json:"description,omitempty"`
+	Status          string         `json:"status"`
+	Priority        Priority       `json:"priority,omitempty"`
+	DueDate         *time.Time     `json:"due_date,omitempty"`
+	EstimateMinutes *int64         `json:"estimate_minutes,omitempty"`
+	EstimatePoints  *float64       `json:"estimate_points,omitempty"`
+	Tags            []string       `json:"tags,omitempty"`
+	Fields          map[string]any `json:"fields,omitempty"`
+	DependsOn       []string       `json:"depends_on,omitempty"`
+	Notes           []ExportNote   `json:"notes,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+type ExportNote struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,133 @@
+// Package quickadd parses the one-line task syntax shared by the TUI and "todo task create":
+//
+//	Buy milk #groceries @errands !high ^tomorrow ~30m
+//
+// #list picks the project/list, @tag adds a tag, !priority sets the priority, ^date the due date and
+// ~estimate the estimate. Everything else is the title. A leading backslash keeps a word literal
+// (\#5 stays "#5"); "#" followed only by digits (issue numbers like #123) is always literal.
+package quickadd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+)
+
+// Task is the result of parsing a quick-add line. Empty fields were not given.
+type Task struct {
+	Title    string
+	Project  string // list name as typed; match it with MatchProject
+	Tags     []string
+	Priority models.Priority
+	Due      *time.Time
+	Estimate string // as typed, valid for store.ParseEstimate
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Parse splits line into title and markup. Later markup wins over earlier (except tags, which add up).
+// now is used for relative due dates.
+func Parse(line string, now time.Time) (Task, error) {
+	var t Task
+	var title []string
+	for _, word := range strings.Fields(line) {
+		if strings.HasPrefix(word, `\`) && len(word) > 1 {
+			title = append(title, word[1:])
+			continue
+		}
+		if len(word) < 2 {
+			title = append(title, word)
+			continue
+		}
+		value := word[1:]
+		switch word[0] {
+		case '#':
+			if strings.Trim(value, "0123456789") == "" {
+				title = append(title, word)
+				continue
+			}
+			t.Project = value
+		case '@':
+			if strings.Contains(value, ",") {
+				return Task{}, fmt.Errorf("tag %q must not contain a comma", value)
+			}
+			t.Tags = append(t.Tags, value)
+		case '!':
+			p, err := store.ParsePriority(value)
+			if err != nil || p == models.PriorityNone {
+				return Task{}, fmt.Errorf("unknown priority %q (use !low, !medium or !high)", word)
+			}
+			t.Priority = p
+		case '^':
+			due, err := ParseDue(value, now)
+			if err != nil {
+				return Task{}, err
+			}
+			t.Due = due
+		case '~':
+			if _, _, err := store.ParseEstimate(value); err != nil {
+				return Task{}, err
+			}
+			t.Estimate = value
+		default:
+			title = append(title, word)
+		}
+	}
+	t.Title = strings.Join(title, " ")
+	if t.Title == "" {
+		return Task{}, fmt.Errorf("title is empty")
+	}
+	return t, nil
+}
+
+// ParseDue understands today, tomorrow (tom), a weekday (the next one, never today), an offset
+// like +3d or 2w, and YYYY-MM-DD.
+func ParseDue(s string, now time.Time) (*time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	s = strings.ToLower(s)
+	switch s {
+	case "today", "tod":
+		return &today, nil
+	case "tomorrow", "tom":
+		d := today.AddDate(0, 0, 1)
+		return &d, nil
+	}
+	if wd, ok := weekdays[s]; ok {
+		days := (int(wd) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		d := today.AddDate(0, 0, days)
+		return &d, nil
+	}
+	d, err := store.ParseDueOffset(s, now)
+	if err != nil {
+		return nil, fmt.Errorf("due ^%s: use today, tomorrow, a weekday, +3d, +2w or YYYY-MM-DD", s)
+	}
+	return d, nil
+}
+
+// MatchProject finds the list a #name refers to: case-insensitive, with - and _ standing for spaces
+// ("#books-to-read" matches "Books to read").
+func MatchProject(name string, projects []models.Project) (models.Project, bool) {
+	norm := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("-", " ", "_", " ").Replace(s))
+	}
+	for _, p := range projects {
+		if norm(p.Name) == norm(name) {
+			return p, true
+		}
+	}
+	return models.Project{}, false
+}
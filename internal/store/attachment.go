@@ -0,0 +1,269 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// Attachment kinds.
+const (
+	AttachURL  = "url"  // a link, opened as is
+	AttachPath = "path" // a reference to a local file that stays where it is
+	AttachBlob = "blob" // a copy stored in the database
+	AttachFile = "file" // a copy stored in the attachments directory next to the database
+)
+
+// Size limits for copied attachments. Blobs make the database itself grow, so they are kept small.
+var (
+	MaxBlobBytes int64 = 1 << 20
+	MaxFileBytes int64 = 50 << 20
+)
+
+const attachmentColumns = "id, task_id, kind, name, COALESCE(target, ''), size, created_at"
+
+func scanAttachment(row interface{ Scan(...any) error }) (models.Attachment, error) {
+	var a models.Attachment
+	err := row.Scan(&a.ID, &a.TaskID, &a.Kind, &a.Name, &a.Target, &a.Size, &a.CreatedAt)
+	return a, err
+}
+
+// AddAttachment links target to a task. For AttachURL target is the URL; for the other kinds
+// it is a local file, which is referenced (AttachPath) or copied (AttachBlob, AttachFile).
+// name defaults to the file name or the URL.
+func AddAttachment(ctx context.Context, db DBTX, taskID int64, kind, target, name string) (models.Attachment, error) {
+	if _, err := GetTask(ctx, db, taskID); err != nil {
+		return models.Attachment{}, fmt.Errorf("task %d: %w", taskID, err)
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return models.Attachment{}, fmt.Errorf("nothing to attach")
+	}
+	var data []byte
+	var size int64
+	if kind != AttachURL {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return models.Attachment{}, err
+		}
+		fi, err := os.Stat(abs)
+		if err != nil {
+			return models.Attachment{}, err
+		}
+		if fi.IsDir() && kind != AttachPath {
+			return models.Attachment{}, fmt.Errorf("%s is a directory; only files can be copied", target)
+		}
+		target, size = abs, fi.Size()
+	}
+	if name == "" {
+		name = target
+		if kind != AttachURL {
+			name = filepath.Base(target)
+		}
+	}
+	switch kind {
+	case AttachURL, AttachPath:
+	case AttachBlob, AttachFile:
+		limit := MaxBlobBytes
+		if kind == AttachFile {
+			limit = MaxFileBytes
+		}
+		if size > limit {
+			return models.Attachment{}, fmt.Errorf("%s is %s, larger than the %s limit for %s attachments", name, FormatBytes(size), FormatBytes(limit), kind)
+		}
+		var err error
+		if data, err = os.ReadFile(target); err != nil {
+			return models.Attachment{}, err
+		}
+	default:
+		return models.Attachment{}, fmt.Errorf("unknown attachment kind %q", kind)
+	}
+
+	var ref interface{} = target
+	var blob interface{}
+	switch kind {
+	case AttachBlob:
+		ref, blob = nil, data
+	case AttachFile:
+		ref = nil
+	}
+	var a models.Attachment
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx, "INSERT INTO attachments (task_id, kind, name, target, data, size) VALUES (?, ?, ?, ?, ?, ?)",
+			taskID, kind, name, ref, blob, size)
+		if err != nil {
+			return err
+		}
+		id, _ := res.LastInsertId()
+		if kind == AttachFile {
+			dir, err := AttachmentDir(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			file := strconv.FormatInt(id, 10) + "-" + filepath.Base(target)
+			if err := os.WriteFile(filepath.Join(dir, file), data, 0o644); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE attachments SET target = ? WHERE id = ?", file, id); err != nil {
+				os.Remove(filepath.Join(dir, file))
+				return err
+			}
+		}
+		a, err = GetAttachment(ctx, tx, id)
+		return err
+	})
+	return a, err
+}
+
+func GetAttachment(ctx context.Context, db DBTX, id int64) (models.Attachment, error) {
+	a, err := scanAttachment(db.QueryRowContext(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE id = ?", id))
+	return a, notFound(err, fmt.Sprintf("attachment %d", id))
+}
+
+// ListAttachments returns a task's attachments, oldest first.
+func ListAttachments(ctx context.Context, db DBTX, taskID int64) ([]models.Attachment, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE task_id = ? ORDER BY id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// DeleteAttachment removes an attachment and, for copied files, the copy. Referenced paths are left alone.
+func DeleteAttachment(ctx context.Context, db DBTX, id int64) error {
+	a, err := GetAttachment(ctx, db, id)
+	if err != nil {
+		return err
+	}
+	if _, err := exec(ctx, db, "DELETE FROM attachments WHERE id = ?", id); err != nil {
+		return err
+	}
+	if a.Kind == AttachFile {
+		dir, err := AttachmentDir(ctx, db)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(dir, a.Target)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// AttachmentLocation returns what to hand to an opener: the URL, the referenced path, or the
+// copied file. Blobs are written to a file in a new private temporary directory, which cleanup
+// removes once the opener is done with it; for the other kinds cleanup does nothing.
+func AttachmentLocation(ctx context.Context, db DBTX, a models.Attachment) (loc string, cleanup func(), err error) {
+	cleanup = func() {}
+	switch a.Kind {
+	case AttachURL, AttachPath:
+		return a.Target, cleanup, nil
+	case AttachFile:
+		dir, err := AttachmentDir(ctx, db)
+		if err != nil {
+			return "", nil, err
+		}
+		return filepath.Join(dir, a.Target), cleanup, nil
+	}
+	var data []byte
+	if err := db.QueryRowContext(ctx, "SELECT data FROM attachments WHERE id = ?", a.ID).Scan(&data); err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "todo-attachment-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	// Keep the extension last, for openers that go by it.
+	name := strings.ReplaceAll(filepath.Base(a.Name), "*", "_")
+	ext := filepath.Ext(name)
+	f, err := os.CreateTemp(dir, strings.TrimSuffix(name, ext)+"-*"+ext)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return f.Name(), cleanup, nil
+}
+
+// AttachmentDir returns the directory holding copied attachments: "<db name>.attachments" next to the database.
+func AttachmentDir(ctx context.Context, db DBTX) (string, error) {
+	file, err := DatabaseFile(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	if file == "" {
+		return "", fmt.Errorf("file attachments need a database on disk")
+	}
+	return strings.TrimSuffix(file, filepath.Ext(file)) + ".attachments", nil
+}
+
+// pruneAttachmentFiles removes copied files whose attachment was deleted along with its task or workspace.
+// Inside a transaction it does nothing, since the delete may still be rolled back; the next delete outside
+// one catches up.
+func pruneAttachmentFiles(ctx context.Context, db DBTX) error {
+	if _, ok := db.(*sql.DB); !ok {
+		return nil
+	}
+	dir, err := AttachmentDir(ctx, db)
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM attachments WHERE kind = 'file' AND target = ?)", e.Name()).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FormatBytes renders a size as B, KB or MB.
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MB"
+	case n >= 1<<10:
+		return strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64) + " KB"
+	}
+	return strconv.FormatInt(n, 10) + " B"
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cli-todo/internal/models"
+)
+
+func TestAttachmentLocationOfBlob(t *testing.T) {
+	tmp := t.TempDir()
+	db := openTest(t, filepath.Join(tmp, "todo.db"))
+	defer closeTest(t, db)
+	w := must(CreateWorkspace(ctx, db, "Work"))(t)
+	task := must(CreateTask(ctx, db, w.ID, nil, "read", "", "", models.PriorityNone, nil))(t)
+	src := filepath.Join(tmp, "notes.txt")
+	if err := os.WriteFile(src, []byte("secret notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := must(AddAttachment(ctx, db, task.ID, AttachBlob, src, ""))(t)
+
+	loc, cleanup, err := AttachmentLocation(ctx, db, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := must(os.ReadFile(loc))(t); string(got) != "secret notes" {
+		t.Errorf("content %q", got)
+	}
+	if !strings.HasSuffix(loc, ".txt") {
+		t.Errorf("%s: lost the extension", loc)
+	}
+	if fi := must(os.Stat(filepath.Dir(loc)))(t); fi.Mode().Perm() != 0o700 {
+		t.Errorf("directory mode %v, want private", fi.Mode().Perm())
+	}
+	// A second open gets its own file, so one opener's cleanup can't remove another's.
+	loc2, cleanup2, err := AttachmentLocation(ctx, db, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup2()
+	if loc2 == loc {
+		t.Errorf("both opens got %s", loc)
+	}
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(loc)); !os.IsNotExist(err) {
+		t.Errorf("after cleanup: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Writes that still find the database busy after busy_timeout (see Open) are retried a few times with
+// growing pauses: 50ms, 100ms, ... 800ms.
+const (
+	busyRetries = 5
+	busyBackoff = 50 * time.Millisecond
+)
+
+// isBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED (including their extended codes).
+func isBusy(err error) bool {
+	var e interface{ Code() int }
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Code() & 0xff {
+	case 5, 6: // SQLITE_BUSY, SQLITE_LOCKED
+		return true
+	}
+	return false
+}
+
+// retryBusy runs fn again while it fails with a busy database, pausing between attempts unless ctx is done.
+func retryBusy(ctx context.Context, fn func() error) error {
+	err := fn()
+	for i := 0; i < busyRetries && isBusy(err); i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(busyBackoff << i):
+		}
+		err = fn()
+	}
+	return err
+}
+
+// exec runs a write statement, retrying while another process holds the write lock. Transactions
+// take the lock up front (_txlock=immediate, see WithTx), so statements inside them don't hit SQLITE_BUSY.
+func exec(ctx context.Context, db DBTX, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := retryBusy(ctx, func() error {
+		var err error
+		res, err = db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
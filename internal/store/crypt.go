@@ -0,0 +1,549 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"modernc.org/sqlite"
+	"modernc.org/sqlite/vfs"
+)
+
+// An encrypted database file is the SQLite database encrypted with AES-256-GCM, under a key derived from
+// a passphrase with PBKDF2-HMAC-SHA256:
+//
+//	"TODOENC1" | iterations (uint32, big endian) | salt (16 bytes) | nonce (12 bytes) | ciphertext
+//
+// The header is authenticated with the data. Open decrypts the file into an in-memory database, so the
+// data never reaches the disk in plain text, and Close encrypts it back under a new nonce. Attachments
+// kept as files (see AttachmentDir) are not encrypted; blobs are part of the database and are.
+const (
+	cryptMagic      = "TODOENC1"
+	cryptIterations = 600_000
+	cryptSaltSize   = 16
+	cryptHeaderSize = len(cryptMagic) + 4 + cryptSaltSize + 12
+)
+
+// sqliteMagic starts every SQLite database file.
+const sqliteMagic = "SQLite format 3\x00"
+
+// Environment variables that unlock encrypted databases without a prompt, e.g. in scripts.
+const (
+	PassphraseEnv = "TODO_PASSPHRASE"
+	// KeyEnv holds a key from CachedKey (todo db unlock). It skips the deliberately slow key derivation,
+	// and stops working when the passphrase is changed.
+	KeyEnv = "TODO_KEY"
+)
+
+// ErrWrongPassphrase is returned when an encrypted database doesn't decrypt.
+var ErrWrongPassphrase = errors.New("wrong passphrase, or the file is damaged")
+
+// ErrNotEncrypted is returned by the functions that need an encrypted database.
+var ErrNotEncrypted = errors.New("the database is not encrypted")
+
+// Passphrase asks for the passphrase of the encrypted database at path when neither KeyEnv nor
+// PassphraseEnv unlocks it. The command line sets it to a terminal prompt; nil fails.
+var Passphrase func(path string) (string, error)
+
+// cryptKey is a key derived from a passphrase, with what it was derived with.
+type cryptKey struct {
+	iterations uint32
+	salt       []byte
+	key        []byte
+}
+
+// newCryptKey derives a key from passphrase with a new salt.
+func newCryptKey(passphrase string) (*cryptKey, error) {
+	salt := make([]byte, cryptSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return deriveCryptKey(passphrase, cryptIterations, salt), nil
+}
+
+func deriveCryptKey(passphrase string, iterations uint32, salt []byte) *cryptKey {
+	return &cryptKey{iterations: iterations, salt: salt, key: pbkdf2SHA256([]byte(passphrase), salt, int(iterations), 32)}
+}
+
+// pbkdf2SHA256 is PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := bytes.Clone(u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+func (k *cryptKey) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a database image into the content of an encrypted database file.
+func (k *cryptKey) seal(image []byte) ([]byte, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, cryptHeaderSize)
+	header = append(header, cryptMagic...)
+	header = binary.BigEndian.AppendUint32(header, k.iterations)
+	header = append(header, k.salt...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, image, header), nil
+}
+
+// open decrypts the content of an encrypted database file.
+func (k *cryptKey) open(data []byte) ([]byte, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	header := data[:cryptHeaderSize]
+	image, err := aead.Open(nil, header[cryptHeaderSize-aead.NonceSize():], data[cryptHeaderSize:], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return image, nil
+}
+
+// cryptHeader returns the key derivation parameters of an encrypted database file; ok is false if data
+// isn't one.
+func cryptHeader(data []byte) (iterations uint32, salt []byte, ok bool) {
+	if len(data) < cryptHeaderSize || string(data[:len(cryptMagic)]) != cryptMagic {
+		return 0, nil, false
+	}
+	data = data[len(cryptMagic):]
+	return binary.BigEndian.Uint32(data), data[4 : 4+cryptSaltSize], true
+}
+
+// IsEncrypted reports whether the file at path is an encrypted database. A missing file is not.
+func IsEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, len(cryptMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, nil // too short for either format; SQLite will say what is wrong
+	}
+	return string(magic) == cryptMagic, nil
+}
+
+// unlockCrypt finds the key of the encrypted database file data at path: from KeyEnv if it holds this
+// file's key, else derived from PassphraseEnv or what ask returns.
+func unlockCrypt(path string, data []byte, ask func(path string) (string, error)) (*cryptKey, error) {
+	iterations, salt, _ := cryptHeader(data)
+	if k, err := parseCachedKey(os.Getenv(KeyEnv)); err == nil && k.iterations == iterations && bytes.Equal(k.salt, salt) {
+		return k, nil
+	}
+	passphrase, ok := os.LookupEnv(PassphraseEnv)
+	if !ok {
+		if ask == nil {
+			return nil, fmt.Errorf("%s is encrypted: set %s", path, PassphraseEnv)
+		}
+		var err error
+		if passphrase, err = ask(path); err != nil {
+			return nil, err
+		}
+	}
+	return deriveCryptKey(passphrase, iterations, salt), nil
+}
+
+// CachedKey returns the key of the open encrypted database db as text for KeyEnv.
+func CachedKey(db *sql.DB) (string, error) {
+	cs, _, err := cryptStoreOf(db)
+	if err != nil {
+		return "", err
+	}
+	b := binary.BigEndian.AppendUint32(nil, cs.key.iterations)
+	b = append(append(b, cs.key.salt...), cs.key.key...)
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func parseCachedKey(s string) (*cryptKey, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 4+cryptSaltSize+32 {
+		return nil, fmt.Errorf("%s: wrong length", KeyEnv)
+	}
+	return &cryptKey{iterations: binary.BigEndian.Uint32(b), salt: b[4 : 4+cryptSaltSize], key: b[4+cryptSaltSize:]}, nil
+}
+
+// cryptStore is an open encrypted database.
+type cryptStore struct {
+	path       string
+	key        *cryptKey // nil once Rekey decrypted the file
+	image      []byte    // the decrypted database, until load
+	unmigrated bool      // load leaves the schema as it is (see OpenUnmigrated)
+	// Digests of the file and of the database as last read or written: the file is only written when
+	// the database changed, and not over changes made by someone else.
+	disk, saved [sha256.Size]byte
+}
+
+// openEncrypted decrypts the encrypted database file at path into a new in-memory database.
+func openEncrypted(ctx context.Context, path string, ask func(path string) (string, error), migrate bool) (*sql.DB, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok := cryptHeader(data); !ok {
+		return nil, fmt.Errorf("%s: not an encrypted database", path)
+	}
+	key, err := unlockCrypt(path, data, ask)
+	if err != nil {
+		return nil, err
+	}
+	image, err := key.open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return openMemory(ctx, &cryptStore{path: path, key: key, image: image, disk: sha256.Sum256(data), unmigrated: !migrate})
+}
+
+func (cs *cryptStore) load(ctx context.Context, db *sql.DB, conn *sql.Conn, name string) error {
+	if err := restoreImage(ctx, name, cs.image); err != nil {
+		return fmt.Errorf("%s: %w", cs.path, err)
+	}
+	if pending, err := migrationPending(ctx, db); err != nil {
+		return err
+	} else if pending && SnapshotKeep > 0 && !cs.unmigrated {
+		data, err := cs.key.seal(cs.image)
+		if err == nil {
+			err = writeSnapshot(cs.path, "migrate", data)
+		}
+		if err != nil {
+			return fmt.Errorf("snapshot before migrate: %w", err)
+		}
+	}
+	cs.image = nil
+	if !cs.unmigrated {
+		if err := Migrate(ctx, db); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+	if err := SetSetting(ctx, db, settingStorePath, cs.path); err != nil {
+		return err
+	}
+	image, err := serializeImage(conn)
+	if err != nil {
+		return err
+	}
+	cs.saved = sha256.Sum256(image)
+	return nil
+}
+
+func (cs *cryptStore) reopen(ctx context.Context) (*sql.DB, error) {
+	data, err := os.ReadFile(cs.path)
+	if err != nil || sha256.Sum256(data) == cs.disk {
+		return nil, err
+	}
+	iterations, salt, ok := cryptHeader(data)
+	if !ok {
+		return nil, fmt.Errorf("%s: no longer an encrypted database", cs.path)
+	}
+	if iterations != cs.key.iterations || !bytes.Equal(salt, cs.key.salt) {
+		return nil, fmt.Errorf("%s: encrypted with a new passphrase meanwhile, open it again", cs.path)
+	}
+	image, err := cs.key.open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cs.path, err)
+	}
+	return openMemory(ctx, &cryptStore{path: cs.path, key: cs.key, image: image, disk: sha256.Sum256(data), unmigrated: cs.unmigrated})
+}
+
+// save writes the database back to the file if it changed. If the file was changed by someone else in
+// the meantime, it is kept and this version is written next to it, to merge with todo sync.
+func (cs *cryptStore) save(ctx context.Context, db *sql.DB, conn *sql.Conn) error {
+	image, err := serializeImage(conn)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(image)
+	if cs.key != nil && sum == cs.saved {
+		return nil
+	}
+	data := image
+	if cs.key != nil {
+		if data, err = cs.key.seal(image); err != nil {
+			return err
+		}
+	}
+	if disk, err := os.ReadFile(cs.path); err == nil && sha256.Sum256(disk) != cs.disk {
+		if err := writeFileAtomic(cs.path+".conflict", data); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s changed while todo had it open, kept as is: todo's version is in %s.conflict (merge it with todo sync --with)",
+			cs.path, cs.path)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := writeFileAtomic(cs.path, data); err != nil {
+		return err
+	}
+	cs.disk, cs.saved = sha256.Sum256(data), sum
+	return nil
+}
+
+// EncryptFile encrypts the database file at path with passphrase, and its snapshots with the same key;
+// it returns how many snapshots it encrypted. The database must be closed.
+func EncryptFile(path, passphrase string) (int, error) {
+	image, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if _, _, ok := cryptHeader(image); ok {
+		return 0, fmt.Errorf("%s is already encrypted", path)
+	}
+	if !bytes.HasPrefix(image, []byte(sqliteMagic)) {
+		return 0, fmt.Errorf("%s is not a SQLite database", path)
+	}
+	// Closing the last connection moves the WAL into the database; one left means it is open elsewhere.
+	if info, err := os.Stat(path + "-wal"); err == nil && info.Size() > 0 {
+		return 0, fmt.Errorf("%s is open in another todo (or wasn't closed cleanly): close it and try again", path)
+	}
+	key, err := newCryptKey(passphrase)
+	if err != nil {
+		return 0, err
+	}
+	data, err := key.seal(image)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return 0, err
+	}
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+	return encryptSnapshots(snapshotDir(path), key)
+}
+
+// encryptSnapshots encrypts the plain snapshots in dir with key, so none keeps the data readable.
+func encryptSnapshots(dir string, key *cryptKey) (int, error) {
+	snaps, err := listSnapshots(dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range snaps {
+		if s.Encrypted {
+			continue
+		}
+		image, err := os.ReadFile(s.Path)
+		if err != nil {
+			return n, err
+		}
+		data, err := key.seal(image)
+		if err != nil {
+			return n, err
+		}
+		if err := writeFileAtomic(s.Path, data); err != nil {
+			return n, fmt.Errorf("encrypt snapshot %s: %w", s.Name, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Rekey encrypts the open encrypted database db under a new passphrase and writes it right away. An
+// empty passphrase writes it decrypted instead; db then stays open, but is no longer saved by Close.
+func Rekey(ctx context.Context, db *sql.DB, passphrase string) error {
+	cs, m, err := cryptStoreOf(db)
+	if err != nil {
+		return err
+	}
+	key, saved := cs.key, cs.saved
+	cs.saved = [sha256.Size]byte{} // written even though the data didn't change
+	if passphrase == "" {
+		cs.key = nil
+		_, err = db.ExecContext(ctx, "DELETE FROM settings WHERE key = ?", settingStorePath)
+	} else {
+		cs.key, err = newCryptKey(passphrase)
+	}
+	if err == nil {
+		err = cs.save(ctx, db, m.conn)
+	}
+	if err != nil {
+		cs.key, cs.saved = key, saved
+		return err
+	}
+	if passphrase == "" {
+		memStores.Delete(db)
+		m.conn.Close()
+	}
+	return nil
+}
+
+func cryptStoreOf(db *sql.DB) (*cryptStore, *memDB, error) {
+	if v, ok := memStores.Load(db); ok {
+		if cs, ok := v.(*memDB).store.(*cryptStore); ok {
+			return cs, v.(*memDB), nil
+		}
+	}
+	return nil, nil, ErrNotEncrypted
+}
+
+// sqliteConn is the part of the driver's connection used to move whole databases in and out.
+type sqliteConn interface {
+	Serialize() ([]byte, error)
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+func rawSQLite(conn *sql.Conn, fn func(sqliteConn) error) error {
+	return conn.Raw(func(dc any) error {
+		c, ok := dc.(sqliteConn)
+		if !ok {
+			return fmt.Errorf("unsupported SQLite driver %T", dc)
+		}
+		return fn(c)
+	})
+}
+
+// serializeImage returns the database of conn as the content of a database file.
+func serializeImage(conn *sql.Conn) ([]byte, error) {
+	var image []byte
+	err := rawSQLite(conn, func(c sqliteConn) error {
+		var err error
+		image, err = c.Serialize()
+		return err
+	})
+	return image, err
+}
+
+// restoreImage copies a database file's content into the memdb database name.
+func restoreImage(ctx context.Context, name string, image []byte) error {
+	return withImage(image, func(uri string) error {
+		src, err := sql.Open("sqlite", uri)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		conn, err := src.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return rawSQLite(conn, func(c sqliteConn) error {
+			b, err := c.NewBackup(memDSN(name))
+			if err != nil {
+				return err
+			}
+			return runBackup(b)
+		})
+	})
+}
+
+// withImage calls fn with the URI of a read-only database serving a database file's content from
+// memory, through imageVFS.
+func withImage(image []byte, fn func(uri string) error) error {
+	if !bytes.HasPrefix(image, []byte(sqliteMagic)) || len(image) < 100 {
+		return errors.New("not a SQLite database")
+	}
+	// Bytes 18 and 19 are 2 in WAL mode, which would make SQLite look for a WAL file.
+	image[18], image[19] = 1, 1
+	imageVFS.once.Do(func() {
+		imageVFS.name, _, imageVFS.err = vfs.New(&imageVFS.files)
+	})
+	if imageVFS.err != nil {
+		return imageVFS.err
+	}
+	name := fmt.Sprintf("image-%d", imageVFS.next.Add(1))
+	imageVFS.files.Store(name, image)
+	defer imageVFS.files.Delete(name)
+	return fn("file:" + name + "?vfs=" + imageVFS.name + "&immutable=1")
+}
+
+// imageVFS serves the images withImage is reading. It is registered once and never closed:
+// (*vfs.FS).Close frees memory SQLite allocated with the wrong allocator, corrupting the heap.
+var imageVFS struct {
+	once  sync.Once
+	name  string
+	err   error
+	next  atomic.Int64
+	files imageFS
+}
+
+func runBackup(b *sqlite.Backup) error {
+	if _, err := b.Step(-1); err != nil {
+		b.Finish()
+		return err
+	}
+	return b.Finish()
+}
+
+// imageFS is a file system holding database images by name.
+type imageFS struct{ sync.Map }
+
+func (f *imageFS) Open(name string) (fs.File, error) {
+	v, ok := f.Load(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	image := v.([]byte)
+	return &imageFile{Reader: bytes.NewReader(image), size: int64(len(image))}, nil
+}
+
+type imageFile struct {
+	*bytes.Reader
+	size int64
+}
+
+func (f *imageFile) Stat() (fs.FileInfo, error) { return imageInfo(f.size), nil }
+func (f *imageFile) Close() error               { return nil }
+
+type imageInfo int64
+
+func (i imageInfo) Name() string       { return "db" }
+func (i imageInfo) Size() int64        { return int64(i) }
+func (i imageInfo) Mode() fs.FileMode  { return 0o444 }
+func (i imageInfo) ModTime() time.Time { return time.Time{} }
+func (i imageInfo) IsDir() bool        { return false }
+func (i imageInfo) Sys() any           { return nil }
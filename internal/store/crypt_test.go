@@ -0,0 +1,49 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptFileEncryptsSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.db")
+	db := openTest(t, path)
+	w := must(CreateWorkspace(ctx, db, "Secret plans"))(t)
+	must(TakeSnapshot(ctx, db))(t)
+	if err := SnapshotBefore(ctx, db, "workspace-delete"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteWorkspace(ctx, db, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	closeTest(t, db)
+
+	if n := must(EncryptFile(path, "secret"))(t); n != 2 {
+		t.Errorf("encrypted %d snapshots, want 2", n)
+	}
+	files := must(filepath.Glob(filepath.Join(snapshotDir(path), "*")))(t)
+	for _, file := range append(files, path) {
+		if data := must(os.ReadFile(file))(t); bytes.Contains(data, []byte("Secret plans")) {
+			t.Errorf("%s: readable after encrypt", filepath.Base(file))
+		}
+	}
+
+	// They restore with the database's passphrase.
+	t.Setenv(PassphraseEnv, "secret")
+	db = openTest(t, path)
+	defer closeTest(t, db)
+	snaps := must(Snapshots(ctx, db))(t)
+	for _, s := range snaps {
+		if !s.Encrypted {
+			t.Errorf("%s: not encrypted", s.Name)
+		}
+	}
+	if err := Restore(ctx, db, must(FindSnapshot(ctx, db, snaps[len(snaps)-1].Name))(t)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetWorkspaceByName(ctx, db, "Secret plans"); err != nil {
+		t.Errorf("restored snapshot: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// AddDependency records that taskID cannot be finished before dependsOnID.
+// It refuses dependencies that would create a cycle.
+func AddDependency(ctx context.Context, db DBTX, taskID, dependsOnID int64) error {
+	return WithTx(ctx, db, func(tx DBTX) error {
+		if taskID == dependsOnID {
+			return fmt.Errorf("task %d cannot depend on itself", taskID)
+		}
+		for _, id := range []int64{taskID, dependsOnID} {
+			if _, err := GetTask(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		// A cycle exists if dependsOnID already (transitively) depends on taskID.
+		var cycle bool
+		err := tx.QueryRowContext(ctx,
+			`WITH RECURSIVE deps(id) AS (
+				SELECT depends_on_id FROM task_dependencies WHERE task_id = ?
+				UNION
+				SELECT d.depends_on_id FROM task_dependencies d JOIN deps ON d.task_id = deps.id
+			)
+			SELECT EXISTS (SELECT 1 FROM deps WHERE id = ?)`,
+			dependsOnID, taskID,
+		).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("task %d already depends on task %d; adding this dependency would create a cycle", dependsOnID, taskID)
+		}
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO task_dependencies (task_id, depends_on_id) VALUES (?, ?)", taskID, dependsOnID)
+		return err
+	})
+}
+
+func RemoveDependency(ctx context.Context, db DBTX, taskID, dependsOnID int64) error {
+	res, err := exec(ctx, db, "DELETE FROM task_dependencies WHERE task_id = ? AND depends_on_id = ?", taskID, dependsOnID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("task %d does not depend on task %d", taskID, dependsOnID)
+	}
+	return nil
+}
+
+// ListBlockers returns the tasks that taskID depends on (done or not).
+func ListBlockers(ctx context.Context, db DBTX, taskID int64) ([]models.Task, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+taskColumns+` FROM tasks WHERE id IN (SELECT depends_on_id FROM task_dependencies WHERE task_id = ?) ORDER BY id`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTasks(rows)
+}
+
+// ListDependents returns the tasks that depend on taskID.
+func ListDependents(ctx context.Context, db DBTX, taskID int64) ([]models.Task, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+taskColumns+` FROM tasks WHERE id IN (SELECT task_id FROM task_dependencies WHERE depends_on_id = ?) ORDER BY id`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTasks(rows)
+}
@@ -0,0 +1,929 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// DirPrefix makes Open use a directory of Markdown files instead of a database file: "dir:/path/to/tasks".
+// The files are loaded into an in-memory database, which every store function works on as usual, and
+// written back by Save and Close. See markdown.go for the layout.
+const DirPrefix = "dir:"
+
+const dirStateFile = "_state.json"
+
+// dirStore is an open directory store.
+type dirStore struct {
+	dir   string
+	files map[string][]byte // content of each file as last read or written, by path relative to dir
+	// Where each workspace and list was read from or last written, by ID; the default list is always
+	// mdDefaultFile.
+	workspaces map[int64]mdPlace
+	lists      map[int64]mdPlace
+}
+
+// mdPlace is a record's name and its directory (a workspace) or file name in the workspace's directory
+// (a list), as read or last written.
+type mdPlace struct{ name, path string }
+
+// dirState is _state.json: what the Markdown files don't show.
+type dirState struct {
+	Settings   map[string]string            `json:"settings,omitempty"`
+	Templates  map[string]json.RawMessage   `json:"templates,omitempty"`
+	Sequences  map[string]int64             `json:"sequences,omitempty"` // last IDs used, so deleted IDs aren't reused
+	Clocks     map[string]map[string]string `json:"clocks,omitempty"`    // sync clocks: uuid -> field -> changed_at
+	Tombstones map[string]syncTombstone     `json:"tombstones,omitempty"`
+	// Written has digests of the field texts last written, to tell which fields were edited in the files.
+	Written map[string]dirWritten `json:"written,omitempty"`
+}
+
+type dirWritten struct {
+	Kind   string            `json:"kind"`
+	Fields map[string]string `json:"fields"`
+}
+
+// openDir loads the directory store in dir (created if missing) into a new in-memory database.
+func openDir(ctx context.Context, dir string) (*sql.DB, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return openMemory(ctx, &dirStore{dir: dir, files: map[string][]byte{}, workspaces: map[int64]mdPlace{}, lists: map[int64]mdPlace{}})
+}
+
+// read returns a file of the store (nil if it doesn't exist) and its modification time, and remembers
+// its content for save.
+func (ds *dirStore) read(rel string) ([]byte, time.Time, error) {
+	path := filepath.Join(ds.dir, rel)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	ds.files[rel] = data
+	return data, info.ModTime(), nil
+}
+
+func (ds *dirStore) load(ctx context.Context, db *sql.DB, _ *sql.Conn, _ string) error {
+	if err := Migrate(ctx, db); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	var state dirState
+	data, _, err := ds.read(dirStateFile)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Join(ds.dir, dirStateFile), err)
+		}
+	}
+	workspaces, err := ds.readWorkspaces()
+	if err != nil {
+		return err
+	}
+	return WithTx(ctx, db, func(tx DBTX) error {
+		// Restored first, so records without an ID in the files don't get one that was deleted.
+		for table, seq := range state.Sequences {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO sqlite_sequence (name, seq) VALUES (?, ?)", table, seq); err != nil {
+				return err
+			}
+		}
+		if err := insertMDWorkspaces(ctx, tx, workspaces); err != nil {
+			return err
+		}
+		if err := restoreDirState(ctx, tx, state, workspaces); err != nil {
+			return err
+		}
+		ds.remember(workspaces)
+		return SetSetting(ctx, tx, settingStorePath, ds.dir)
+	})
+}
+
+// readWorkspaces parses the workspace directories: those with at least one .md file.
+func (ds *dirStore) readWorkspaces() ([]*mdWorkspace, error) {
+	entries, err := os.ReadDir(ds.dir)
+	if err != nil {
+		return nil, err
+	}
+	var workspaces []*mdWorkspace
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(ds.dir, e.Name(), "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+		w := &mdWorkspace{mdRecord: mdRecord{Name: e.Name()}, dir: e.Name()}
+		rel := filepath.Join(e.Name(), mdWorkspaceFile)
+		data, mod, err := ds.read(rel)
+		if err != nil {
+			return nil, err
+		}
+		w.modTime = mod
+		if err := parseMDWorkspace(filepath.Join(ds.dir, rel), data, w); err != nil {
+			return nil, err
+		}
+		if len(w.Statuses) == 0 {
+			w.Statuses = mdDefaultStatuses()
+		}
+		w.Lists = []*mdList{{Default: true, file: filepath.Join(e.Name(), mdDefaultFile)}}
+		for _, file := range files {
+			name := filepath.Base(file)
+			switch name {
+			case mdWorkspaceFile:
+				continue
+			case mdDefaultFile:
+			default:
+				w.Lists = append(w.Lists, &mdList{mdRecord: mdRecord{Name: strings.TrimSuffix(name, ".md")}, file: filepath.Join(e.Name(), name)})
+			}
+		}
+		for _, l := range w.Lists {
+			data, mod, err := ds.read(l.file)
+			if err != nil {
+				return nil, err
+			}
+			l.modTime = mod
+			if err := parseMDList(filepath.Join(ds.dir, l.file), data, w, l); err != nil {
+				return nil, err
+			}
+		}
+		workspaces = append(workspaces, w)
+	}
+	return workspaces, nil
+}
+
+// insertMDWorkspaces fills an empty database. Records keep the IDs and UUIDs of their files, except a
+// second record with the same one (e.g. a task copied in an editor), which gets a new one; records
+// without an ID are inserted last so they don't take one a file has.
+func insertMDWorkspaces(ctx context.Context, tx DBTX, workspaces []*mdWorkspace) error {
+	type listRef struct {
+		w *mdWorkspace
+		l *mdList
+	}
+	type taskRef struct {
+		w *mdWorkspace
+		l *mdList
+		t *mdTask
+	}
+	seen := map[string]bool{}
+	unique := func(table string, id *int64, uuid *string) {
+		key := table + ":" + strconv.FormatInt(*id, 10)
+		if *id != 0 && seen[key] {
+			*id = 0
+		}
+		if *uuid != "" && seen[*uuid] {
+			*uuid = ""
+		}
+		seen[key], seen[*uuid] = true, true
+	}
+	var lists []listRef
+	var tasks []taskRef
+	for _, w := range workspaces {
+		unique("workspaces", &w.ID, &w.UUID)
+		for _, l := range w.Lists {
+			if !l.Default {
+				unique("projects", &l.ID, &l.UUID)
+				lists = append(lists, listRef{w, l})
+			}
+			for _, t := range l.Tasks {
+				unique("tasks", &t.ID, &t.UUID)
+				tasks = append(tasks, taskRef{w, l, t})
+			}
+		}
+	}
+
+	for _, w := range idsFirst(workspaces, func(w *mdWorkspace) int64 { return w.ID }) {
+		file := filepath.Join(w.dir, mdWorkspaceFile)
+		res, err := tx.ExecContext(ctx, "INSERT INTO workspaces (id, uuid, name, color, created_at) VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))",
+			nullID(w.ID), nullString(w.UUID), w.Name, nullString(w.Color), mdSQLTime(w.Created))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, duplicate(err, fmt.Sprintf("workspace %q", w.Name)))
+		}
+		w.ID, _ = res.LastInsertId()
+		for _, s := range w.Statuses {
+			if _, err := CreateStatus(ctx, tx, w.ID, s.Name, s.Color, s.IsDone); err != nil {
+				return fmt.Errorf("%s: status %q: %w", file, s.Name, err)
+			}
+		}
+		for _, f := range w.Fields {
+			if _, err := CreateField(ctx, tx, w.ID, f.Name, f.Type, f.Options); err != nil {
+				return fmt.Errorf("%s: field %q: %w", file, f.Name, err)
+			}
+		}
+	}
+	for _, r := range idsFirst(lists, func(r listRef) int64 { return r.l.ID }) {
+		res, err := tx.ExecContext(ctx, "INSERT INTO projects (id, uuid, workspace_id, name, color, created_at) VALUES (?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))",
+			nullID(r.l.ID), nullString(r.l.UUID), r.w.ID, r.l.Name, nullString(r.l.Color), mdSQLTime(r.l.Created))
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.l.file, duplicate(err, fmt.Sprintf("project %q", r.l.Name)))
+		}
+		r.l.ID, _ = res.LastInsertId()
+	}
+	tasks = idsFirst(tasks, func(r taskRef) int64 { return r.t.ID })
+	fileIDs := map[int64]int64{} // task ID in the files -> ID in the database
+	for _, r := range tasks {
+		fileID, err := insertMDTask(ctx, tx, r.w, r.l, r.t)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", r.l.file, r.t.line, err)
+		}
+		if fileID != 0 {
+			fileIDs[fileID] = r.t.ID
+		}
+	}
+	for _, r := range tasks {
+		if err := insertMDTaskDetails(ctx, tx, r.w, r.t, fileIDs); err != nil {
+			return fmt.Errorf("%s:%d: %w", r.l.file, r.t.line, err)
+		}
+	}
+	return nil
+}
+
+// idsFirst returns items with those that have an ID first, otherwise in the same order.
+func idsFirst[T any](items []T, id func(T) int64) []T {
+	out := append([]T(nil), items...)
+	sort.SliceStable(out, func(a, b int) bool { return id(out[a]) != 0 && id(out[b]) == 0 })
+	return out
+}
+
+func nullID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func mdSQLTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return sqlTime(t)
+}
+
+// insertMDTask inserts the task row and sets t.ID to its ID; it returns the ID the file gave it.
+func insertMDTask(ctx context.Context, tx DBTX, w *mdWorkspace, l *mdList, t *mdTask) (int64, error) {
+	priority, err := ParsePriority(t.Meta["priority"])
+	if err != nil {
+		return 0, err
+	}
+	pri, err := nullPriority(priority)
+	if err != nil {
+		return 0, err
+	}
+	due, err := parseMDDue(t.Meta["due"])
+	if err != nil {
+		return 0, err
+	}
+	minutes, points, err := ParseEstimate(t.Meta["estimate"])
+	if err != nil {
+		return 0, err
+	}
+	var project any
+	if !l.Default {
+		project = l.ID
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO tasks (id, uuid, workspace_id, project_id, title, description, status, priority, due_date, estimate_minutes, estimate_points, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, ?, CURRENT_TIMESTAMP))`,
+		nullID(t.ID), nullString(t.UUID), w.ID, project, t.Title, t.Description, t.Status, pri, nullTime(due),
+		minutes, points, mdSQLTime(t.Created), mdSQLTime(t.Updated), mdSQLTime(t.Created))
+	if err != nil {
+		return 0, err
+	}
+	fileID := t.ID
+	t.ID, _ = res.LastInsertId()
+	return fileID, nil
+}
+
+// insertMDTaskDetails adds a task's tags, dependencies, custom field values, notes, time entries and attachments.
+func insertMDTaskDetails(ctx context.Context, tx DBTX, w *mdWorkspace, t *mdTask, fileIDs map[int64]int64) error {
+	for _, tag := range strings.Split(t.Meta["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO task_tags (task_id, tag) VALUES (?, ?)", t.ID, tag); err != nil {
+				return err
+			}
+		}
+	}
+	for _, ref := range strings.Split(t.Meta["depends_on"], ",") {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil || fileIDs[n] == 0 {
+			return fmt.Errorf("depends_on: no task %s", ref)
+		}
+		if err := AddDependency(ctx, tx, t.ID, fileIDs[n]); err != nil {
+			return fmt.Errorf("depends_on: %w", err)
+		}
+	}
+	for key, raw := range t.Meta {
+		name, ok := strings.CutPrefix(key, "field.")
+		if !ok {
+			continue
+		}
+		f, err := GetFieldByName(ctx, tx, w.ID, name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		v, err := parseFieldValue(f, raw)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO task_field_values (task_id, field_id, value) VALUES (?, ?, ?)", t.ID, f.ID, v); err != nil {
+			return err
+		}
+	}
+	for _, line := range t.Notes {
+		tok, err := mdTokens(line)
+		if err != nil || len(tok) != 2 {
+			return fmt.Errorf("note: expected TIME \"TEXT\", got %q", line)
+		}
+		at, err := parseMDTime(tok[0])
+		if err != nil {
+			return fmt.Errorf("note: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO task_notes (task_id, body, created_at) VALUES (?, ?, ?)", t.ID, tok[1], sqlTime(at)); err != nil {
+			return err
+		}
+	}
+	for _, line := range t.Time {
+		if err := insertMDTimeEntry(ctx, tx, t.ID, line); err != nil {
+			return fmt.Errorf("time: %w", err)
+		}
+	}
+	for _, line := range t.Attachments {
+		if err := insertMDAttachment(ctx, tx, t.ID, line); err != nil {
+			return fmt.Errorf("attachment: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertMDTimeEntry reads "START END|running DURATION [\"NOTE\"]".
+func insertMDTimeEntry(ctx context.Context, tx DBTX, taskID int64, line string) error {
+	tok, err := mdTokens(line)
+	if err != nil || len(tok) < 3 || len(tok) > 4 {
+		return fmt.Errorf("expected START END|running DURATION [\"NOTE\"], got %q", line)
+	}
+	start, err := parseMDTime(tok[0])
+	if err != nil {
+		return err
+	}
+	var end any
+	if tok[1] != "running" {
+		t, err := parseMDTime(tok[1])
+		if err != nil {
+			return err
+		}
+		end = sqlTime(t)
+	}
+	d, err := time.ParseDuration(tok[2])
+	if err != nil {
+		return fmt.Errorf("duration %q: %w", tok[2], err)
+	}
+	var note any
+	if len(tok) == 4 {
+		note = nullString(tok[3])
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO time_entries (task_id, started_at, ended_at, seconds, note) VALUES (?, ?, ?, ?, ?)",
+		taskID, sqlTime(start), end, int64(d.Seconds()), note)
+	return err
+}
+
+// insertMDAttachment reads "CREATED KIND SIZE \"NAME\" \"TARGET\"", where a blob's target is its data in base64.
+func insertMDAttachment(ctx context.Context, tx DBTX, taskID int64, line string) error {
+	tok, err := mdTokens(line)
+	if err != nil || len(tok) != 5 {
+		return fmt.Errorf("expected CREATED KIND SIZE \"NAME\" \"TARGET\", got %q", line)
+	}
+	at, err := parseMDTime(tok[0])
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(tok[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("size %q: %w", tok[2], err)
+	}
+	var target, data any = tok[4], nil
+	if tok[1] == AttachBlob {
+		b, err := base64.StdEncoding.DecodeString(tok[4])
+		if err != nil {
+			return fmt.Errorf("blob data: %w", err)
+		}
+		target, data = nil, b
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO attachments (task_id, kind, name, target, data, size, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		taskID, tok[1], tok[3], target, data, size, sqlTime(at))
+	return err
+}
+
+// restoreDirState loads _state.json into the database. Sync clocks are the saved ones (the inserts set
+// them all to now), plus the time of the file for fields edited there since they were written; records
+// removed from the files get tombstones.
+func restoreDirState(ctx context.Context, tx DBTX, state dirState, workspaces []*mdWorkspace) error {
+	for key, value := range state.Settings {
+		if key != settingStorePath {
+			if err := SetSetting(ctx, tx, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	for name, body := range state.Templates {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO templates (name, body) VALUES (?, ?)", name, string(body)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sync_clocks"); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sync_tombstones"); err != nil {
+		return err
+	}
+	setClock := func(uuid, field, at string) error {
+		_, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO sync_clocks (uuid, field, changed_at) VALUES (?, ?, ?)", uuid, field, at)
+		return err
+	}
+	for uuid, fields := range state.Clocks {
+		for field, at := range fields {
+			if err := setClock(uuid, field, at); err != nil {
+				return err
+			}
+		}
+	}
+	for uuid, ts := range state.Tombstones {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO sync_tombstones (uuid, kind, deleted_at) VALUES (?, ?, ?)", uuid, ts.Kind, ts.At); err != nil {
+			return err
+		}
+	}
+	present := map[string]bool{}
+	var err error
+	for _, w := range workspaces {
+		w.syncTexts(func(uuid, kind, field, text string, at time.Time) {
+			present[uuid] = true
+			old, ok := state.Written[uuid]
+			if err != nil || !ok || old.Fields[field] == mdDigest(text) {
+				return
+			}
+			if at.IsZero() {
+				at = time.Now()
+			}
+			clock := at.UTC().Format(syncClockLayout)
+			if err = setClock(uuid, field, clock); err == nil && kind == "task" {
+				_, err = tx.ExecContext(ctx, "UPDATE tasks SET updated_at = ? WHERE uuid = ? AND updated_at < ?", sqlTime(at), uuid, sqlTime(at))
+			}
+		})
+	}
+	if err != nil {
+		return err
+	}
+	now := TreeClock()
+	for uuid, old := range state.Written {
+		if present[uuid] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO sync_tombstones (uuid, kind, deleted_at) VALUES (?, ?, ?)", uuid, old.Kind, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save writes the database to the directory. A file is only written when its content changes; one
+// that was also changed on disk since it was read (e.g. in an editor while the TUI was open) is kept,
+// and the database's version is written next to it as <file>.conflict.
+func (ds *dirStore) save(ctx context.Context, db *sql.DB, _ *sql.Conn) error {
+	workspaces, err := readMDWorkspaces(ctx, db)
+	if err != nil {
+		return err
+	}
+	state, err := readDirState(ctx, db)
+	if err != nil {
+		return err
+	}
+	want := map[string][]byte{}
+	// Workspaces and lists stay where they are, so paths don't change under editors and scripts; only
+	// new and renamed ones get a name from theirs, with their ID added if another has it already.
+	dirs := map[string]bool{}
+	for _, w := range workspaces {
+		if p, ok := ds.workspaces[w.ID]; ok && p.name == w.Name && takeMDName(dirs, p.path) {
+			w.dir = p.path
+		}
+	}
+	for _, w := range workspaces {
+		if w.dir == "" {
+			w.dir = uniqueMDName(dirs, w.Name, w.ID)
+		}
+		want[filepath.Join(w.dir, mdWorkspaceFile)] = renderMDWorkspace(w)
+		files := map[string]bool{}
+		takeMDName(files, mdWorkspaceFile)
+		takeMDName(files, mdDefaultFile)
+		for _, l := range w.Lists {
+			if l.Default {
+				l.file = mdDefaultFile
+			} else if p, ok := ds.lists[l.ID]; ok && p.name == l.Name && takeMDName(files, p.path) {
+				l.file = p.path
+			}
+		}
+		for _, l := range w.Lists {
+			if l.file == "" {
+				l.file = uniqueMDName(files, l.Name, l.ID) + ".md"
+			}
+			want[filepath.Join(w.dir, l.file)] = renderMDList(l, w.Fields)
+		}
+		w.syncTexts(func(uuid, kind, field, text string, _ time.Time) {
+			if state.Written[uuid].Fields == nil {
+				state.Written[uuid] = dirWritten{Kind: kind, Fields: map[string]string{}}
+			}
+			state.Written[uuid].Fields[field] = mdDigest(text)
+		})
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	want[dirStateFile] = append(data, '\n')
+	if err := ds.write(want); err != nil {
+		return err
+	}
+	ds.remember(workspaces)
+	return nil
+}
+
+func (ds *dirStore) reopen(ctx context.Context) (*sql.DB, error) {
+	// The files load reads: the state and the Markdown files of the workspace directories.
+	files, err := filepath.Glob(filepath.Join(ds.dir, "*", "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filepath.Join(ds.dir, dirStateFile))
+	seen := 0
+	for _, path := range files {
+		rel, err := filepath.Rel(ds.dir, path)
+		if err != nil || strings.HasPrefix(rel, ".") {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if old, ok := ds.files[rel]; !ok || !bytes.Equal(old, data) {
+			return openDir(ctx, ds.dir)
+		}
+		seen++
+	}
+	if seen != len(ds.files) {
+		return openDir(ctx, ds.dir)
+	}
+	return nil, nil
+}
+
+// remember records where the workspaces and lists are, for save to keep them there.
+func (ds *dirStore) remember(workspaces []*mdWorkspace) {
+	for _, w := range workspaces {
+		ds.workspaces[w.ID] = mdPlace{name: w.Name, path: w.dir}
+		for _, l := range w.Lists {
+			if !l.Default {
+				ds.lists[l.ID] = mdPlace{name: l.Name, path: filepath.Base(l.file)}
+			}
+		}
+	}
+}
+
+// uniqueMDName returns name as a file name not in taken yet (compared case-insensitively, as some
+// file systems do), adding the record's ID when needed, and marks it taken.
+func uniqueMDName(taken map[string]bool, name string, id int64) string {
+	file := mdFileName(name)
+	if !takeMDName(taken, file) {
+		file += "-" + strconv.FormatInt(id, 10)
+		takeMDName(taken, file)
+	}
+	return file
+}
+
+// takeMDName marks a directory or file name (with or without .md) taken, unless it is already.
+func takeMDName(taken map[string]bool, name string) bool {
+	key := strings.ToLower(strings.TrimSuffix(name, ".md"))
+	if taken[key] {
+		return false
+	}
+	taken[key] = true
+	return true
+}
+
+// write makes the directory hold want (path relative to the directory -> content), removing the
+// Markdown files it read that are no longer wanted.
+func (ds *dirStore) write(want map[string][]byte) error {
+	var conflicts []string
+	// changedOnDisk reports whether a file differs from what was read or written last.
+	changedOnDisk := func(rel string) (bool, error) {
+		disk, err := os.ReadFile(filepath.Join(ds.dir, rel))
+		if errors.Is(err, fs.ErrNotExist) {
+			_, had := ds.files[rel]
+			return had, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		old, had := ds.files[rel]
+		return !had || !bytes.Equal(disk, old), nil
+	}
+	names := make([]string, 0, len(want))
+	for rel := range want {
+		names = append(names, rel)
+	}
+	sort.Strings(names)
+	for _, rel := range names {
+		data := want[rel]
+		if old, ok := ds.files[rel]; ok && bytes.Equal(old, data) {
+			continue
+		}
+		changed, err := changedOnDisk(rel)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(ds.dir, rel)
+		if changed {
+			if disk, _ := os.ReadFile(path); bytes.Equal(disk, data) {
+				ds.files[rel] = data
+				continue
+			}
+			conflicts = append(conflicts, rel)
+			path += ".conflict"
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			return err
+		}
+		if !changed {
+			ds.files[rel] = data
+		}
+	}
+	for rel := range ds.files {
+		if _, ok := want[rel]; ok {
+			continue
+		}
+		changed, err := changedOnDisk(rel)
+		if err != nil {
+			return err
+		}
+		if changed {
+			conflicts = append(conflicts, rel)
+			continue
+		}
+		if err := os.Remove(filepath.Join(ds.dir, rel)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		delete(ds.files, rel)
+		if dir := filepath.Dir(rel); dir != "." {
+			os.Remove(filepath.Join(ds.dir, dir)) // only if it is empty now
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("changed in %s while todo had it open, kept as is (todo's version is in <file>.conflict): %s",
+			ds.dir, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readMDWorkspaces reads the database the way the Markdown files show it.
+func readMDWorkspaces(ctx context.Context, db DBTX) ([]*mdWorkspace, error) {
+	notes := map[int64][]string{}
+	err := eachRow(ctx, db, "SELECT task_id, body, created_at FROM task_notes ORDER BY created_at, id", func(scan func(...any) error) error {
+		var id int64
+		var body string
+		var at time.Time
+		if err := scan(&id, &body, &at); err != nil {
+			return err
+		}
+		notes[id] = append(notes[id], formatMDTime(at)+" "+strconv.Quote(body))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := map[int64][]string{}
+	err = eachRow(ctx, db, "SELECT task_id, started_at, ended_at, seconds, note FROM time_entries ORDER BY started_at, id", func(scan func(...any) error) error {
+		var id, seconds int64
+		var start time.Time
+		var end sql.NullTime
+		var note sql.NullString
+		if err := scan(&id, &start, &end, &seconds, &note); err != nil {
+			return err
+		}
+		ended := "running"
+		if end.Valid {
+			ended = formatMDTime(end.Time)
+		}
+		line := fmt.Sprintf("%s %s %s", formatMDTime(start), ended, time.Duration(seconds)*time.Second)
+		if note.String != "" {
+			line += " " + strconv.Quote(note.String)
+		}
+		entries[id] = append(entries[id], line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	attachments := map[int64][]string{}
+	err = eachRow(ctx, db, "SELECT task_id, kind, name, COALESCE(target, ''), data, size, created_at FROM attachments ORDER BY id", func(scan func(...any) error) error {
+		var id, size int64
+		var kind, name, target string
+		var data []byte
+		var at time.Time
+		if err := scan(&id, &kind, &name, &target, &data, &size, &at); err != nil {
+			return err
+		}
+		if kind == AttachBlob {
+			target = base64.StdEncoding.EncodeToString(data)
+		}
+		attachments[id] = append(attachments[id], fmt.Sprintf("%s %s %d %s %s", formatMDTime(at), kind, size, strconv.Quote(name), strconv.Quote(target)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	deps := map[int64][]string{}
+	err = eachRow(ctx, db, "SELECT task_id, depends_on_id FROM task_dependencies ORDER BY task_id, depends_on_id", func(scan func(...any) error) error {
+		var id, on int64
+		if err := scan(&id, &on); err != nil {
+			return err
+		}
+		deps[id] = append(deps[id], strconv.FormatInt(on, 10))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := ListWorkspaces(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var workspaces []*mdWorkspace
+	for _, ws := range list {
+		w := &mdWorkspace{mdRecord: mdRecord{ID: ws.ID, UUID: ws.UUID, Name: ws.Name, Color: ws.Color, Created: ws.CreatedAt}}
+		statuses, err := ListStatuses(ctx, db, ws.ID)
+		if err != nil {
+			return nil, err
+		}
+		done := map[string]bool{}
+		for _, s := range statuses {
+			w.Statuses = append(w.Statuses, models.ExportStatus{Name: s.Name, Color: s.Color, IsDone: s.IsDone})
+			done[s.Name] = s.IsDone
+		}
+		fields, err := ListFields(ctx, db, ws.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			w.Fields = append(w.Fields, models.ExportField{Name: f.Name, Type: f.Type, Options: f.Options})
+		}
+		projects, err := ListProjects(ctx, db, ws.ID)
+		if err != nil {
+			return nil, err
+		}
+		w.Lists = []*mdList{{Default: true}}
+		lists := map[int64]*mdList{}
+		for _, p := range projects {
+			l := &mdList{mdRecord: mdRecord{ID: p.ID, UUID: p.UUID, Name: p.Name, Color: p.Color, Created: p.CreatedAt}}
+			lists[p.ID] = l
+			w.Lists = append(w.Lists, l)
+		}
+		tasks, err := ListAllTasksInWorkspace(ctx, db, ws.ID)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+		for _, task := range tasks {
+			t := &mdTask{
+				ID: task.ID, UUID: task.UUID, Done: done[task.Status], Title: task.Title, Description: task.Description,
+				Status: task.Status, Created: task.CreatedAt, Updated: task.UpdatedAt,
+				Notes: notes[task.ID], Time: entries[task.ID], Attachments: attachments[task.ID],
+				Meta: map[string]string{
+					"priority":   string(task.Priority),
+					"due":        formatMDDue(task.DueDate),
+					"estimate":   FormatEstimate(task),
+					"tags":       strings.Join(task.Tags, ", "),
+					"depends_on": strings.Join(deps[task.ID], ", "),
+				},
+			}
+			for name, v := range task.Fields {
+				t.Meta["field."+name] = FormatFieldValue(v)
+			}
+			l := w.Lists[0]
+			if task.ProjectID != nil && lists[*task.ProjectID] != nil {
+				l = lists[*task.ProjectID]
+			}
+			l.Tasks = append(l.Tasks, t)
+		}
+		workspaces = append(workspaces, w)
+	}
+	return workspaces, nil
+}
+
+// readDirState reads what _state.json keeps from the database; Written is left for save to fill.
+func readDirState(ctx context.Context, db DBTX) (dirState, error) {
+	state := dirState{
+		Settings: map[string]string{}, Templates: map[string]json.RawMessage{}, Sequences: map[string]int64{},
+		Clocks: map[string]map[string]string{}, Tombstones: map[string]syncTombstone{}, Written: map[string]dirWritten{},
+	}
+	err := eachRow(ctx, db, "SELECT key, value FROM settings WHERE key <> '"+settingStorePath+"'", func(scan func(...any) error) error {
+		var key, value string
+		if err := scan(&key, &value); err != nil {
+			return err
+		}
+		state.Settings[key] = value
+		return nil
+	})
+	if err != nil {
+		return state, err
+	}
+	err = eachRow(ctx, db, "SELECT name, body FROM templates", func(scan func(...any) error) error {
+		var name, body string
+		if err := scan(&name, &body); err != nil {
+			return err
+		}
+		state.Templates[name] = json.RawMessage(body)
+		return nil
+	})
+	if err != nil {
+		return state, err
+	}
+	err = eachRow(ctx, db, "SELECT name, seq FROM sqlite_sequence WHERE name IN ('workspaces', 'projects', 'tasks')", func(scan func(...any) error) error {
+		var name string
+		var seq int64
+		if err := scan(&name, &seq); err != nil {
+			return err
+		}
+		state.Sequences[name] = seq
+		return nil
+	})
+	if err != nil {
+		return state, err
+	}
+	err = eachRow(ctx, db, "SELECT uuid, field, changed_at FROM sync_clocks", func(scan func(...any) error) error {
+		var uuid, field, at string
+		if err := scan(&uuid, &field, &at); err != nil {
+			return err
+		}
+		if state.Clocks[uuid] == nil {
+			state.Clocks[uuid] = map[string]string{}
+		}
+		state.Clocks[uuid][field] = at
+		return nil
+	})
+	if err != nil {
+		return state, err
+	}
+	err = eachRow(ctx, db, "SELECT uuid, kind, deleted_at FROM sync_tombstones", func(scan func(...any) error) error {
+		var uuid string
+		var ts syncTombstone
+		if err := scan(&uuid, &ts.Kind, &ts.At); err != nil {
+			return err
+		}
+		state.Tombstones[uuid] = ts
+		return nil
+	})
+	return state, err
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/cli-todo/internal/models"
+)
+
+func TestDirStoreKeepsPaths(t *testing.T) {
+	dir := t.TempDir()
+	db := openTest(t, DirPrefix+dir)
+	work := must(CreateWorkspace(ctx, db, "work"))(t)
+	backend := must(CreateProject(ctx, db, work.ID, "backend"))(t)
+	must(CreateTask(ctx, db, work.ID, &backend.ID, "api", "", "", models.PriorityNone, nil))(t)
+	web := must(CreateProject(ctx, db, work.ID, "web"))(t)
+	closeTest(t, db)
+
+	// New records whose names clash with existing ones, case-insensitively, get their ID added;
+	// a renamed one moves.
+	db = openTest(t, DirPrefix+dir)
+	other := must(CreateWorkspace(ctx, db, "Work"))(t)
+	clash := must(CreateProject(ctx, db, work.ID, "Backend"))(t)
+	must(UpdateProject(ctx, db, web.ID, "site"))(t)
+	closeTest(t, db)
+
+	for _, rel := range []string{
+		"work/_workspace.md",
+		"work/backend.md",
+		"work/site.md",
+		"work/Backend-" + strconv.FormatInt(clash.ID, 10) + ".md",
+		"Work-" + strconv.FormatInt(other.ID, 10) + "/_workspace.md",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, rel)); err != nil {
+			t.Errorf("%s: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "work", "web.md")); err == nil {
+		t.Error("work/web.md is still there after the list was renamed")
+	}
+
+	// Reading the files back finds each record where it was.
+	db = openTest(t, DirPrefix+dir)
+	defer closeTest(t, db)
+	for _, w := range must(ListWorkspaces(ctx, db))(t) {
+		if w.ID == other.ID && w.Name != "Work" || w.ID == work.ID && w.Name != "work" {
+			t.Errorf("workspace %d is named %q", w.ID, w.Name)
+		}
+	}
+	tasks := must(ListTasks(ctx, db, work.ID, &backend.ID))(t)
+	if len(tasks) != 1 || tasks[0].Title != "api" {
+		t.Errorf("backend tasks %v, want [api]", taskTitles(tasks))
+	}
+}
@@ -0,0 +1,381 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A Finding is a problem found by CheckDatabase.
+type Finding struct {
+	Check   string // integrity, schema, foreign key or consistency
+	Problem string
+	Fix     string // what FixDatabase does about it, "" if it can't be fixed automatically
+
+	repair  func(ctx context.Context, tx DBTX) error
+	migrate bool // fixed by running Migrate
+}
+
+// Fixable reports whether FixDatabase can fix the problem.
+func (f Finding) Fixable() bool {
+	return f.repair != nil || f.migrate
+}
+
+// CheckDatabase looks for what migrations that failed silently, foreign keys that weren't enforced or
+// bugs can leave behind: corruption (PRAGMA integrity_check), a schema older or newer than this version's
+// or missing tables, columns, indexes and triggers, rows whose foreign key points nowhere (PRAGMA
+// foreign_key_check), and data breaking rules the store enforces, e.g. tasks in a list of another
+// workspace. The foreign key and consistency checks need the tables and columns they read, so they are
+// only run once the schema is complete.
+func CheckDatabase(ctx context.Context, db *sql.DB) ([]Finding, error) {
+	findings, err := checkIntegrity(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	schema, complete, err := checkSchema(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("schema check: %w", err)
+	}
+	findings = append(findings, schema...)
+	if !complete {
+		return findings, nil
+	}
+	fks, err := checkForeignKeys(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("foreign key check: %w", err)
+	}
+	findings = append(findings, fks...)
+	consistency, err := checkConsistency(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("consistency check: %w", err)
+	}
+	return append(findings, consistency...), nil
+}
+
+// FixDatabase fixes the fixable findings in one transaction, then migrates the database if one of them
+// needs it, and returns how many it fixed. Check again afterwards: fixing the schema lets CheckDatabase
+// run the checks it skipped.
+func FixDatabase(ctx context.Context, db *sql.DB, findings []Finding) (int, error) {
+	fixed, migrate := 0, false
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		for _, f := range findings {
+			if f.repair != nil {
+				if err := f.repair(ctx, tx); err != nil {
+					return fmt.Errorf("fix %s: %w", f.Problem, err)
+				}
+			}
+			if f.Fixable() {
+				fixed++
+			}
+			migrate = migrate || f.migrate
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if migrate {
+		if err := Migrate(ctx, db); err != nil {
+			return 0, fmt.Errorf("migrate: %w", err)
+		}
+	}
+	return fixed, nil
+}
+
+// checkIntegrity runs PRAGMA integrity_check. Broken indexes are rebuilt by REINDEX; anything else calls
+// for a snapshot or backup.
+func checkIntegrity(ctx context.Context, db DBTX) ([]Finding, error) {
+	var findings []Finding
+	err := eachRow(ctx, db, "PRAGMA integrity_check", func(scan func(...any) error) error {
+		var msg string
+		if err := scan(&msg); err != nil {
+			return err
+		}
+		if msg == "ok" {
+			return nil
+		}
+		f := Finding{Check: "integrity", Problem: msg}
+		// e.g. "row 5 missing from index idx_tasks_status", "wrong # of entries in index idx_tasks_status"
+		if _, rest, ok := strings.Cut(msg, " index "); ok && rest != "" {
+			index := strings.Fields(rest)[0]
+			f.Fix = "rebuild index " + index
+			f.repair = func(ctx context.Context, tx DBTX) error {
+				_, err := tx.ExecContext(ctx, "REINDEX "+quoteIdent(index))
+				return err
+			}
+		}
+		findings = append(findings, f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+	return findings, nil
+}
+
+// checkSchema compares the schema version and the schema with those Migrate creates in a new database.
+// Only what is missing is reported; columns and tables left over from older versions do no harm. complete
+// is false if tables or columns are missing.
+func checkSchema(ctx context.Context, db *sql.DB) (findings []Finding, complete bool, err error) {
+	var version int
+	if err := db.QueryRowContext(ctx, "SELECT user_version FROM pragma_user_version").Scan(&version); err != nil {
+		return nil, false, err
+	}
+	switch {
+	case version > schemaVersion:
+		findings = append(findings, Finding{Check: "schema",
+			Problem: fmt.Sprintf("schema version %d is newer than this version of todo knows (%d); update todo", version, schemaVersion)})
+	case version < schemaVersion:
+		findings = append(findings, Finding{Check: "schema",
+			Problem: fmt.Sprintf("schema version %d, expected %d", version, schemaVersion), Fix: "migrate", migrate: true})
+	}
+	ref, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, false, err
+	}
+	defer ref.Close()
+	ref.SetMaxOpenConns(1) // each connection would get its own :memory: database
+	if err := Migrate(ctx, ref); err != nil {
+		return nil, false, err
+	}
+	const tablesSQL = "SELECT name FROM pragma_table_list WHERE schema = 'main' AND type IN ('table', 'virtual') AND name NOT LIKE 'sqlite_%'"
+	want, err := schemaNames(ctx, ref, tablesSQL)
+	if err != nil {
+		return nil, false, err
+	}
+	have, err := schemaNames(ctx, db, tablesSQL)
+	if err != nil {
+		return nil, false, err
+	}
+	complete = true
+	for _, table := range want {
+		if !slices.Contains(have, table) {
+			findings = append(findings, Finding{Check: "schema", Problem: "table " + table + " is missing", Fix: "create it", migrate: true})
+			complete = false
+			continue
+		}
+		missing, err := missingColumns(ctx, ref, db, table)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(missing) > 0 {
+			complete = false
+		}
+		findings = append(findings, missing...)
+	}
+	for _, kind := range []string{"index", "trigger"} {
+		query := "SELECT name FROM sqlite_master WHERE type = '" + kind + "' AND sql IS NOT NULL"
+		want, err := schemaNames(ctx, ref, query)
+		if err != nil {
+			return nil, false, err
+		}
+		have, err := schemaNames(ctx, db, query)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, name := range want {
+			if !slices.Contains(have, name) {
+				findings = append(findings, Finding{Check: "schema", Problem: kind + " " + name + " is missing", Fix: "create it", migrate: true})
+			}
+		}
+	}
+	return findings, complete, nil
+}
+
+// missingColumns reports the columns of table in ref that db lacks, added by ALTER TABLE if SQLite allows.
+func missingColumns(ctx context.Context, ref, db *sql.DB, table string) ([]Finding, error) {
+	have, err := schemaNames(ctx, db, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ref.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var findings []Finding
+	for rows.Next() {
+		var name, typ string
+		var notNull bool
+		var pk int // position in the primary key, 0 if not part of it
+		var dflt sql.NullString
+		if err := rows.Scan(&name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		if slices.Contains(have, name) {
+			continue
+		}
+		f := Finding{Check: "schema", Problem: "column " + table + "." + name + " is missing"}
+		// ALTER TABLE can't add a default that isn't constant, like CURRENT_TIMESTAMP; without one, it
+		// can't add a NOT NULL column. Nor can it add a primary key.
+		if dflt.Valid && (strings.HasPrefix(strings.ToUpper(dflt.String), "CURRENT_") || strings.HasPrefix(dflt.String, "(")) {
+			dflt.Valid = false
+		}
+		if pk == 0 && (!notNull || dflt.Valid) {
+			def := strings.TrimSpace(quoteIdent(name) + " " + typ)
+			if notNull {
+				def += " NOT NULL"
+			}
+			if dflt.Valid {
+				def += " DEFAULT " + dflt.String
+			}
+			stmt := "ALTER TABLE " + quoteIdent(table) + " ADD COLUMN " + def
+			f.Fix = "add it"
+			f.repair = func(ctx context.Context, tx DBTX) error {
+				_, err := tx.ExecContext(ctx, stmt)
+				return err
+			}
+			f.migrate = true // for the triggers and indexes on it
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// checkForeignKeys runs PRAGMA foreign_key_check, which finds what the foreign_keys pragma would have
+// prevented: a row whose parent doesn't exist is deleted, like the cascade would have, or its reference
+// cleared where the key is ON DELETE SET NULL.
+func checkForeignKeys(ctx context.Context, db DBTX) ([]Finding, error) {
+	type violation struct {
+		table, parent string
+		rowid         sql.NullInt64
+		fkid          int
+	}
+	var violations []violation
+	err := eachRow(ctx, db, "PRAGMA foreign_key_check", func(scan func(...any) error) error {
+		var v violation
+		if err := scan(&v.table, &v.rowid, &v.parent, &v.fkid); err != nil {
+			return err
+		}
+		violations = append(violations, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, v := range violations {
+		var column, onDelete string
+		err := db.QueryRowContext(ctx, `SELECT "from", on_delete FROM pragma_foreign_key_list(?) WHERE id = ? ORDER BY seq LIMIT 1`, v.table, v.fkid).Scan(&column, &onDelete)
+		if err != nil {
+			return nil, err
+		}
+		if !v.rowid.Valid {
+			findings = append(findings, Finding{Check: "foreign key", Problem: fmt.Sprintf("a row of %s refers to a missing row of %s", v.table, v.parent)})
+			continue
+		}
+		rowid := v.rowid.Int64
+		var value any
+		if err := db.QueryRowContext(ctx, "SELECT "+quoteIdent(column)+" FROM "+quoteIdent(v.table)+" WHERE rowid = ?", rowid).Scan(&value); err != nil {
+			return nil, err
+		}
+		f := Finding{Check: "foreign key",
+			Problem: fmt.Sprintf("%s row %d has %s %v, which is not in %s", v.table, rowid, column, value, v.parent)}
+		if onDelete == "SET NULL" {
+			f.Fix = "clear " + column
+			f.repair = func(ctx context.Context, tx DBTX) error {
+				_, err := tx.ExecContext(ctx, "UPDATE "+quoteIdent(v.table)+" SET "+quoteIdent(column)+" = NULL WHERE rowid = ?", rowid)
+				return err
+			}
+		} else {
+			f.Fix = "delete the row"
+			f.repair = func(ctx context.Context, tx DBTX) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM "+quoteIdent(v.table)+" WHERE rowid = ?", rowid)
+				return err
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// consistencyChecks are the rules the store keeps that the schema doesn't enforce.
+var consistencyChecks = []struct {
+	query  string // selects the rowid of each offending row and a description of the problem
+	fix    string
+	repair string // fixes the row with that rowid
+}{
+	{
+		`SELECT t.id, printf('task %d "%s" is in workspace %d, but its list "%s" is in workspace %d', t.id, t.title, t.workspace_id, p.name, p.workspace_id)
+		 FROM tasks t JOIN projects p ON p.id = t.project_id WHERE p.workspace_id <> t.workspace_id`,
+		"move it to the default list of its workspace",
+		"UPDATE tasks SET project_id = NULL WHERE id = ?",
+	},
+	{
+		`SELECT t.id, printf('task %d "%s" has status "%s", which is not in the workflow of its workspace', t.id, t.title, t.status)
+		 FROM tasks t WHERE NOT EXISTS (SELECT 1 FROM statuses s WHERE s.workspace_id = t.workspace_id AND s.name = t.status)`,
+		"set it to the first status of the workflow",
+		`UPDATE tasks SET status = COALESCE((SELECT name FROM statuses s WHERE s.workspace_id = tasks.workspace_id ORDER BY position, id LIMIT 1), status),
+		 updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+	},
+	{
+		`SELECT v.rowid, printf('task %d "%s" has a value for field "%s" of workspace %d', t.id, t.title, f.name, f.workspace_id)
+		 FROM task_field_values v JOIN tasks t ON t.id = v.task_id JOIN custom_fields f ON f.id = v.field_id WHERE f.workspace_id <> t.workspace_id`,
+		"delete the value",
+		"DELETE FROM task_field_values WHERE rowid = ?",
+	},
+	{
+		`SELECT c.rowid, printf('sync clock of %s for %s, which no longer exists', c.field, c.uuid) FROM sync_clocks c
+		 WHERE c.uuid NOT IN (SELECT uuid FROM workspaces WHERE uuid IS NOT NULL UNION ALL SELECT uuid FROM projects WHERE uuid IS NOT NULL
+		 UNION ALL SELECT uuid FROM tasks WHERE uuid IS NOT NULL)`,
+		"delete it",
+		"DELETE FROM sync_clocks WHERE rowid = ?",
+	},
+}
+
+func checkConsistency(ctx context.Context, db DBTX) ([]Finding, error) {
+	var findings []Finding
+	for _, c := range consistencyChecks {
+		err := eachRow(ctx, db, c.query, func(scan func(...any) error) error {
+			var rowid int64
+			var problem string
+			if err := scan(&rowid, &problem); err != nil {
+				return err
+			}
+			repair := c.repair
+			findings = append(findings, Finding{Check: "consistency", Problem: problem, Fix: c.fix,
+				repair: func(ctx context.Context, tx DBTX) error {
+					_, err := tx.ExecContext(ctx, repair, rowid)
+					return err
+				}})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The search index is kept in sync by triggers, which a rebuilt tasks table may have lost for a while.
+	if _, err := db.ExecContext(ctx, "INSERT INTO tasks_fts(tasks_fts) VALUES ('integrity-check')"); err != nil {
+		findings = append(findings, Finding{Check: "consistency", Problem: "search index: " + err.Error(), Fix: "rebuild it",
+			repair: func(ctx context.Context, tx DBTX) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild')")
+				return err
+			}})
+	}
+	return findings, nil
+}
+
+// schemaNames returns the first column of the rows of query.
+func schemaNames(ctx context.Context, db DBTX, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// quoteIdent quotes an SQL identifier, e.g. a table name taken from the schema.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDoctorChecksUnmigratedSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.db")
+	db := openTest(t, path)
+	if _, err := db.ExecContext(ctx, "PRAGMA user_version = 0; DROP INDEX idx_tasks_status; DROP TRIGGER tasks_uuid"); err != nil {
+		t.Fatal(err)
+	}
+	closeTest(t, db)
+
+	db = must(OpenUnmigrated(ctx, path))(t)
+	defer closeTest(t, db)
+	findings := must(CheckDatabase(ctx, db))(t)
+	var problems []string
+	for _, f := range findings {
+		if !f.Fixable() {
+			t.Errorf("%s: can't be fixed", f.Problem)
+		}
+		problems = append(problems, f.Problem)
+	}
+	if len(findings) != 3 {
+		t.Fatalf("findings %q, want the schema version, index and trigger", problems)
+	}
+	must(FixDatabase(ctx, db, findings))(t)
+	if findings := must(CheckDatabase(ctx, db))(t); len(findings) != 0 {
+		t.Errorf("after the fix: %v", findings)
+	}
+}
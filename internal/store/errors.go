@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned (wrapped with what was looked up) when a row doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicateName is returned (wrapped with the name) when a workspace, list, status, field or
+// template with that name already exists.
+var ErrDuplicateName = errors.New("already exists")
+
+// ErrInvalidField is returned when a value is not valid for a task attribute or custom field.
+type ErrInvalidField struct {
+	Field  string // e.g. priority, status, due, estimate or a custom field's name
+	Value  string
+	Reason string // what is expected instead, e.g. "use low, medium or high"
+}
+
+func (e ErrInvalidField) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// notFound turns sql.ErrNoRows into ErrNotFound, described by what (e.g. "task 3"); other errors pass through.
+func notFound(err error, what string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	}
+	return err
+}
+
+// checkAffected reports ErrNotFound when a delete or update by key touched no row.
+func checkAffected(res sql.Result, what string) error {
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	}
+	return nil
+}
+
+// duplicate turns a UNIQUE constraint violation into ErrDuplicateName, described by what
+// (e.g. `list "backend"`); other errors pass through.
+func duplicate(err error, what string) error {
+	var e interface{ Code() int }
+	if errors.As(err, &e) {
+		switch e.Code() {
+		case 2067, 1555: // SQLITE_CONSTRAINT_UNIQUE, SQLITE_CONSTRAINT_PRIMARYKEY
+			return fmt.Errorf("%s %w", what, ErrDuplicateName)
+		}
+	}
+	return err
+}
+
+// oneOf phrases a list of choices for ErrInvalidField: "use a, b or c".
+func oneOf(choices []string) string {
+	switch len(choices) {
+	case 0:
+		return "no values are defined"
+	case 1:
+		return "use " + choices[0]
+	}
+	return "use " + strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// ParseEstimate parses a duration ("2h", "1h30m", "45m") or story points ("3pt", "5sp", "2p").
+// An empty string clears the estimate (both results nil).
+func ParseEstimate(s string) (minutes *int64, points *float64, err error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		return nil, nil, nil
+	}
+	for _, suffix := range []string{"pts", "pt", "sp", "p"} {
+		if num, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil || v < 0 {
+				return nil, nil, ErrInvalidField{Field: "estimate", Value: s, Reason: "points must be a non-negative number (e.g. 3pt)"}
+			}
+			return nil, &v, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return nil, nil, ErrInvalidField{Field: "estimate", Value: s, Reason: "use a duration (e.g. 1h30m) or points (e.g. 3pt)"}
+	}
+	m := int64(d.Minutes())
+	return &m, nil, nil
+}
+
+// FormatEstimate renders a task's estimate like ParseEstimate accepts it ("" if unset).
+func FormatEstimate(t models.Task) string {
+	switch {
+	case t.EstimateMinutes != nil:
+		return FormatMinutes(*t.EstimateMinutes)
+	case t.EstimatePoints != nil:
+		return strconv.FormatFloat(*t.EstimatePoints, 'f', -1, 64) + "pt"
+	}
+	return ""
+}
+
+// FormatMinutes renders minutes as e.g. "1h30m", "2h" or "45m".
+func FormatMinutes(m int64) string {
+	h, m := m/60, m%60
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+// FormatCapacity renders remaining estimates against logged time,
+// e.g. "5h estimated, 1h20m logged, 3h40m left • 8pt".
+func FormatCapacity(minutes int64, points float64, loggedSeconds int64) string {
+	var parts []string
+	if minutes > 0 {
+		s := FormatMinutes(minutes) + " estimated"
+		if loggedMinutes := loggedSeconds / 60; loggedMinutes > 0 {
+			s += ", " + FormatMinutes(loggedMinutes) + " logged"
+			if left := minutes - loggedMinutes; left >= 0 {
+				s += ", " + FormatMinutes(left) + " left"
+			} else {
+				s += ", " + FormatMinutes(-left) + " over"
+			}
+		}
+		parts = append(parts, s)
+	}
+	if points > 0 {
+		parts = append(parts, strconv.FormatFloat(points, 'f', -1, 64)+"pt")
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " • ")
+}
+
+// SetTaskEstimate sets a task's estimate; pass nil for both to clear it.
+func SetTaskEstimate(ctx context.Context, db DBTX, taskID int64, minutes *int64, points *float64) (models.Task, error) {
+	if minutes != nil && points != nil {
+		return models.Task{}, fmt.Errorf("estimate is either a duration or points, not both")
+	}
+	_, err := exec(ctx, db,
+		`UPDATE tasks SET estimate_minutes = ?, estimate_points = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		minutes, points, taskID,
+	)
+	if err != nil {
+		return models.Task{}, err
+	}
+	return GetTask(ctx, db, taskID)
+}
+
+// EstimateTotals sums the estimates of open tasks per list of a workspace, default list first.
+// Lists without open tasks are omitted.
+func EstimateTotals(ctx context.Context, db DBTX, workspaceID int64) ([]models.EstimateTotal, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.project_id, COALESCE(p.name, 'Default'), COUNT(*),
+		        COALESCE(SUM(t.estimate_minutes), 0), COALESCE(SUM(t.estimate_points), 0),
+		        COALESCE(SUM(CASE WHEN t.estimate_minutes IS NOT NULL
+		                     THEN (SELECT SUM(`+entrySecondsSQL+`) FROM time_entries e WHERE e.task_id = t.id) END), 0)
+		 FROM tasks t LEFT JOIN projects p ON p.id = t.project_id
+		 WHERE t.workspace_id = ?
+		   AND NOT EXISTS (SELECT 1 FROM statuses s WHERE s.workspace_id = t.workspace_id AND s.name = t.status AND s.is_done = 1)
+		 GROUP BY t.project_id ORDER BY t.project_id IS NOT NULL, p.name`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.EstimateTotal
+	for rows.Next() {
+		var et models.EstimateTotal
+		var projID sql.NullInt64
+		if err := rows.Scan(&projID, &et.Name, &et.OpenTasks, &et.Minutes, &et.Points, &et.LoggedSeconds); err != nil {
+			return nil, err
+		}
+		if projID.Valid {
+			et.ProjectID = &projID.Int64
+		}
+		list = append(list, et)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,299 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// ExportVersion is the version of the export format written by Export.
+const ExportVersion = 1
+
+// ImportResult counts what Import did.
+type ImportResult struct {
+	Workspaces   int // created
+	Projects     int // created
+	TasksAdded   int
+	TasksUpdated int // the file's copy was newer
+	TasksSkipped int // already up to date
+}
+
+// Export collects workspaces (all of them when ids is empty) with their workflows, custom fields,
+// projects and tasks, including tags, field values, dependencies and notes. Time entries and
+// attachments are not exported.
+func Export(ctx context.Context, db DBTX, ids []int64) (models.Export, error) {
+	out := models.Export{Version: ExportVersion, ExportedAt: time.Now().UTC()}
+	if len(ids) == 0 {
+		list, err := ListWorkspaces(ctx, db)
+		if err != nil {
+			return out, err
+		}
+		for _, w := range list {
+			ids = append(ids, w.ID)
+		}
+	}
+	for _, id := range ids {
+		ew, err := exportWorkspace(ctx, db, id)
+		if err != nil {
+			return out, err
+		}
+		out.Workspaces = append(out.Workspaces, ew)
+	}
+	return out, nil
+}
+
+func exportWorkspace(ctx context.Context, db DBTX, id int64) (models.ExportWorkspace, error) {
+	w, err := GetWorkspace(ctx, db, id)
+	if err != nil {
+		return models.ExportWorkspace{}, err
+	}
+	ew := models.ExportWorkspace{UUID: w.UUID, Name: w.Name, Color: w.Color}
+	statuses, err := ListStatuses(ctx, db, id)
+	if err != nil {
+		return ew, err
+	}
+	for _, s := range statuses {
+		ew.Statuses = append(ew.Statuses, models.ExportStatus{Name: s.Name, Color: s.Color, IsDone: s.IsDone})
+	}
+	fields, err := ListFields(ctx, db, id)
+	if err != nil {
+		return ew, err
+	}
+	for _, f := range fields {
+		ew.Fields = append(ew.Fields, models.ExportField{Name: f.Name, Type: f.Type, Options: f.Options})
+	}
+	projects, err := ListProjects(ctx, db, id)
+	if err != nil {
+		return ew, err
+	}
+	projectUUIDs := map[int64]string{}
+	for _, p := range projects {
+		projectUUIDs[p.ID] = p.UUID
+		ew.Projects = append(ew.Projects, models.ExportProject{UUID: p.UUID, Name: p.Name, Color: p.Color})
+	}
+	tasks, err := ListAllTasksInWorkspace(ctx, db, id)
+	if err != nil {
+		return ew, err
+	}
+	for _, t := range tasks {
+		et := models.ExportTask{
+			UUID: t.UUID, Title: t.Title, Description: t.Description, Status: t.Status, Priority: t.Priority,
+			DueDate: t.DueDate, EstimateMinutes: t.EstimateMinutes, EstimatePoints: t.EstimatePoints,
+			Tags: t.Tags, Fields: t.Fields, CreatedAt: t.CreatedAt, UpdatedAt: t.UpdatedAt,
+		}
+		if t.ProjectID != nil {
+			et.Project = projectUUIDs[*t.ProjectID]
+		}
+		blockers, err := ListBlockers(ctx, db, t.ID)
+		if err != nil {
+			return ew, err
+		}
+		for _, b := range blockers {
+			et.DependsOn = append(et.DependsOn, b.UUID)
+		}
+		notes, err := ListNotes(ctx, db, t.ID)
+		if err != nil {
+			return ew, err
+		}
+		for _, n := range notes {
+			et.Notes = append(et.Notes, models.ExportNote{Body: n.Body, CreatedAt: n.CreatedAt})
+		}
+		ew.Tasks = append(ew.Tasks, et)
+	}
+	return ew, nil
+}
+
+// Import merges an export into the database in one transaction. Workspaces and projects are matched
+// by UUID, then by name; tasks by UUID. A task that exists already is overwritten only when the file's
+// copy was updated later. Missing statuses and custom fields are added to the workflow; notes are added
+// unless the task has the same note already.
+func Import(ctx context.Context, db DBTX, data models.Export) (ImportResult, error) {
+	var res ImportResult
+	if data.Version != ExportVersion {
+		return res, fmt.Errorf("unsupported export version %d (this todo reads version %d)", data.Version, ExportVersion)
+	}
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		res = ImportResult{}
+		taskIDs := map[string]int64{} // task UUID -> local ID
+		for _, ew := range data.Workspaces {
+			if err := importWorkspace(ctx, tx, ew, taskIDs, &res); err != nil {
+				return fmt.Errorf("workspace %q: %w", ew.Name, err)
+			}
+		}
+		for _, ew := range data.Workspaces {
+			for _, et := range ew.Tasks {
+				for _, dep := range et.DependsOn {
+					on, ok := taskIDs[dep]
+					if !ok {
+						if err := tx.QueryRowContext(ctx, "SELECT id FROM tasks WHERE uuid = ?", dep).Scan(&on); err != nil {
+							return fmt.Errorf("task %q depends on %s: %w", et.Title, dep, notFound(err, "task "+dep))
+						}
+					}
+					if err := AddDependency(ctx, tx, taskIDs[et.UUID], on); err != nil {
+						return fmt.Errorf("task %q: %w", et.Title, err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+func importWorkspace(ctx context.Context, tx DBTX, ew models.ExportWorkspace, taskIDs map[string]int64, res *ImportResult) error {
+	if ew.UUID == "" || ew.Name == "" {
+		return fmt.Errorf("workspace without uuid or name")
+	}
+	var wsID int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM workspaces WHERE uuid = ? OR (name = ? AND uuid <> ?) ORDER BY uuid = ? DESC LIMIT 1",
+		ew.UUID, ew.Name, ew.UUID, ew.UUID).Scan(&wsID)
+	if err != nil {
+		r, err := tx.ExecContext(ctx, "INSERT INTO workspaces (uuid, name, color) VALUES (?, ?, ?)", ew.UUID, ew.Name, nullString(ew.Color))
+		if err != nil {
+			return duplicate(err, fmt.Sprintf("workspace %q", ew.Name))
+		}
+		wsID, _ = r.LastInsertId()
+		res.Workspaces++
+		if len(ew.Statuses) == 0 {
+			if _, err := tx.ExecContext(ctx, seedStatusesSQL+" WHERE w.id = ?", wsID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, s := range ew.Statuses {
+		if _, err := GetStatusByName(ctx, tx, wsID, s.Name); err == nil {
+			continue
+		}
+		if _, err := CreateStatus(ctx, tx, wsID, s.Name, s.Color, s.IsDone); err != nil {
+			return err
+		}
+	}
+	for _, f := range ew.Fields {
+		if _, err := GetFieldByName(ctx, tx, wsID, f.Name); err == nil {
+			continue
+		}
+		if _, err := CreateField(ctx, tx, wsID, f.Name, f.Type, f.Options); err != nil {
+			return err
+		}
+	}
+	projectIDs := map[string]int64{}
+	for _, ep := range ew.Projects {
+		var id int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM projects WHERE uuid = ? OR (workspace_id = ? AND name = ?) ORDER BY uuid = ? DESC LIMIT 1",
+			ep.UUID, wsID, ep.Name, ep.UUID).Scan(&id)
+		if err != nil {
+			r, err := tx.ExecContext(ctx, "INSERT INTO projects (uuid, workspace_id, name, color) VALUES (?, ?, ?, ?)", ep.UUID, wsID, ep.Name, nullString(ep.Color))
+			if err != nil {
+				return duplicate(err, fmt.Sprintf("project %q", ep.Name))
+			}
+			id, _ = r.LastInsertId()
+			res.Projects++
+		}
+		projectIDs[ep.UUID] = id
+	}
+	for _, et := range ew.Tasks {
+		id, err := importTask(ctx, tx, wsID, projectIDs, et, res)
+		if err != nil {
+			return fmt.Errorf("task %q: %w", et.Title, err)
+		}
+		taskIDs[et.UUID] = id
+	}
+	return nil
+}
+
+func importTask(ctx context.Context, tx DBTX, wsID int64, projectIDs map[string]int64, et models.ExportTask, res *ImportResult) (int64, error) {
+	if et.UUID == "" {
+		return 0, fmt.Errorf("task without uuid")
+	}
+	var projectID *int64
+	if et.Project != "" {
+		id, ok := projectIDs[et.Project]
+		if !ok {
+			return 0, fmt.Errorf("project %s %w in the file", et.Project, ErrNotFound)
+		}
+		projectID = &id
+	}
+	pri, err := nullPriority(et.Priority)
+	if err != nil {
+		return 0, err
+	}
+	status, err := resolveTaskStatus(ctx, tx, wsID, et.Status)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	var updated time.Time
+	err = tx.QueryRowContext(ctx, "SELECT id, updated_at FROM tasks WHERE uuid = ?", et.UUID).Scan(&id, &updated)
+	switch {
+	case err == nil && !et.UpdatedAt.Truncate(time.Second).After(updated):
+		res.TasksSkipped++
+		return id, importNotes(ctx, tx, id, et.Notes)
+	case err == nil:
+		_, err = tx.ExecContext(ctx,
+			`UPDATE tasks SET workspace_id = ?, project_id = ?, title = ?, description = ?, status = ?, priority = ?, due_date = ?,
+			 estimate_minutes = ?, estimate_points = ?, updated_at = ? WHERE id = ?`,
+			wsID, projectID, et.Title, et.Description, status, pri, nullTime(et.DueDate),
+			et.EstimateMinutes, et.EstimatePoints, sqlTime(et.UpdatedAt), id)
+		if err != nil {
+			return 0, err
+		}
+		for _, q := range []string{"DELETE FROM task_tags WHERE task_id = ?", "DELETE FROM task_field_values WHERE task_id = ?"} {
+			if _, err := tx.ExecContext(ctx, q, id); err != nil {
+				return 0, err
+			}
+		}
+		res.TasksUpdated++
+	default:
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+		r, err := tx.ExecContext(ctx,
+			`INSERT INTO tasks (uuid, workspace_id, project_id, title, description, status, priority, due_date,
+			 estimate_minutes, estimate_points, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			et.UUID, wsID, projectID, et.Title, et.Description, status, pri, nullTime(et.DueDate),
+			et.EstimateMinutes, et.EstimatePoints, sqlTime(et.CreatedAt), sqlTime(et.UpdatedAt))
+		if err != nil {
+			return 0, err
+		}
+		id, _ = r.LastInsertId()
+		res.TasksAdded++
+	}
+	if len(et.Tags) > 0 {
+		if _, err := AddTaskTags(ctx, tx, id, et.Tags); err != nil {
+			return 0, err
+		}
+	}
+	if len(et.Fields) > 0 {
+		values := map[string]string{}
+		for name, v := range et.Fields {
+			values[name] = FormatFieldValue(v)
+		}
+		changes, err := resolveFieldValues(ctx, tx, wsID, values)
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range changes {
+			if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO task_field_values (task_id, field_id, value) VALUES (?, ?, ?)", id, c.fieldID, c.value); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return id, importNotes(ctx, tx, id, et.Notes)
+}
+
+func importNotes(ctx context.Context, tx DBTX, taskID int64, notes []models.ExportNote) error {
+	for _, n := range notes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO task_notes (task_id, body, created_at) SELECT ?, ?, ?
+			 WHERE NOT EXISTS (SELECT 1 FROM task_notes WHERE task_id = ? AND body = ? AND created_at = ?)`,
+			taskID, n.Body, sqlTime(n.CreatedAt), taskID, n.Body, sqlTime(n.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
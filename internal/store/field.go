@@ -0,0 +1,411 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// FieldTypes lists the supported custom field types.
+var FieldTypes = []string{"text", "number", "date", "enum", "bool"}
+
+const fieldColumns = "id, workspace_id, name, type, options, position, created_at"
+
+func scanField(row interface{ Scan(...any) error }) (models.CustomField, error) {
+	var f models.CustomField
+	var options sql.NullString
+	if err := row.Scan(&f.ID, &f.WorkspaceID, &f.Name, &f.Type, &options, &f.Position, &f.CreatedAt); err != nil {
+		return models.CustomField{}, err
+	}
+	if options.String != "" {
+		f.Options = strings.Split(options.String, ",")
+	}
+	return f, nil
+}
+
+// ListFields returns the workspace's custom fields in display order.
+func ListFields(ctx context.Context, db DBTX, workspaceID int64) ([]models.CustomField, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+fieldColumns+" FROM custom_fields WHERE workspace_id = ? ORDER BY position, id", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.CustomField
+	for rows.Next() {
+		f, err := scanField(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+func GetField(ctx context.Context, db DBTX, id int64) (models.CustomField, error) {
+	f, err := scanField(db.QueryRowContext(ctx, "SELECT "+fieldColumns+" FROM custom_fields WHERE id = ?", id))
+	return f, notFound(err, fmt.Sprintf("field %d", id))
+}
+
+func GetFieldByName(ctx context.Context, db DBTX, workspaceID int64, name string) (models.CustomField, error) {
+	f, err := scanField(db.QueryRowContext(ctx, "SELECT "+fieldColumns+" FROM custom_fields WHERE workspace_id = ? AND name = ?", workspaceID, name))
+	return f, notFound(err, fmt.Sprintf("field %q", name))
+}
+
+// CreateField adds a custom field to the workspace. options are required for (and only allowed on) enums.
+func CreateField(ctx context.Context, db DBTX, workspaceID int64, name, fieldType string, options []string) (models.CustomField, error) {
+	if err := validFieldName(name); err != nil {
+		return models.CustomField{}, err
+	}
+	known := false
+	for _, t := range FieldTypes {
+		known = known || t == fieldType
+	}
+	if !known {
+		return models.CustomField{}, ErrInvalidField{Field: "field type", Value: fieldType, Reason: oneOf(FieldTypes)}
+	}
+	opts, err := fieldOptions(fieldType, options)
+	if err != nil {
+		return models.CustomField{}, err
+	}
+	res, err := exec(ctx, db,
+		`INSERT INTO custom_fields (workspace_id, name, type, options, position)
+		 VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM custom_fields WHERE workspace_id = ?))`,
+		workspaceID, name, fieldType, nullString(opts), workspaceID,
+	)
+	if err != nil {
+		return models.CustomField{}, duplicate(err, fmt.Sprintf("field %q", name))
+	}
+	id, _ := res.LastInsertId()
+	return GetField(ctx, db, id)
+}
+
+// UpdateField renames a field and, for enums, replaces its options. Options still used by a task cannot be removed.
+func UpdateField(ctx context.Context, db DBTX, id int64, name string, options []string) (models.CustomField, error) {
+	f, err := GetField(ctx, db, id)
+	if err != nil {
+		return models.CustomField{}, err
+	}
+	if err := validFieldName(name); err != nil {
+		return models.CustomField{}, err
+	}
+	opts := strings.Join(f.Options, ",")
+	if options != nil {
+		if opts, err = fieldOptions(f.Type, options); err != nil {
+			return models.CustomField{}, err
+		}
+		keep := map[string]bool{}
+		for _, o := range strings.Split(opts, ",") {
+			keep[o] = true
+		}
+		for _, o := range f.Options {
+			if keep[o] {
+				continue
+			}
+			var n int
+			if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM task_field_values WHERE field_id = ? AND value = ?", id, o).Scan(&n); err != nil {
+				return models.CustomField{}, err
+			}
+			if n > 0 {
+				return models.CustomField{}, fmt.Errorf("option %q is used by %d task(s)", o, n)
+			}
+		}
+	}
+	if _, err := exec(ctx, db, "UPDATE custom_fields SET name = ?, options = ? WHERE id = ?", name, nullString(opts), id); err != nil {
+		return models.CustomField{}, duplicate(err, fmt.Sprintf("field %q", name))
+	}
+	return GetField(ctx, db, id)
+}
+
+// DeleteField removes a field and its values on all tasks.
+func DeleteField(ctx context.Context, db DBTX, id int64) error {
+	res, err := exec(ctx, db, "DELETE FROM custom_fields WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, fmt.Sprintf("field %d", id))
+}
+
+func validFieldName(name string) error {
+	if name == "" {
+		return fmt.Errorf("field name is empty")
+	}
+	for _, c := range name {
+		if !(c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return ErrInvalidField{Field: "field name", Value: name, Reason: "use letters, digits, - and _"}
+		}
+	}
+	return nil
+}
+
+func fieldOptions(fieldType string, options []string) (string, error) {
+	var clean []string
+	for _, o := range options {
+		if o = strings.TrimSpace(o); o != "" {
+			clean = append(clean, o)
+		}
+	}
+	if fieldType != "enum" {
+		if len(clean) > 0 {
+			return "", fmt.Errorf("only enum fields have options")
+		}
+		return "", nil
+	}
+	if len(clean) == 0 {
+		return "", fmt.Errorf("an enum field needs --options (e.g. --options low,high)")
+	}
+	return strings.Join(clean, ","), nil
+}
+
+// parseFieldValue validates raw for the field's type and returns the value to store.
+func parseFieldValue(f models.CustomField, raw string) (any, error) {
+	switch f.Type {
+	case "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, ErrInvalidField{Field: f.Name, Value: raw, Reason: "not a number"}
+		}
+		return n, nil
+	case "date":
+		d, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, ErrInvalidField{Field: f.Name, Value: raw, Reason: "use YYYY-MM-DD"}
+		}
+		return d.Format("2006-01-02"), nil
+	case "enum":
+		for _, o := range f.Options {
+			if strings.EqualFold(o, raw) {
+				return o, nil
+			}
+		}
+		return nil, ErrInvalidField{Field: f.Name, Value: raw, Reason: oneOf(f.Options)}
+	case "bool":
+		switch strings.ToLower(raw) {
+		case "1", "true", "yes", "y", "on":
+			return true, nil
+		case "0", "false", "no", "n", "off":
+			return false, nil
+		}
+		return nil, ErrInvalidField{Field: f.Name, Value: raw, Reason: "use yes or no"}
+	}
+	return raw, nil
+}
+
+type fieldChange struct {
+	fieldID int64
+	value   any // nil = clear
+}
+
+// resolveFieldValues validates values (field name → raw value) against the workspace's fields.
+func resolveFieldValues(ctx context.Context, db DBTX, workspaceID int64, values map[string]string) ([]fieldChange, error) {
+	var changes []fieldChange
+	for name, raw := range values {
+		f, err := GetFieldByName(ctx, db, workspaceID, name)
+		if err != nil {
+			return nil, err
+		}
+		c := fieldChange{fieldID: f.ID}
+		if raw = strings.TrimSpace(raw); raw != "" {
+			if c.value, err = parseFieldValue(f, raw); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// CheckTaskFields validates custom field values without writing them, so a task can be
+// rejected before it is created.
+func CheckTaskFields(ctx context.Context, db DBTX, workspaceID int64, values map[string]string) error {
+	_, err := resolveFieldValues(ctx, db, workspaceID, values)
+	return err
+}
+
+// SetTaskFields sets custom field values by field name; an empty value clears the field.
+// All values are validated before any is written.
+func SetTaskFields(ctx context.Context, db DBTX, taskID int64, values map[string]string) (models.Task, error) {
+	var t models.Task
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		t, err := GetTask(ctx, tx, taskID)
+		if err != nil {
+			return err
+		}
+		changes, err := resolveFieldValues(ctx, tx, t.WorkspaceID, values)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if c.value == nil {
+				_, err = tx.ExecContext(ctx, "DELETE FROM task_field_values WHERE task_id = ? AND field_id = ?", taskID, c.fieldID)
+			} else {
+				_, err = tx.ExecContext(ctx,
+					`INSERT INTO task_field_values (task_id, field_id, value) VALUES (?, ?, ?)
+					 ON CONFLICT (task_id, field_id) DO UPDATE SET value = excluded.value`,
+					taskID, c.fieldID, c.value,
+				)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE tasks SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", taskID)
+		return err
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	t, err = GetTask(ctx, db, taskID)
+	return t, err
+}
+
+// FormatFieldValue renders a value from Task.Fields for display.
+func FormatFieldValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// FieldFilter is a condition on a custom field, e.g. customer=acme or points>=3.
+type FieldFilter struct {
+	Name  string
+	Op    string // =, !=, <, <=, >, >=, ~ (contains, case-insensitive)
+	Value string
+}
+
+// ParseFieldFilter parses "name<op>value".
+func ParseFieldFilter(s string) (FieldFilter, error) {
+	i := strings.IndexAny(s, "=!<>~")
+	if i <= 0 {
+		return FieldFilter{}, fmt.Errorf("filter %q: use name=value (or !=, <, <=, >, >=, ~)", s)
+	}
+	op := s[i : i+1]
+	if i+1 < len(s) && s[i+1] == '=' && op != "=" && op != "~" {
+		op += "="
+	}
+	if op == "!" {
+		return FieldFilter{}, fmt.Errorf("filter %q: use != for not equal", s)
+	}
+	return FieldFilter{Name: strings.TrimSpace(s[:i]), Op: op, Value: strings.TrimSpace(s[i+len(op):])}, nil
+}
+
+// FilterTasks keeps the tasks matching all filters. Values are compared by the field's type;
+// a task without a value only matches "!=".
+func FilterTasks(tasks []models.Task, fields []models.CustomField, filters []FieldFilter) ([]models.Task, error) {
+	byName := map[string]models.CustomField{}
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+	want := make([]any, len(filters))
+	for i, flt := range filters {
+		f, ok := byName[flt.Name]
+		if !ok {
+			return nil, fmt.Errorf("field %q is not defined in this workspace (see: todo field list)", flt.Name)
+		}
+		if flt.Op == "~" {
+			want[i] = strings.ToLower(flt.Value)
+			continue
+		}
+		v, err := parseFieldValue(f, flt.Value)
+		if err != nil {
+			return nil, err
+		}
+		want[i] = v
+	}
+	var out []models.Task
+next:
+	for _, t := range tasks {
+		for i, flt := range filters {
+			v, ok := t.Fields[flt.Name]
+			if !ok {
+				if flt.Op != "!=" {
+					continue next
+				}
+				continue
+			}
+			if flt.Op == "~" {
+				if !strings.Contains(strings.ToLower(FormatFieldValue(v)), want[i].(string)) {
+					continue next
+				}
+				continue
+			}
+			c := compareFieldValues(v, want[i])
+			ok = false
+			switch flt.Op {
+			case "=":
+				ok = c == 0
+			case "!=":
+				ok = c != 0
+			case "<":
+				ok = c < 0
+			case "<=":
+				ok = c <= 0
+			case ">":
+				ok = c > 0
+			case ">=":
+				ok = c >= 0
+			}
+			if !ok {
+				continue next
+			}
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// SortTasksByField orders tasks by a custom field; tasks without a value come last either way.
+func SortTasksByField(tasks []models.Task, name string, desc bool) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, okA := tasks[i].Fields[name]
+		b, okB := tasks[j].Fields[name]
+		if !okA || !okB {
+			return okA && !okB
+		}
+		if desc {
+			return compareFieldValues(a, b) > 0
+		}
+		return compareFieldValues(a, b) < 0
+	})
+}
+
+// compareFieldValues compares two values of the same field (false < true, numbers numerically, the rest as text).
+func compareFieldValues(a, b any) int {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case !a:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(FormatFieldValue(a), FormatFieldValue(b))
+}
@@ -0,0 +1,549 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// A directory store (see Open) keeps the database as Markdown files, to read, grep and edit by hand:
+//
+//	<dir>/<workspace>/_workspace.md   the workspace, with its workflow and custom fields
+//	<dir>/<workspace>/_default.md     the tasks of its default list
+//	<dir>/<workspace>/<list>.md       a project/list and its tasks
+//	<dir>/_state.json                 settings, templates and sync bookkeeping
+//
+// Files start with front matter: "key: value" lines between "---" lines. A task is a checklist item
+// followed by front matter of its own and its description, both indented by two spaces:
+//
+//	- [ ] Write the migration
+//	  ---
+//	  id: 12
+//	  status: in_progress
+//	  tags: backend, db
+//	  ---
+//	  What needs doing, in as many lines as needed.
+//
+// Only the checklist line is required: a task added in an editor gets an ID and the workflow's first
+// status when it is loaded, and ticking or unticking its box moves it to the first done or open status.
+// Other lines (headings, notes to self) are not kept.
+
+const (
+	mdWorkspaceFile = "_workspace.md"
+	mdDefaultFile   = "_default.md"
+	mdTimeLayout    = time.RFC3339 // in UTC
+)
+
+// mdRecord is the front matter workspaces and lists have in common.
+type mdRecord struct {
+	ID      int64
+	UUID    string
+	Name    string
+	Color   string
+	Created time.Time
+}
+
+type mdWorkspace struct {
+	mdRecord
+	Statuses []models.ExportStatus
+	Fields   []models.ExportField
+	Lists    []*mdList // the default list first
+	dir      string    // directory name
+	modTime  time.Time // of _workspace.md, when it was read
+}
+
+type mdList struct {
+	mdRecord
+	Default bool
+	Tasks   []*mdTask
+	file    string // path relative to the store's directory
+	modTime time.Time
+}
+
+// mdTask is a task as its checklist item has it. Meta holds the optional keys of its front matter
+// (priority, due, estimate, tags, depends_on and field.<name>) as written; Notes, Time and Attachments
+// hold the values of its note, time and attachment lines.
+type mdTask struct {
+	ID          int64
+	UUID        string
+	Done        bool
+	Title       string
+	Description string
+	Status      string
+	Meta        map[string]string
+	Created     time.Time
+	Updated     time.Time
+	Notes       []string
+	Time        []string
+	Attachments []string
+	line        int
+}
+
+// mdTaskKeys are the optional task keys in the order they are written; custom fields follow.
+var mdTaskKeys = []string{"priority", "due", "estimate", "tags", "depends_on"}
+
+type mdPair struct {
+	Key, Value string
+	Line       int
+}
+
+var mdTaskLine = regexp.MustCompile(`^[-*+] \[([ xX])\](?:\s+(.*))?$`)
+
+// mdDefaultStatuses is the workflow of a workspace without _workspace.md, as seedStatusesSQL creates it.
+func mdDefaultStatuses() []models.ExportStatus {
+	return []models.ExportStatus{{Name: "todo"}, {Name: "in_progress"}, {Name: "done", IsDone: true}}
+}
+
+func renderMDWorkspace(w *mdWorkspace) []byte {
+	var b bytes.Buffer
+	writeFrontMatter(&b, "", append(w.pairs(), w.workflowPairs()...))
+	fmt.Fprintf(&b, "\n# %s\n", w.Name)
+	return b.Bytes()
+}
+
+func (w *mdWorkspace) workflowPairs() []mdPair {
+	var pairs []mdPair
+	for _, s := range w.Statuses {
+		key := "status"
+		if s.IsDone {
+			key = "done"
+		}
+		pairs = append(pairs, mdPair{Key: key, Value: mdJoin(s.Name, s.Color)})
+	}
+	for _, f := range w.Fields {
+		pairs = append(pairs, mdPair{Key: "field", Value: mdJoin(f.Name+" "+f.Type, strings.Join(f.Options, ", "))})
+	}
+	return pairs
+}
+
+func renderMDList(l *mdList, fields []models.ExportField) []byte {
+	var b bytes.Buffer
+	title := "Default list"
+	if !l.Default {
+		writeFrontMatter(&b, "", l.pairs())
+		b.WriteByte('\n')
+		title = l.Name
+	}
+	fmt.Fprintf(&b, "# %s\n", title)
+	for _, t := range l.Tasks {
+		b.WriteByte('\n')
+		renderMDTask(&b, t, fields)
+	}
+	return b.Bytes()
+}
+
+func renderMDTask(b *bytes.Buffer, t *mdTask, fields []models.ExportField) {
+	box := " "
+	if t.Done {
+		box = "x"
+	}
+	fmt.Fprintf(b, "- [%s] %s\n", box, strings.Join(strings.Fields(t.Title), " "))
+	pairs := []mdPair{{Key: "id", Value: strconv.FormatInt(t.ID, 10)}, {Key: "uuid", Value: t.UUID}, {Key: "status", Value: t.Status}}
+	for _, key := range mdTaskKeys {
+		if v := t.Meta[key]; v != "" {
+			pairs = append(pairs, mdPair{Key: key, Value: v})
+		}
+	}
+	for _, f := range fields {
+		if v := t.Meta["field."+f.Name]; v != "" {
+			pairs = append(pairs, mdPair{Key: "field." + f.Name, Value: v})
+		}
+	}
+	pairs = append(pairs, mdTimePair("created", t.Created), mdTimePair("updated", t.Updated))
+	for _, v := range t.Notes {
+		pairs = append(pairs, mdPair{Key: "note", Value: v})
+	}
+	for _, v := range t.Time {
+		pairs = append(pairs, mdPair{Key: "time", Value: v})
+	}
+	for _, v := range t.Attachments {
+		pairs = append(pairs, mdPair{Key: "attachment", Value: v})
+	}
+	writeFrontMatter(b, "  ", pairs)
+	if t.Description == "" {
+		return
+	}
+	for _, line := range strings.Split(t.Description, "\n") {
+		if strings.TrimSpace(line) == "" {
+			b.WriteByte('\n')
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+}
+
+func (r mdRecord) pairs() []mdPair {
+	pairs := []mdPair{{Key: "id", Value: strconv.FormatInt(r.ID, 10)}, {Key: "uuid", Value: r.UUID}, {Key: "name", Value: r.Name}}
+	if r.Color != "" {
+		pairs = append(pairs, mdPair{Key: "color", Value: r.Color})
+	}
+	return append(pairs, mdTimePair("created", r.Created))
+}
+
+func mdTimePair(key string, t time.Time) mdPair {
+	return mdPair{Key: key, Value: formatMDTime(t)}
+}
+
+func writeFrontMatter(b *bytes.Buffer, indent string, pairs []mdPair) {
+	b.WriteString(indent + "---\n")
+	for _, p := range pairs {
+		if p.Value != "" {
+			fmt.Fprintf(b, "%s%s: %s\n", indent, p.Key, p.Value)
+		}
+	}
+	b.WriteString(indent + "---\n")
+}
+
+// mdJoin adds the optional part of a value after " | " (e.g. a status's color).
+func mdJoin(value, extra string) string {
+	if extra == "" {
+		return value
+	}
+	return value + " | " + extra
+}
+
+func formatMDTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(mdTimeLayout)
+}
+
+func parseMDTime(s string) (time.Time, error) {
+	t, err := time.Parse(mdTimeLayout, s)
+	if err != nil {
+		return t, fmt.Errorf("time %q: use e.g. 2006-01-02T15:04:05Z", s)
+	}
+	return t, nil
+}
+
+// formatMDDue writes a due date as a date, or as a time when it has one.
+func formatMDDue(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return formatMDTime(*t)
+}
+
+func parseMDDue(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(mdTimeLayout, s)
+	if err != nil {
+		return nil, ErrInvalidField{Field: "due", Value: s, Reason: "use YYYY-MM-DD"}
+	}
+	return &t, nil
+}
+
+// mdTokens splits the value of a note, time or attachment line into words and quoted strings.
+func mdTokens(s string) ([]string, error) {
+	var tokens []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("bad quoted text in %q", s)
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, text)
+			s = s[len(quoted):]
+			continue
+		}
+		word, rest, _ := strings.Cut(s, " ")
+		tokens = append(tokens, word)
+		s = rest
+	}
+	return tokens, nil
+}
+
+// parseFrontMatter reads the "---" block at the start of lines, if there is one, and returns its pairs
+// and the number of lines it takes. first is the line number of lines[0], for errors.
+func parseFrontMatter(file string, lines []string, first int) ([]mdPair, int, error) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return nil, 0, nil
+	}
+	var pairs []mdPair
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "---":
+			return pairs, i + 1, nil
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, 0, fmt.Errorf("%s:%d: expected key: value", file, first+i)
+		}
+		pairs = append(pairs, mdPair{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value), Line: first + i})
+	}
+	return nil, 0, fmt.Errorf("%s:%d: front matter is not closed with ---", file, first)
+}
+
+func mdLines(data []byte) []string {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// set applies a front matter key workspaces and lists have in common; it reports whether p was one.
+func (r *mdRecord) set(p mdPair) (bool, error) {
+	var err error
+	switch p.Key {
+	case "id":
+		r.ID, err = strconv.ParseInt(p.Value, 10, 64)
+	case "uuid":
+		r.UUID = p.Value
+	case "name":
+		r.Name = p.Value
+	case "color":
+		r.Color = p.Value
+	case "created":
+		r.Created, err = parseMDTime(p.Value)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+func parseMDWorkspace(file string, data []byte, w *mdWorkspace) error {
+	pairs, _, err := parseFrontMatter(file, mdLines(data), 1)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		ok, err := w.set(p)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", file, p.Line, p.Key, err)
+		}
+		if ok {
+			continue
+		}
+		head, extra, _ := strings.Cut(p.Value, "|")
+		head, extra = strings.TrimSpace(head), strings.TrimSpace(extra)
+		switch p.Key {
+		case "status", "done":
+			w.Statuses = append(w.Statuses, models.ExportStatus{Name: head, Color: extra, IsDone: p.Key == "done"})
+		case "field":
+			name, typ, ok := strings.Cut(head, " ")
+			if !ok {
+				return fmt.Errorf("%s:%d: expected field: NAME TYPE [| OPTIONS]", file, p.Line)
+			}
+			f := models.ExportField{Name: name, Type: strings.TrimSpace(typ)}
+			if extra != "" {
+				f.Options = strings.Split(extra, ",")
+				for i := range f.Options {
+					f.Options[i] = strings.TrimSpace(f.Options[i])
+				}
+			}
+			w.Fields = append(w.Fields, f)
+		default:
+			return fmt.Errorf("%s:%d: unknown key %q", file, p.Line, p.Key)
+		}
+	}
+	return nil
+}
+
+// parseMDList reads a list file of workspace w; its tasks' statuses are resolved against w's workflow.
+func parseMDList(file string, data []byte, w *mdWorkspace, l *mdList) error {
+	lines := mdLines(data)
+	pairs, n, err := parseFrontMatter(file, lines, 1)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		if ok, err := l.set(p); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", file, p.Line, p.Key, err)
+		} else if !ok {
+			return fmt.Errorf("%s:%d: unknown key %q", file, p.Line, p.Key)
+		}
+	}
+	for i := n; i < len(lines); i++ {
+		m := mdTaskLine.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		t := &mdTask{Done: m[1] != " ", Title: strings.TrimSpace(m[2]), Meta: map[string]string{}, line: i + 1}
+		if t.Title == "" {
+			return fmt.Errorf("%s:%d: task without a title", file, t.line)
+		}
+		// The task's block: the indented lines that follow, and blank lines between them.
+		var block []string
+		for i+1 < len(lines) {
+			next := lines[i+1]
+			if strings.TrimSpace(next) == "" {
+				block = append(block, "")
+			} else if rest, ok := strings.CutPrefix(next, "  "); ok {
+				block = append(block, rest)
+			} else if rest, ok := strings.CutPrefix(next, "\t"); ok {
+				block = append(block, rest)
+			} else {
+				break
+			}
+			i++
+		}
+		if err := parseMDTask(file, block, t); err != nil {
+			return err
+		}
+		if err := w.resolveStatus(file, t); err != nil {
+			return err
+		}
+		l.Tasks = append(l.Tasks, t)
+	}
+	return nil
+}
+
+func parseMDTask(file string, block []string, t *mdTask) error {
+	pairs, n, err := parseFrontMatter(file, block, t.line+1)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		switch p.Key {
+		case "id":
+			t.ID, err = strconv.ParseInt(p.Value, 10, 64)
+		case "uuid":
+			t.UUID = p.Value
+		case "status":
+			t.Status = p.Value
+		case "created":
+			t.Created, err = parseMDTime(p.Value)
+		case "updated":
+			t.Updated, err = parseMDTime(p.Value)
+		case "note":
+			t.Notes = append(t.Notes, p.Value)
+		case "time":
+			t.Time = append(t.Time, p.Value)
+		case "attachment":
+			t.Attachments = append(t.Attachments, p.Value)
+		case "priority", "due", "estimate", "tags", "depends_on":
+			t.Meta[p.Key] = p.Value
+		default:
+			if !strings.HasPrefix(p.Key, "field.") {
+				return fmt.Errorf("%s:%d: unknown key %q", file, p.Line, p.Key)
+			}
+			t.Meta[p.Key] = p.Value
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", file, p.Line, p.Key, err)
+		}
+	}
+	t.Description = strings.Trim(strings.Join(block[n:], "\n"), "\n")
+	return nil
+}
+
+// resolveStatus checks the task's status against the workflow. A task without one, or whose box
+// disagrees with it, gets the first done or open status.
+func (w *mdWorkspace) resolveStatus(file string, t *mdTask) error {
+	var open, done string
+	known := false
+	for _, s := range w.Statuses {
+		if s.Name == t.Status {
+			if s.IsDone == t.Done {
+				return nil
+			}
+			known = true
+		}
+		if s.IsDone && done == "" {
+			done = s.Name
+		} else if !s.IsDone && open == "" {
+			open = s.Name
+		}
+	}
+	if t.Status != "" && !known {
+		var names []string
+		for _, s := range w.Statuses {
+			names = append(names, s.Name)
+		}
+		return fmt.Errorf("%s:%d: %w", file, t.line, ErrInvalidField{Field: "status", Value: t.Status, Reason: oneOf(names)})
+	}
+	switch {
+	case t.Done && done != "":
+		t.Status = done
+	case !t.Done && open != "":
+		t.Status = open
+	case t.Status == "" && len(w.Statuses) > 0:
+		t.Status = w.Statuses[0].Name
+	}
+	return nil
+}
+
+// syncTexts calls fn with the text of each field sync keeps a clock for, for the workspace, its lists
+// and its tasks (those with a UUID), and the time of the file it is in. Comparing them with the texts
+// last written shows which fields were edited in the files.
+func (w *mdWorkspace) syncTexts(fn func(uuid, kind, field, text string, at time.Time)) {
+	if w.UUID != "" {
+		fn(w.UUID, "workspace", "name", w.Name, w.modTime)
+		fn(w.UUID, "workspace", "color", w.Color, w.modTime)
+		var statuses, fields []string
+		for _, p := range w.workflowPairs() {
+			if p.Key == "field" {
+				fields = append(fields, p.Value)
+			} else {
+				statuses = append(statuses, p.Key+": "+p.Value)
+			}
+		}
+		fn(w.UUID, "workspace", "statuses", strings.Join(statuses, "\n"), w.modTime)
+		fn(w.UUID, "workspace", "fields", strings.Join(fields, "\n"), w.modTime)
+	}
+	for _, l := range w.Lists {
+		if !l.Default && l.UUID != "" {
+			fn(l.UUID, "project", "workspace", w.UUID, l.modTime)
+			fn(l.UUID, "project", "name", l.Name, l.modTime)
+			fn(l.UUID, "project", "color", l.Color, l.modTime)
+		}
+		for _, t := range l.Tasks {
+			if t.UUID == "" {
+				continue
+			}
+			for field, text := range map[string]string{
+				"workspace": w.UUID, "project": l.UUID, "title": t.Title, "description": t.Description,
+				"status": t.Status, "priority": t.Meta["priority"], "due_date": t.Meta["due"],
+				"estimate_minutes": t.Meta["estimate"], "estimate_points": t.Meta["estimate"],
+				"tags": t.Meta["tags"], "depends_on": t.Meta["depends_on"],
+			} {
+				fn(t.UUID, "task", field, text, l.modTime)
+			}
+			for _, f := range w.Fields {
+				fn(t.UUID, "task", "field:"+f.Name, t.Meta["field."+f.Name], l.modTime)
+			}
+		}
+	}
+}
+
+// mdDigest shortens a field's text for the sync bookkeeping in _state.json.
+func mdDigest(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// mdFileName makes name usable as a file or directory name: path separators and characters some
+// systems reject become "-", and so does a leading dot or underscore (hidden files and the store's own).
+func mdFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name[0] == '.' || name[0] == '_' {
+		name = "-" + strings.TrimLeft(name, "._")
+	}
+	return name
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// A memory store keeps its data somewhere SQLite can't work on directly (a directory of Markdown files,
+// an encrypted file): Open loads it into an in-memory database, which every store function works on as
+// usual, and Save or Close saves it back.
+type memStore interface {
+	// load fills the new database, leaving it migrated; name is its memdb name (see memDSN).
+	load(ctx context.Context, db *sql.DB, conn *sql.Conn, name string) error
+	save(ctx context.Context, db *sql.DB, conn *sql.Conn) error
+	// reopen loads the data into a new in-memory database if someone else changed it since it was
+	// loaded or saved; it returns nil if not.
+	reopen(ctx context.Context) (*sql.DB, error)
+}
+
+// settingStorePath holds the directory or file of a memory store in its in-memory database. Stores don't
+// save it, so it always names where the data was loaded from.
+const settingStorePath = "store.path"
+
+type memDB struct {
+	store memStore
+	conn  *sql.Conn // keeps the in-memory database alive
+	name  string    // see memDSN
+}
+
+var (
+	memStores sync.Map // *sql.DB -> *memDB
+	memSeq    atomic.Int64
+)
+
+// openMemory opens a new in-memory database loaded from st.
+func openMemory(ctx context.Context, st memStore) (*sql.DB, error) {
+	// A memdb database is shared by all connections of the pool, and lives while one of them is open.
+	name := fmt.Sprintf("/todo-mem-%d", memSeq.Add(1))
+	db, err := sql.Open("sqlite", memDSN(name)+"&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate")
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := st.load(ctx, db, conn, name); err != nil {
+		conn.Close()
+		db.Close()
+		return nil, err
+	}
+	memStores.Store(db, &memDB{store: st, conn: conn, name: name})
+	return db, nil
+}
+
+// memDSN returns the URI of the memdb database name, which other connections of the process can open
+// while it is alive.
+func memDSN(name string) string {
+	return "file:" + name + "?vfs=memdb"
+}
+
+// Close closes a database opened by Open. A directory store or an encrypted database is saved first.
+func Close(db *sql.DB) error {
+	v, ok := memStores.LoadAndDelete(db)
+	if !ok {
+		return db.Close()
+	}
+	m := v.(*memDB)
+	err := m.store.save(context.Background(), db, m.conn)
+	m.conn.Close()
+	return errors.Join(err, db.Close())
+}
+
+// Save writes a directory store or an encrypted database back now, like Close. Other databases have
+// every commit on disk already.
+func Save(ctx context.Context, db *sql.DB) error {
+	v, ok := memStores.Load(db)
+	if !ok {
+		return nil
+	}
+	m := v.(*memDB)
+	return m.store.save(ctx, db, m.conn)
+}
+
+// Reload loads a directory store or an encrypted database again if someone else changed its files
+// since it was loaded or saved, e.g. another todo, and reports whether it did. Changes not saved yet
+// are lost: Save first.
+func Reload(ctx context.Context, db *sql.DB) (bool, error) {
+	v, ok := memStores.Load(db)
+	if !ok {
+		return false, nil
+	}
+	m := v.(*memDB)
+	fresh, err := m.store.reopen(ctx)
+	if err != nil || fresh == nil {
+		return false, err
+	}
+	fv, _ := memStores.LoadAndDelete(fresh)
+	f := fv.(*memDB)
+	defer fresh.Close()
+	defer f.conn.Close()
+	// Copy it over db's in-memory database, which all of db's connections share.
+	err = rawSQLite(f.conn, func(c sqliteConn) error {
+		b, err := c.NewBackup(memDSN(m.name))
+		if err != nil {
+			return err
+		}
+		return runBackup(b)
+	})
+	if err != nil {
+		return false, err
+	}
+	m.store = f.store
+	return true, nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cli-todo/internal/models"
+)
+
+func TestSaveAndReload(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		path func(t *testing.T) string
+	}{
+		{"dir", func(t *testing.T) string { return DirPrefix + t.TempDir() }},
+		{"encrypted", func(t *testing.T) string {
+			path := filepath.Join(t.TempDir(), "todo.db")
+			closeTest(t, openTest(t, path))
+			if _, err := EncryptFile(path, "secret"); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(PassphraseEnv, "secret")
+			return path
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := tc.path(t)
+			db := openTest(t, path)
+			defer closeTest(t, db)
+			w := must(CreateWorkspace(ctx, db, "Work"))(t)
+			if err := Save(ctx, db); err != nil {
+				t.Fatal(err)
+			}
+
+			// Another todo sees what was saved; what it saves, Reload brings in.
+			other := openTest(t, path)
+			if _, err := GetWorkspaceByName(ctx, other, "Work"); err != nil {
+				t.Errorf("saved workspace: %v", err)
+			}
+			must(CreateTask(ctx, other, w.ID, nil, "from the CLI", "", "", models.PriorityNone, nil))(t)
+			closeTest(t, other)
+			if !must(Reload(ctx, db))(t) {
+				t.Fatal("not reloaded after another todo saved")
+			}
+			if got := taskTitles(must(ListTasks(ctx, db, w.ID, nil))(t)); !slices.Equal(got, []string{"from the CLI"}) {
+				t.Errorf("tasks %v after the reload, want [from the CLI]", got)
+			}
+			if must(Reload(ctx, db))(t) {
+				t.Error("reloaded again without changes")
+			}
+
+			// Saving on top of the reloaded files is no conflict.
+			must(CreateTask(ctx, db, w.ID, nil, "from the TUI", "", "", models.PriorityNone, nil))(t)
+			if err := Save(ctx, db); err != nil {
+				t.Fatal(err)
+			}
+			if must(Reload(ctx, db))(t) {
+				t.Error("reloaded its own save")
+			}
+		})
+	}
+}
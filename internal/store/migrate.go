@@ -1,26 +1,255 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"fmt"
 	"io/fs"
+	"strings"
 )
 
 //go:embed schema.sql
 var schemaFS embed.FS
 
+// schemaVersion is the schema Migrate brings databases to, recorded in PRAGMA user_version. Raise it with
+// every change to the schema, so Open snapshots databases before migrating them.
+const schemaVersion = 2
+
 // Migrate runs the schema migration.
-func Migrate(db *sql.DB) error {
+func Migrate(ctx context.Context, db *sql.DB) error {
 	sqlBytes, err := fs.ReadFile(schemaFS, "schema.sql")
 	if err != nil {
 		return err
 	}
-	if _, err := db.Exec(string(sqlBytes)); err != nil {
+	if _, err := exec(ctx, db, string(sqlBytes)); err != nil {
+		return err
+	}
+	// Drop the hard-coded status CHECK from DBs created before per-workspace workflows.
+	rebuilt, err := dropTaskStatusCheck(ctx, db)
+	if err != nil {
 		return err
 	}
+	if rebuilt {
+		// Dropping the old tasks table dropped its triggers; create them again.
+		if _, err := exec(ctx, db, string(sqlBytes)); err != nil {
+			return err
+		}
+	}
 	// Add workspace color column for DBs created before this field existed.
-	_, _ = db.Exec("ALTER TABLE workspaces ADD COLUMN color TEXT")
+	_, _ = exec(ctx, db, "ALTER TABLE workspaces ADD COLUMN color TEXT")
 	// Add project color column for DBs created before this field existed.
-	_, _ = db.Exec("ALTER TABLE projects ADD COLUMN color TEXT")
+	_, _ = exec(ctx, db, "ALTER TABLE projects ADD COLUMN color TEXT")
+	// Add task estimate columns for DBs created before estimates existed.
+	_, _ = exec(ctx, db, "ALTER TABLE tasks ADD COLUMN estimate_minutes INTEGER")
+	_, _ = exec(ctx, db, "ALTER TABLE tasks ADD COLUMN estimate_points REAL")
+	if err := migrateUUIDs(ctx, db); err != nil {
+		return err
+	}
+	if err := migrateSyncTriggers(ctx, db); err != nil {
+		return err
+	}
+	// Give every workspace without a workflow the default statuses.
+	if _, err := exec(ctx, db, seedStatusesSQL+" WHERE NOT EXISTS (SELECT 1 FROM statuses s WHERE s.workspace_id = w.id)"); err != nil {
+		return err
+	}
+	// Index tasks created before full-text search existed.
+	var indexed, total int
+	if err := db.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM tasks_fts_docsize), (SELECT COUNT(*) FROM tasks)").Scan(&indexed, &total); err != nil {
+		return err
+	}
+	if indexed != total {
+		if _, err := exec(ctx, db, "INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild')"); err != nil {
+			return err
+		}
+	}
+	if _, err := exec(ctx, db, fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// migrationPending reports whether db has data in an older schema version, which Migrate would change.
+func migrationPending(ctx context.Context, db DBTX) (bool, error) {
+	var version, tables int
+	err := db.QueryRowContext(ctx, "SELECT (SELECT user_version FROM pragma_user_version), "+
+		"(SELECT COUNT(*) FROM sqlite_master WHERE type = 'table')").Scan(&version, &tables)
+	return tables > 0 && version < schemaVersion, err
+}
+
+// dropTaskStatusCheck rebuilds the tasks table without the old
+// CHECK (status IN ('todo', 'in_progress', 'done')) constraint, which SQLite cannot drop in place.
+// It reports whether the table was rebuilt.
+func dropTaskStatusCheck(ctx context.Context, db *sql.DB) (bool, error) {
+	var ddl string
+	if err := db.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'tasks'").Scan(&ddl); err != nil {
+		return false, err
+	}
+	if !strings.Contains(ddl, "CHECK (status IN") {
+		return false, nil
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	// foreign_keys must be off while the table is swapped, and cannot be changed inside a transaction.
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return false, err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	stmts := []string{
+		`CREATE TABLE tasks_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    status TEXT NOT NULL DEFAULT 'todo',
+    priority TEXT CHECK (priority IN ('low', 'medium', 'high') OR priority IS NULL),
+    due_date DATE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		`INSERT INTO tasks_new (id, workspace_id, project_id, title, description, status, priority, due_date, created_at, updated_at)
+SELECT id, workspace_id, project_id, title, description, status, priority, due_date, created_at, updated_at FROM tasks`,
+		"DROP TABLE tasks",
+		"ALTER TABLE tasks_new RENAME TO tasks",
+		"CREATE INDEX IF NOT EXISTS idx_tasks_workspace ON tasks(workspace_id)",
+		"CREATE INDEX IF NOT EXISTS idx_tasks_project ON tasks(project_id)",
+		"CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status)",
+		"CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date)",
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// newUUIDSQL generates a random (version 4) UUID in SQL.
+const newUUIDSQL = `lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+	substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))`
+
+// uuidTables are the tables whose rows get a UUID.
+var uuidTables = []string{"workspaces", "projects", "tasks"}
+
+// migrateUUIDs adds the uuid column to DBs created before it existed, gives existing rows one, and
+// installs the triggers that set it on insert (unless the insert brings its own, as import does).
+func migrateUUIDs(ctx context.Context, db *sql.DB) error {
+	for _, table := range uuidTables {
+		_, _ = exec(ctx, db, "ALTER TABLE "+table+" ADD COLUMN uuid TEXT")
+		stmts := []string{
+			"UPDATE " + table + " SET uuid = " + newUUIDSQL + " WHERE uuid IS NULL",
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_" + table + "_uuid ON " + table + "(uuid)",
+			"CREATE TRIGGER IF NOT EXISTS " + table + "_uuid AFTER INSERT ON " + table + " WHEN new.uuid IS NULL BEGIN\n" +
+				"    UPDATE " + table + " SET uuid = " + newUUIDSQL + " WHERE id = new.id;\nEND",
+		}
+		for _, s := range stmts {
+			if _, err := exec(ctx, db, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncClockNowSQL is when a field changed, to the millisecond (the format of syncClockLayout).
+const syncClockNowSQL = `strftime('%Y-%m-%d %H:%M:%f', 'now')`
+
+// syncClockUpsertSQL ends the triggers' inserts into sync_clocks. Not INSERT OR REPLACE: in a trigger,
+// the conflict handling of an upsert that fired it would override that, and the insert would fail.
+const syncClockUpsertSQL = "ON CONFLICT (uuid, field) DO UPDATE SET changed_at = excluded.changed_at"
+
+// syncColumn is a column of a synced table and the name sync gives the field.
+type syncColumn struct{ column, field string }
+
+// syncTables are the tables sync compares field by field, with the kind of record each holds.
+var syncTables = []struct {
+	table, kind string
+	columns     []syncColumn
+}{
+	{"workspaces", "workspace", []syncColumn{{"name", "name"}, {"color", "color"}}},
+	{"projects", "project", []syncColumn{{"workspace_id", "workspace"}, {"name", "name"}, {"color", "color"}}},
+	{"tasks", "task", []syncColumn{{"workspace_id", "workspace"}, {"project_id", "project"}, {"title", "title"},
+		{"description", "description"}, {"status", "status"}, {"priority", "priority"}, {"due_date", "due_date"},
+		{"estimate_minutes", "estimate_minutes"}, {"estimate_points", "estimate_points"}}},
+}
+
+// syncChildTables hold values sync treats as one field of the row they belong to (owner, through fk).
+var syncChildTables = []struct {
+	table, fk, owner, field string // field is an SQL expression; "row." refers to the changed row
+}{
+	{"task_tags", "task_id", "tasks", "'tags'"},
+	{"task_dependencies", "task_id", "tasks", "'depends_on'"},
+	{"task_field_values", "task_id", "tasks", "'field:' || (SELECT name FROM custom_fields WHERE id = row.field_id)"},
+	{"statuses", "workspace_id", "workspaces", "'statuses'"},
+	{"custom_fields", "workspace_id", "workspaces", "'fields'"},
+}
+
+// migrateSyncTriggers installs the triggers that record sync clocks and tombstones. They are created
+// here rather than in schema.sql because older databases only get the uuid column in migrateUUIDs.
+func migrateSyncTriggers(ctx context.Context, db *sql.DB) error {
+	// Schema version 1's triggers used INSERT OR REPLACE (see syncClockUpsertSQL); replace them.
+	var old []string
+	err := eachRow(ctx, db, "SELECT name FROM sqlite_master WHERE type = 'trigger' AND sql LIKE '%INSERT OR REPLACE INTO sync_%'", func(scan func(...any) error) error {
+		var name string
+		if err := scan(&name); err != nil {
+			return err
+		}
+		old = append(old, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var stmts []string
+	for _, name := range old {
+		stmts = append(stmts, "DROP TRIGGER "+quoteIdent(name))
+	}
+	for _, t := range syncTables {
+		var changed []string
+		for _, c := range t.columns {
+			changed = append(changed, fmt.Sprintf("SELECT '%s' AS field WHERE old.%s IS NOT new.%s", c.field, c.column, c.column))
+		}
+		stmts = append(stmts,
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_sync_update AFTER UPDATE ON "+t.table+" WHEN new.uuid IS NOT NULL BEGIN\n"+
+				"    INSERT INTO sync_clocks (uuid, field, changed_at)\n"+
+				"    SELECT new.uuid, field, "+syncClockNowSQL+" FROM ("+strings.Join(changed, " UNION ALL ")+") WHERE true\n"+
+				"    "+syncClockUpsertSQL+";\nEND",
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_sync_insert AFTER INSERT ON "+t.table+" WHEN new.uuid IS NOT NULL BEGIN\n"+
+				"    DELETE FROM sync_tombstones WHERE uuid = new.uuid;\nEND",
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_sync_delete AFTER DELETE ON "+t.table+" WHEN old.uuid IS NOT NULL BEGIN\n"+
+				"    INSERT INTO sync_tombstones (uuid, kind, deleted_at) VALUES (old.uuid, '"+t.kind+"', "+syncClockNowSQL+")\n"+
+				"    ON CONFLICT (uuid) DO UPDATE SET kind = excluded.kind, deleted_at = excluded.deleted_at;\n"+
+				"    DELETE FROM sync_clocks WHERE uuid = old.uuid;\nEND",
+		)
+	}
+	for _, c := range syncChildTables {
+		for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+			row := "new"
+			if event == "DELETE" {
+				row = "old"
+			}
+			// The owner may be gone already when the row is deleted by a cascade; then nothing is recorded.
+			stmts = append(stmts, fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_sync_%s AFTER %s ON %s BEGIN\n"+
+				"    INSERT INTO sync_clocks (uuid, field, changed_at)\n"+
+				"    SELECT uuid, field, %s FROM (SELECT o.uuid, %s AS field FROM %s o WHERE o.id = %s.%s)\n"+
+				"    WHERE uuid IS NOT NULL AND field IS NOT NULL\n"+
+				"    %s;\nEND",
+				c.table, strings.ToLower(event), event, c.table, syncClockNowSQL, strings.ReplaceAll(c.field, "row.", row+"."), c.owner, row, c.fk, syncClockUpsertSQL))
+		}
+	}
+	for _, s := range stmts {
+		if _, err := exec(ctx, db, s); err != nil {
+			return err
+		}
+	}
 	return nil
 }
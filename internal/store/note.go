@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// AddNote appends a note to a task. Notes cannot be edited afterwards.
+func AddNote(ctx context.Context, db DBTX, taskID int64, body string) (models.Note, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return models.Note{}, fmt.Errorf("note is empty")
+	}
+	if _, err := GetTask(ctx, db, taskID); err != nil {
+		return models.Note{}, err
+	}
+	res, err := exec(ctx, db, "INSERT INTO task_notes (task_id, body) VALUES (?, ?)", taskID, body)
+	if err != nil {
+		return models.Note{}, err
+	}
+	id, _ := res.LastInsertId()
+	var n models.Note
+	err = db.QueryRowContext(ctx, "SELECT id, task_id, body, created_at FROM task_notes WHERE id = ?", id).
+		Scan(&n.ID, &n.TaskID, &n.Body, &n.CreatedAt)
+	return n, err
+}
+
+// ListNotes returns a task's notes, oldest first.
+func ListNotes(ctx context.Context, db DBTX, taskID int64) ([]models.Note, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, task_id, body, created_at FROM task_notes WHERE task_id = ? ORDER BY id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.Note
+	for rows.Next() {
+		var n models.Note
+		if err := rows.Scan(&n.ID, &n.TaskID, &n.Body, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
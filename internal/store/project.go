@@ -1,27 +1,29 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/cli-todo/internal/models"
 )
 
-func CreateProject(db *sql.DB, workspaceID int64, name string) (models.Project, error) {
-	res, err := db.Exec("INSERT INTO projects (workspace_id, name) VALUES (?, ?)", workspaceID, name)
+func CreateProject(ctx context.Context, db DBTX, workspaceID int64, name string) (models.Project, error) {
+	res, err := exec(ctx, db, "INSERT INTO projects (workspace_id, name) VALUES (?, ?)", workspaceID, name)
 	if err != nil {
-		return models.Project{}, err
+		return models.Project{}, duplicate(err, fmt.Sprintf("project %q", name))
 	}
 	id, _ := res.LastInsertId()
-	return GetProject(db, id)
+	return GetProject(ctx, db, id)
 }
 
-func GetProject(db *sql.DB, id int64) (models.Project, error) {
+func GetProject(ctx context.Context, db DBTX, id int64) (models.Project, error) {
 	var p models.Project
 	var color sql.NullString
-	err := db.QueryRow("SELECT id, workspace_id, name, color, created_at FROM projects WHERE id = ?", id).
-		Scan(&p.ID, &p.WorkspaceID, &p.Name, &color, &p.CreatedAt)
+	err := db.QueryRowContext(ctx, "SELECT id, COALESCE(uuid, ''), workspace_id, name, color, created_at FROM projects WHERE id = ?", id).
+		Scan(&p.ID, &p.UUID, &p.WorkspaceID, &p.Name, &color, &p.CreatedAt)
 	if err != nil {
-		return p, err
+		return p, notFound(err, fmt.Sprintf("project %d", id))
 	}
 	if color.Valid {
 		p.Color = color.String
@@ -29,8 +31,8 @@ func GetProject(db *sql.DB, id int64) (models.Project, error) {
 	return p, nil
 }
 
-func ListProjects(db *sql.DB, workspaceID int64) ([]models.Project, error) {
-	rows, err := db.Query("SELECT id, workspace_id, name, color, created_at FROM projects WHERE workspace_id = ? ORDER BY name", workspaceID)
+func ListProjects(ctx context.Context, db DBTX, workspaceID int64) ([]models.Project, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, COALESCE(uuid, ''), workspace_id, name, color, created_at FROM projects WHERE workspace_id = ? ORDER BY name", workspaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +41,7 @@ func ListProjects(db *sql.DB, workspaceID int64) ([]models.Project, error) {
 	for rows.Next() {
 		var p models.Project
 		var color sql.NullString
-		if err := rows.Scan(&p.ID, &p.WorkspaceID, &p.Name, &color, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.UUID, &p.WorkspaceID, &p.Name, &color, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		if color.Valid {
@@ -50,30 +52,36 @@ func ListProjects(db *sql.DB, workspaceID int64) ([]models.Project, error) {
 	return list, rows.Err()
 }
 
-func UpdateProject(db *sql.DB, id int64, name string) (models.Project, error) {
-	_, err := db.Exec("UPDATE projects SET name = ? WHERE id = ?", name, id)
+func UpdateProject(ctx context.Context, db DBTX, id int64, name string) (models.Project, error) {
+	_, err := exec(ctx, db, "UPDATE projects SET name = ? WHERE id = ?", name, id)
 	if err != nil {
-		return models.Project{}, err
+		return models.Project{}, duplicate(err, fmt.Sprintf("project %q", name))
 	}
-	return GetProject(db, id)
+	return GetProject(ctx, db, id)
 }
 
-func SetProjectColor(db *sql.DB, id int64, color string) (models.Project, error) {
+func SetProjectColor(ctx context.Context, db DBTX, id int64, color string) (models.Project, error) {
 	var val interface{}
 	if color != "" {
 		val = color
 	}
-	_, err := db.Exec("UPDATE projects SET color = ? WHERE id = ?", val, id)
+	_, err := exec(ctx, db, "UPDATE projects SET color = ? WHERE id = ?", val, id)
 	if err != nil {
 		return models.Project{}, err
 	}
-	return GetProject(db, id)
+	return GetProject(ctx, db, id)
 }
 
-func DeleteProject(db *sql.DB, id int64) error {
-	if _, err := db.Exec("UPDATE tasks SET project_id = NULL WHERE project_id = ?", id); err != nil {
-		return err
-	}
-	_, err := db.Exec("DELETE FROM projects WHERE id = ?", id)
-	return err
+// DeleteProject deletes a list; its tasks move to the default list.
+func DeleteProject(ctx context.Context, db DBTX, id int64) error {
+	return WithTx(ctx, db, func(tx DBTX) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE tasks SET project_id = NULL WHERE project_id = ?", id); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, "DELETE FROM projects WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		return checkAffected(res, fmt.Sprintf("project %d", id))
+	})
 }
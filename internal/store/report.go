@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// reportGroups maps a --group-by value to the SQL expression naming the group.
+var reportGroups = map[string]string{
+	"workspace": "w.name",
+	"project":   "w.name || '/' || COALESCE(p.name, 'Default')",
+	"tag":       "COALESCE(tg.tag, '(untagged)')",
+	"day":       "date(e.started_at, 'localtime')",
+}
+
+// TimeReport sums time logged in [from, to) per group and local day. groupBy is one of
+// workspace, project, tag or day; workspaceID nil = all workspaces. An entry counts on the day
+// it started; with groupBy "tag" a task with several tags counts once per tag.
+func TimeReport(ctx context.Context, db DBTX, from, to time.Time, groupBy string, workspaceID *int64) ([]models.TimeReportRow, error) {
+	group, ok := reportGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("cannot group by %q (use workspace, project, tag or day)", groupBy)
+	}
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+group+` AS grp, date(e.started_at, 'localtime') AS day, SUM(`+entrySecondsSQL+`)
+		 FROM time_entries e
+		 JOIN tasks t ON t.id = e.task_id
+		 JOIN workspaces w ON w.id = t.workspace_id
+		 LEFT JOIN projects p ON p.id = t.project_id
+		 LEFT JOIN task_tags tg ON tg.task_id = t.id AND ? = 'tag'
+		 WHERE e.started_at >= ? AND e.started_at < ? AND (? IS NULL OR t.workspace_id = ?)
+		 GROUP BY grp, day ORDER BY grp, day`,
+		groupBy, sqlTime(from), sqlTime(to), workspaceID, workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.TimeReportRow
+	for rows.Next() {
+		var r models.TimeReportRow
+		if err := rows.Scan(&r.Group, &r.Day, &r.Seconds); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
@@ -1,6 +1,7 @@
 -- Workspaces: personal, family, daily, work
 CREATE TABLE IF NOT EXISTS workspaces (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uuid TEXT, -- set on insert by a trigger, see Migrate
     name TEXT NOT NULL UNIQUE,
     color TEXT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
@@ -9,6 +10,7 @@ CREATE TABLE IF NOT EXISTS workspaces (
 -- Projects/lists inside a workspace (optional). If no project, task goes to "default" list (project_id NULL).
 CREATE TABLE IF NOT EXISTS projects (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uuid TEXT,
     workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
     name TEXT NOT NULL,
     color TEXT,
@@ -19,19 +21,164 @@ CREATE TABLE IF NOT EXISTS projects (
 -- Tasks: belong to workspace; project_id NULL = default list
 CREATE TABLE IF NOT EXISTS tasks (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uuid TEXT,
     workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
     project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL,
     title TEXT NOT NULL,
     description TEXT,
-    status TEXT NOT NULL DEFAULT 'todo' CHECK (status IN ('todo', 'in_progress', 'done')),
+    status TEXT NOT NULL DEFAULT 'todo',
     priority TEXT CHECK (priority IN ('low', 'medium', 'high') OR priority IS NULL),
     due_date DATE,
+    estimate_minutes INTEGER,
+    estimate_points REAL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Statuses: per-workspace workflow. Tasks store the status name; validity is checked in the store.
+CREATE TABLE IF NOT EXISTS statuses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    color TEXT,
+    is_done INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(workspace_id, name)
+);
+
+-- Task dependencies: task_id is blocked by depends_on_id until that task is in a done status.
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    depends_on_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, depends_on_id),
+    CHECK (task_id <> depends_on_id)
+);
+
+-- Time entries: work logged on a task. ended_at NULL = the running timer (at most one).
+CREATE TABLE IF NOT EXISTS time_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME,
+    seconds INTEGER NOT NULL DEFAULT 0,
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Tags: free-form labels on tasks.
+CREATE TABLE IF NOT EXISTS task_tags (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (task_id, tag)
+);
+
+-- Notes: timestamped, append-only comments on a task.
+CREATE TABLE IF NOT EXISTS task_notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Attachments: a URL, a reference to a local path, or a copy of a file kept in the
+-- database (blob) or in the attachments directory next to the database (file).
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('url', 'path', 'blob', 'file')),
+    name TEXT NOT NULL,
+    target TEXT,
+    data BLOB,
+    size INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Custom fields: typed metadata defined per workspace (e.g. ticket, customer, author).
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('text', 'number', 'date', 'enum', 'bool')),
+    options TEXT, -- enum values, comma-separated
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, name)
+);
+
+-- value has no declared type so numbers (REAL), booleans (0/1) and text/dates (TEXT) keep their storage class and sort naturally.
+CREATE TABLE IF NOT EXISTS task_field_values (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    field_id INTEGER NOT NULL REFERENCES custom_fields(id) ON DELETE CASCADE,
+    value,
+    PRIMARY KEY (task_id, field_id)
+);
+
+-- Templates: reusable project checklists, stored as JSON (see models.Template).
+CREATE TABLE IF NOT EXISTS templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    body TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Settings: small key/value store (e.g. the current workspace set by "todo use").
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+-- Sync clocks: when each field of a workspace, list or task last changed (see "todo sync").
+-- Fields without a row haven't changed since the row was created. Kept up to date by triggers, see Migrate.
+CREATE TABLE IF NOT EXISTS sync_clocks (
+    uuid TEXT NOT NULL,
+    field TEXT NOT NULL, -- a column (title, status, ...), tags, depends_on, field:<name>, statuses or fields
+    changed_at TEXT NOT NULL,
+    PRIMARY KEY (uuid, field)
+);
+
+-- Sync tombstones: deleted workspaces, lists and tasks, so sync deletes them in the other database too.
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    uuid TEXT PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('workspace', 'project', 'task')),
+    deleted_at TEXT NOT NULL
+);
+
 CREATE INDEX IF NOT EXISTS idx_tasks_workspace ON tasks(workspace_id);
 CREATE INDEX IF NOT EXISTS idx_tasks_project ON tasks(project_id);
 CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
 CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date);
 CREATE INDEX IF NOT EXISTS idx_projects_workspace ON projects(workspace_id);
+CREATE INDEX IF NOT EXISTS idx_statuses_workspace ON statuses(workspace_id);
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_depends_on ON task_dependencies(depends_on_id);
+CREATE INDEX IF NOT EXISTS idx_time_entries_task ON time_entries(task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_running ON time_entries((ended_at IS NULL)) WHERE ended_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_task_tags_tag ON task_tags(tag);
+CREATE INDEX IF NOT EXISTS idx_task_notes_task ON task_notes(task_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_task ON attachments(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_field_values_field ON task_field_values(field_id, value);
+
+CREATE TRIGGER IF NOT EXISTS task_notes_append_only BEFORE UPDATE ON task_notes BEGIN
+    SELECT RAISE(ABORT, 'task notes are append-only');
+END;
+
+-- Full-text index over task titles and descriptions, kept in sync with tasks by triggers.
+CREATE VIRTUAL TABLE IF NOT EXISTS tasks_fts USING fts5(
+    title, description,
+    content='tasks', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS tasks_fts_insert AFTER INSERT ON tasks BEGIN
+    INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS tasks_fts_delete AFTER DELETE ON tasks BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS tasks_fts_update AFTER UPDATE OF title, description ON tasks BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
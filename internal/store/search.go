@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// Markers placed around matched terms in SearchHit.Title and SearchHit.Snippet.
+// Callers replace them with their own highlighting (ANSI, lipgloss, ...).
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// SearchQuery turns plain user input into an FTS5 query: every word must match,
+// as a prefix ("depl mig" finds "deploy migration"). Quotes keep FTS5 syntax out.
+func SearchQuery(input string) string {
+	var terms []string
+	for _, w := range strings.Fields(input) {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchTasks runs an FTS5 query over task titles and descriptions, best matches first
+// (title matches weigh more). workspaceID/projectID nil = no scoping; limit <= 0 = no limit.
+func SearchTasks(ctx context.Context, db DBTX, query string, workspaceID, projectID *int64, limit int) ([]models.SearchHit, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+taskColumns+`, w.name, COALESCE(p.name, ''),
+		        highlight(tasks_fts, 0, ?, ?),
+		        CASE WHEN COALESCE(tasks.description, '') = '' THEN '' ELSE snippet(tasks_fts, 1, ?, ?, '…', 12) END,
+		        bm25(tasks_fts, 10.0, 1.0) AS rank
+		 FROM tasks_fts
+		 JOIN tasks ON tasks.id = tasks_fts.rowid
+		 JOIN workspaces w ON w.id = tasks.workspace_id
+		 LEFT JOIN projects p ON p.id = tasks.project_id
+		 WHERE tasks_fts MATCH ? AND (? IS NULL OR tasks.workspace_id = ?) AND (? IS NULL OR tasks.project_id = ?)
+		 ORDER BY rank LIMIT ?`,
+		HighlightStart, HighlightEnd, HighlightStart, HighlightEnd,
+		query, workspaceID, workspaceID, projectID, projectID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var hits []models.SearchHit
+	for rows.Next() {
+		var h models.SearchHit
+		t, err := scanTask(searchRow{rows, &h})
+		if err != nil {
+			return nil, err
+		}
+		h.Task = t
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// searchRow lets scanTask scan the task columns of a search result while the
+// trailing search columns land in the hit.
+type searchRow struct {
+	rows *sql.Rows
+	hit  *models.SearchHit
+}
+
+func (r searchRow) Scan(dest ...any) error {
+	return r.rows.Scan(append(dest, &r.hit.WorkspaceName, &r.hit.ProjectName, &r.hit.Title, &r.hit.Snippet, &r.hit.Rank)...)
+}
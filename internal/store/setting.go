@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Setting keys.
+const (
+	SettingWorkspace = "context.workspace" // current workspace, set by "todo use"
+	SettingProject   = "context.project"   // current project/list within it ("" = default list)
+	SettingGitRepo   = "git.repo"          // directory of the tree kept in git, set by "todo git init"
+	SettingGitHead   = "git.head"          // the commit the database was last written to or loaded from
+	SettingGitSynced = "git.synced"        // when that was, as a sync clock (see LoadTree)
+)
+
+// GetSetting returns a stored setting, or "" if it is not set.
+func GetSetting(ctx context.Context, db DBTX, key string) (string, error) {
+	var value string
+	err := db.QueryRowContext(ctx, "SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetSetting stores a setting; an empty value removes it.
+func SetSetting(ctx context.Context, db DBTX, key, value string) error {
+	if value == "" {
+		_, err := exec(ctx, db, "DELETE FROM settings WHERE key = ?", key)
+		return err
+	}
+	_, err := exec(ctx, db, "INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value", key, value)
+	return err
+}
@@ -0,0 +1,361 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshots are copies of the database in "<db name>.snapshots" next to it, named after when and why they
+// were taken, e.g. 20261019-153012.042-import.db. Open takes one before migrating a database to a new
+// schema version, commands before deleting a workspace, importing, restoring or repairing it, and todo db
+// backup when asked. Only the newest SnapshotKeep automatic ones are kept; those of todo db backup stay until removed.
+// An encrypted database's snapshots are encrypted with its key.
+
+// SnapshotKeep is how many automatic snapshots are kept per database; 0 turns them off.
+var SnapshotKeep = 10
+
+// manualSnapshot is the reason of snapshots taken by todo db backup, which are not rotated.
+const manualSnapshot = "backup"
+
+const snapshotTimeLayout = "20060102-150405.000"
+
+// A Snapshot is a file in the snapshot directory.
+type Snapshot struct {
+	Name      string // file name, e.g. 20261019-153012.042-import.db
+	Path      string
+	Taken     time.Time
+	Reason    string // e.g. migrate, import, workspace-delete, restore, or backup for todo db backup
+	Size      int64
+	Encrypted bool
+}
+
+// SnapshotDir returns the directory holding the database's snapshots, "" for an in-memory database.
+func SnapshotDir(ctx context.Context, db DBTX) (string, error) {
+	file, err := DatabaseFile(ctx, db)
+	if err != nil || file == "" {
+		return "", err
+	}
+	return snapshotDir(file), nil
+}
+
+func snapshotDir(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + ".snapshots"
+}
+
+// TakeSnapshot writes a snapshot of the database that is kept until removed, and returns its path.
+func TakeSnapshot(ctx context.Context, db *sql.DB) (string, error) {
+	file, err := DatabaseFile(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	if file == "" {
+		return "", errors.New("snapshots need a database on disk")
+	}
+	return takeSnapshot(ctx, db, file, manualSnapshot)
+}
+
+// SnapshotBefore takes an automatic snapshot before a destructive operation described by reason (e.g.
+// "import"), unless they are turned off or the database is only in memory.
+func SnapshotBefore(ctx context.Context, db *sql.DB, reason string) error {
+	if SnapshotKeep <= 0 {
+		return nil
+	}
+	file, err := DatabaseFile(ctx, db)
+	if err != nil || file == "" {
+		return err
+	}
+	if _, err := takeSnapshot(ctx, db, file, reason); err != nil {
+		return fmt.Errorf("snapshot before %s: %w", reason, err)
+	}
+	return nil
+}
+
+func takeSnapshot(ctx context.Context, db *sql.DB, file, reason string) (string, error) {
+	path, err := newSnapshotPath(file, reason)
+	if err != nil {
+		return "", err
+	}
+	if err := Backup(ctx, db, path); err != nil {
+		return "", err
+	}
+	return path, rotateSnapshots(snapshotDir(file))
+}
+
+// snapshotBeforeMigrate snapshots the database file at path if Migrate is about to change its schema.
+func snapshotBeforeMigrate(ctx context.Context, db *sql.DB, path string) error {
+	if SnapshotKeep <= 0 {
+		return nil
+	}
+	pending, err := migrationPending(ctx, db)
+	if err != nil || !pending {
+		return err
+	}
+	if _, err := takeSnapshot(ctx, db, path, "migrate"); err != nil {
+		return fmt.Errorf("snapshot before migrate: %w", err)
+	}
+	return nil
+}
+
+// writeSnapshot writes data as a snapshot of the database file, for snapshots taken before it is open.
+func writeSnapshot(file, reason string, data []byte) error {
+	path, err := newSnapshotPath(file, reason)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	return rotateSnapshots(snapshotDir(file))
+}
+
+func newSnapshotPath(file, reason string) (string, error) {
+	dir := snapshotDir(file)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, time.Now().Format(snapshotTimeLayout)+"-"+reason+".db"), nil
+}
+
+// rotateSnapshots removes the automatic snapshots in dir beyond the newest SnapshotKeep.
+func rotateSnapshots(dir string) error {
+	if SnapshotKeep <= 0 {
+		return nil
+	}
+	snaps, err := listSnapshots(dir)
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, s := range snaps {
+		if s.Reason == manualSnapshot {
+			continue
+		}
+		if kept++; kept > SnapshotKeep {
+			if err := os.Remove(s.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Snapshots lists the database's snapshots, newest first.
+func Snapshots(ctx context.Context, db DBTX) ([]Snapshot, error) {
+	dir, err := SnapshotDir(ctx, db)
+	if err != nil || dir == "" {
+		return nil, err
+	}
+	return listSnapshots(dir)
+}
+
+func listSnapshots(dir string) ([]Snapshot, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, path := range files {
+		name := filepath.Base(path)
+		base := strings.TrimSuffix(name, ".db")
+		n := len(snapshotTimeLayout)
+		if len(base) < n+2 || base[n] != '-' {
+			continue // not a snapshot
+		}
+		taken, err := time.ParseInLocation(snapshotTimeLayout, base[:n], time.Local)
+		if err != nil {
+			continue
+		}
+		reason := base[n+1:]
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := IsEncrypted(path)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, Snapshot{Name: name, Path: path, Taken: taken, Reason: reason, Size: info.Size(), Encrypted: encrypted})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Taken.After(snaps[j].Taken) })
+	return snaps, nil
+}
+
+// FindSnapshot resolves a snapshot given by path, by name, or by a unique prefix of its name.
+func FindSnapshot(ctx context.Context, db DBTX, name string) (string, error) {
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		return name, nil
+	}
+	snaps, err := Snapshots(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	var found []string
+	for _, s := range snaps {
+		if s.Name == name || s.Name == name+".db" {
+			return s.Path, nil
+		}
+		if strings.HasPrefix(s.Name, name) {
+			found = append(found, s.Path)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("snapshot %q %w (list them with: todo db snapshots)", name, ErrNotFound)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("snapshot %q is ambiguous: %d snapshots start with it", name, len(found))
+}
+
+// Backup writes a copy of the database to path, which must not exist: with VACUUM INTO, or from memory for
+// a directory store or an encrypted database, whose copy is encrypted with its key.
+func Backup(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if v, ok := memStores.Load(db); ok {
+		// VACUUM INTO would write through the memdb VFS, into memory.
+		m := v.(*memDB)
+		image, err := serializeImage(m.conn)
+		if err != nil {
+			return err
+		}
+		if cs, ok := m.store.(*cryptStore); ok {
+			data, err := cs.key.seal(image)
+			if err != nil {
+				return err
+			}
+			return writeFileAtomic(path, data)
+		}
+		if err := os.WriteFile(tmp, image, 0o644); err != nil {
+			return err
+		}
+		// Where a directory store was loaded from is not part of the copy.
+		if err := deleteStorePath(ctx, tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	} else if _, err := db.ExecContext(ctx, "VACUUM INTO ?", tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func deleteStorePath(ctx context.Context, file string) error {
+	copyDB, err := sql.Open("sqlite", file)
+	if err != nil {
+		return err
+	}
+	defer copyDB.Close()
+	_, err = copyDB.ExecContext(ctx, "DELETE FROM settings WHERE key = ?", settingStorePath)
+	return err
+}
+
+// Restore replaces the content of the database with the snapshot or backup at src, after checking that
+// it is intact. The current content is snapshotted first, so a restore can be undone.
+func Restore(ctx context.Context, db *sql.DB, src string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	image := data
+	if iterations, salt, ok := cryptHeader(data); ok {
+		cs, _, err := cryptStoreOf(db)
+		if err != nil || cs.key.iterations != iterations || !bytes.Equal(cs.key.salt, salt) {
+			// Another key, e.g. from before todo db rekey.
+			k, err := unlockCrypt(src, data, Passphrase)
+			if err != nil {
+				return err
+			}
+			if image, err = k.open(data); err != nil {
+				return fmt.Errorf("%s: %w", src, err)
+			}
+		} else if image, err = cs.key.open(data); err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+	}
+	if err := checkImage(ctx, image); err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	file, err := DatabaseFile(ctx, db)
+	if err != nil {
+		return err
+	}
+	if err := SnapshotBefore(ctx, db, "restore"); err != nil {
+		return err
+	}
+	var conn *sql.Conn
+	if m, ok := memStores.Load(db); ok {
+		conn = m.(*memDB).conn
+	} else {
+		if conn, err = db.Conn(ctx); err != nil {
+			return err
+		}
+		defer conn.Close()
+	}
+	err = withImage(image, func(uri string) error {
+		return rawSQLite(conn, func(c sqliteConn) error {
+			b, err := c.NewRestore(uri)
+			if err != nil {
+				return err
+			}
+			return runBackup(b)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := Migrate(ctx, db); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	if _, ok := memStores.Load(db); ok {
+		return SetSetting(ctx, db, settingStorePath, file)
+	}
+	return nil
+}
+
+// checkImage checks that a database file's content passes PRAGMA integrity_check and is a todo database.
+func checkImage(ctx context.Context, image []byte) error {
+	return withImage(image, func(uri string) error {
+		src, err := sql.Open("sqlite", uri)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		var problems []string
+		err = eachRow(ctx, src, "PRAGMA integrity_check", func(scan func(...any) error) error {
+			var msg string
+			if err := scan(&msg); err != nil {
+				return err
+			}
+			if msg != "ok" {
+				problems = append(problems, msg)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("integrity check failed: %w", err)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+		}
+		var tables int
+		if err := src.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('workspaces', 'tasks')").Scan(&tables); err != nil {
+			return err
+		}
+		if tables < 2 {
+			return errors.New("not a todo database")
+		}
+		return nil
+	})
+}
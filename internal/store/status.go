@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// seedStatusesSQL inserts the default workflow (todo → in_progress → done) for rows of "workspaces w".
+// Callers append a WHERE clause selecting the workspaces to seed.
+const seedStatusesSQL = `INSERT INTO statuses (workspace_id, name, position, is_done)
+SELECT w.id, d.name, d.position, d.is_done FROM workspaces w
+CROSS JOIN (SELECT 'todo' AS name, 0 AS position, 0 AS is_done
+            UNION ALL SELECT 'in_progress', 1, 0
+            UNION ALL SELECT 'done', 2, 1) d`
+
+const statusColumns = "id, workspace_id, name, position, color, is_done, created_at"
+
+func scanStatus(row interface{ Scan(...any) error }) (models.Status, error) {
+	var st models.Status
+	var color sql.NullString
+	if err := row.Scan(&st.ID, &st.WorkspaceID, &st.Name, &st.Position, &color, &st.IsDone, &st.CreatedAt); err != nil {
+		return models.Status{}, err
+	}
+	st.Color = color.String
+	return st, nil
+}
+
+// ListStatuses returns the workspace's workflow in order.
+func ListStatuses(ctx context.Context, db DBTX, workspaceID int64) ([]models.Status, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+statusColumns+" FROM statuses WHERE workspace_id = ? ORDER BY position, id", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.Status
+	for rows.Next() {
+		st, err := scanStatus(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, st)
+	}
+	return list, rows.Err()
+}
+
+func GetStatus(ctx context.Context, db DBTX, id int64) (models.Status, error) {
+	st, err := scanStatus(db.QueryRowContext(ctx, "SELECT "+statusColumns+" FROM statuses WHERE id = ?", id))
+	return st, notFound(err, fmt.Sprintf("status %d", id))
+}
+
+func GetStatusByName(ctx context.Context, db DBTX, workspaceID int64, name string) (models.Status, error) {
+	st, err := scanStatus(db.QueryRowContext(ctx, "SELECT "+statusColumns+" FROM statuses WHERE workspace_id = ? AND name = ?", workspaceID, name))
+	return st, notFound(err, fmt.Sprintf("status %q", name))
+}
+
+// CreateStatus appends a status to the end of the workspace's workflow.
+func CreateStatus(ctx context.Context, db DBTX, workspaceID int64, name, color string, isDone bool) (models.Status, error) {
+	res, err := exec(ctx, db,
+		`INSERT INTO statuses (workspace_id, name, position, color, is_done)
+		 VALUES (?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM statuses WHERE workspace_id = ?), ?, ?)`,
+		workspaceID, name, workspaceID, nullString(color), isDone,
+	)
+	if err != nil {
+		return models.Status{}, duplicate(err, fmt.Sprintf("status %q", name))
+	}
+	id, _ := res.LastInsertId()
+	return GetStatus(ctx, db, id)
+}
+
+// UpdateStatus renames a status (moving its tasks along) and sets its color and done flag.
+func UpdateStatus(ctx context.Context, db DBTX, id int64, name, color string, isDone bool) (models.Status, error) {
+	var out models.Status
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		st, err := GetStatus(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE statuses SET name = ?, color = ?, is_done = ? WHERE id = ?", name, nullString(color), isDone, id); err != nil {
+			return duplicate(err, fmt.Sprintf("status %q", name))
+		}
+		if name != st.Name {
+			if _, err := tx.ExecContext(ctx, "UPDATE tasks SET status = ? WHERE workspace_id = ? AND status = ?", name, st.WorkspaceID, st.Name); err != nil {
+				return err
+			}
+		}
+		out, err = GetStatus(ctx, tx, id)
+		return err
+	})
+	return out, err
+}
+
+// MoveStatus places a status at the given 0-based position in its workflow.
+func MoveStatus(ctx context.Context, db DBTX, id int64, position int) error {
+	return WithTx(ctx, db, func(tx DBTX) error {
+		st, err := GetStatus(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		list, err := ListStatuses(ctx, tx, st.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		ordered := make([]models.Status, 0, len(list))
+		for _, s := range list {
+			if s.ID != id {
+				ordered = append(ordered, s)
+			}
+		}
+		position = max(0, min(position, len(ordered)))
+		ordered = append(ordered[:position], append([]models.Status{st}, ordered[position:]...)...)
+		for i, s := range ordered {
+			if _, err := tx.ExecContext(ctx, "UPDATE statuses SET position = ? WHERE id = ?", i, s.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteStatus removes a status; tasks in it move to the replacement status.
+func DeleteStatus(ctx context.Context, db DBTX, id int64, replacement string) error {
+	return WithTx(ctx, db, func(tx DBTX) error {
+		st, err := GetStatus(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if replacement == st.Name {
+			return fmt.Errorf("cannot move tasks of status %q to itself", st.Name)
+		}
+		if _, err := GetStatusByName(ctx, tx, st.WorkspaceID, replacement); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE tasks SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE workspace_id = ? AND status = ?", replacement, st.WorkspaceID, st.Name); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM statuses WHERE id = ?", id)
+		return err
+	})
+}
+
+// resolveTaskStatus validates status against the workspace workflow. Empty means the first status.
+func resolveTaskStatus(ctx context.Context, db DBTX, workspaceID int64, status string) (string, error) {
+	if status != "" {
+		st, err := GetStatusByName(ctx, db, workspaceID, status)
+		if errors.Is(err, ErrNotFound) {
+			list, err := ListStatuses(ctx, db, workspaceID)
+			if err != nil {
+				return "", err
+			}
+			names := make([]string, len(list))
+			for i, s := range list {
+				names[i] = s.Name
+			}
+			return "", ErrInvalidField{Field: "status", Value: status, Reason: oneOf(names)}
+		}
+		return st.Name, err
+	}
+	var name string
+	err := db.QueryRowContext(ctx, "SELECT name FROM statuses WHERE workspace_id = ? ORDER BY position, id LIMIT 1", workspaceID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("workspace %d has no statuses", workspaceID)
+	}
+	return name, err
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
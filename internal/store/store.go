@@ -1,10 +1,12 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -26,8 +28,33 @@ func DBPath() (string, error) {
 	return filepath.Join(dir, "todo.db"), nil
 }
 
-// Open opens the SQLite database and runs migrations.
-func Open(path string) (*sql.DB, error) {
+// dsnOptions make the database safe to share between processes (e.g. the TUI and scripts): WAL lets readers
+// and one writer work at the same time, busy_timeout waits up to 5s for the write lock instead of failing
+// with "database is locked", and immediate transactions take that lock when they start.
+const dsnOptions = "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
+// Open opens the SQLite database and runs migrations. A path starting with DirPrefix opens a directory
+// store instead, and an encrypted database (see crypt.go) is unlocked with KeyEnv, PassphraseEnv or
+// Passphrase; close databases with Close so these are written back (see also Save).
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	return OpenWith(ctx, path, Passphrase)
+}
+
+// OpenWith is Open, asking passphrase rather than Passphrase for the passphrase of an encrypted database.
+func OpenWith(ctx context.Context, path string, passphrase func(path string) (string, error)) (*sql.DB, error) {
+	return open(ctx, path, passphrase, true)
+}
+
+// OpenUnmigrated is Open without migrating the database, so CheckDatabase sees it as it is; FixDatabase
+// migrates it. A directory store is always loaded into the current schema.
+func OpenUnmigrated(ctx context.Context, path string) (*sql.DB, error) {
+	return open(ctx, path, Passphrase, false)
+}
+
+func open(ctx context.Context, path string, passphrase func(path string) (string, error), migrate bool) (*sql.DB, error) {
+	if dir, ok := strings.CutPrefix(path, DirPrefix); ok {
+		return openDir(ctx, dir)
+	}
 	if path == "" {
 		var err error
 		path, err = DBPath()
@@ -35,13 +62,44 @@ func Open(path string) (*sql.DB, error) {
 			return nil, err
 		}
 	}
-	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)")
+	if encrypted, err := IsEncrypted(path); err != nil {
+		return nil, err
+	} else if encrypted {
+		return openEncrypted(ctx, path, passphrase, migrate)
+	}
+	db, err := sql.Open("sqlite", path+dsnOptions)
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
-	if err := Migrate(db); err != nil {
+	if !migrate {
+		return db, nil
+	}
+	if err := snapshotBeforeMigrate(ctx, db, path); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := Migrate(ctx, db); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
 	return db, nil
 }
+
+// DatabaseFile returns the path of the open database file: the directory of a directory store, the file
+// of an encrypted database, "" for another in-memory database.
+func DatabaseFile(ctx context.Context, db DBTX) (string, error) {
+	// A database opened by OpenUnmigrated may predate the settings table.
+	var settings bool
+	err := db.QueryRowContext(ctx, "SELECT count(*) > 0 FROM sqlite_schema WHERE type = 'table' AND name = 'settings'").Scan(&settings)
+	if err != nil {
+		return "", err
+	}
+	if settings {
+		if path, err := GetSetting(ctx, db, settingStorePath); err != nil || path != "" {
+			return path, err
+		}
+	}
+	var file string
+	err = db.QueryRowContext(ctx, "SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file)
+	return file, err
+}
@@ -0,0 +1,307 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// backends are the ways Open stores a database; the behavior suite runs against each.
+var backends = []struct {
+	name string
+	path func(t *testing.T) string
+}{
+	{"sqlite", func(t *testing.T) string { return filepath.Join(t.TempDir(), "todo.db") }},
+	{"dir", func(t *testing.T) string { return DirPrefix + t.TempDir() }},
+}
+
+// A behavior changes a new database in setup; check tests the result, then again after the database
+// was closed and opened again.
+var behaviors = []struct {
+	name  string
+	setup func(t *testing.T, db *sql.DB)
+	check func(t *testing.T, db *sql.DB)
+}{
+	{
+		name: "workspaces",
+		setup: func(t *testing.T, db *sql.DB) {
+			must(CreateWorkspace(ctx, db, "Home"))(t)
+			must(CreateWorkspace(ctx, db, "Work"))(t)
+			if _, err := CreateWorkspace(ctx, db, "Work"); !errors.Is(err, ErrDuplicateName) {
+				t.Errorf("second Work: %v, want ErrDuplicateName", err)
+			}
+			old := must(CreateWorkspace(ctx, db, "Old"))(t)
+			must(UpdateWorkspace(ctx, db, old.ID, "Family"))(t)
+			gone := must(CreateWorkspace(ctx, db, "Gone"))(t)
+			if err := DeleteWorkspace(ctx, db, gone.ID); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, db *sql.DB) {
+			var names []string
+			for _, w := range must(ListWorkspaces(ctx, db))(t) {
+				names = append(names, w.Name)
+			}
+			slices.Sort(names)
+			if want := []string{"Family", "Home", "Work"}; !slices.Equal(names, want) {
+				t.Errorf("workspaces %v, want %v", names, want)
+			}
+			if _, err := GetWorkspaceByName(ctx, db, "Gone"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleted workspace: %v, want ErrNotFound", err)
+			}
+		},
+	},
+	{
+		name: "projects",
+		setup: func(t *testing.T, db *sql.DB) {
+			w := must(CreateWorkspace(ctx, db, "Work"))(t)
+			backend := must(CreateProject(ctx, db, w.ID, "backend"))(t)
+			front := must(CreateProject(ctx, db, w.ID, "frontend"))(t)
+			must(UpdateProject(ctx, db, front.ID, "web"))(t)
+			if _, err := CreateProject(ctx, db, w.ID, "web"); !errors.Is(err, ErrDuplicateName) {
+				t.Errorf("second web: %v, want ErrDuplicateName", err)
+			}
+			must(CreateTask(ctx, db, w.ID, &backend.ID, "api", "", "", models.PriorityNone, nil))(t)
+			tmp := must(CreateProject(ctx, db, w.ID, "tmp"))(t)
+			must(CreateTask(ctx, db, w.ID, &tmp.ID, "moved", "", "", models.PriorityNone, nil))(t)
+			if err := DeleteProject(ctx, db, tmp.ID); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, db *sql.DB) {
+			w := must(GetWorkspaceByName(ctx, db, "Work"))(t)
+			projects := map[string]int64{}
+			for _, p := range must(ListProjects(ctx, db, w.ID))(t) {
+				projects[p.Name] = p.ID
+			}
+			if len(projects) != 2 || projects["backend"] == 0 || projects["web"] == 0 {
+				t.Errorf("projects %v, want backend and web", projects)
+			}
+			backend := projects["backend"]
+			if got := taskTitles(must(ListTasks(ctx, db, w.ID, &backend))(t)); !slices.Equal(got, []string{"api"}) {
+				t.Errorf("backend tasks %v, want [api]", got)
+			}
+			if got := taskTitles(must(ListTasks(ctx, db, w.ID, nil))(t)); !slices.Equal(got, []string{"moved"}) {
+				t.Errorf("default list tasks %v, want [moved]", got)
+			}
+		},
+	},
+	{
+		name: "tasks",
+		setup: func(t *testing.T, db *sql.DB) {
+			w := must(CreateWorkspace(ctx, db, "Work"))(t)
+			due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+			must(CreateTask(ctx, db, w.ID, nil, "write report", "for Q4", "", models.PriorityHigh, &due))(t)
+			edit := must(CreateTask(ctx, db, w.ID, nil, "draft", "", "", models.PriorityNone, nil))(t)
+			must(UpdateTask(ctx, db, edit.ID, "review draft", "twice", "in_progress", models.PriorityLow, nil))(t)
+			must(AddTaskTags(ctx, db, edit.ID, []string{"q4", "docs"}))(t)
+			if _, err := UpdateTask(ctx, db, edit.ID, "review draft", "", "nosuch", models.PriorityLow, nil); err == nil {
+				t.Error("unknown status accepted")
+			}
+			gone := must(CreateTask(ctx, db, w.ID, nil, "gone", "", "", models.PriorityNone, nil))(t)
+			if err := DeleteTask(ctx, db, gone.ID); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, db *sql.DB) {
+			w := must(GetWorkspaceByName(ctx, db, "Work"))(t)
+			tasks := map[string]models.Task{}
+			for _, task := range must(ListTasks(ctx, db, w.ID, nil))(t) {
+				tasks[task.Title] = task
+			}
+			if len(tasks) != 2 {
+				t.Fatalf("tasks %v, want write report and review draft", taskTitles(must(ListTasks(ctx, db, w.ID, nil))(t)))
+			}
+			report := tasks["write report"]
+			if report.Description != "for Q4" || report.Status != "todo" || report.Priority != models.PriorityHigh ||
+				report.DueDate == nil || report.DueDate.Format("2006-01-02") != "2026-11-02" {
+				t.Errorf("write report: %+v", report)
+			}
+			review := tasks["review draft"]
+			slices.Sort(review.Tags)
+			if review.Description != "twice" || review.Status != "in_progress" || review.Priority != models.PriorityLow ||
+				review.DueDate != nil || !slices.Equal(review.Tags, []string{"docs", "q4"}) {
+				t.Errorf("review draft: %+v", review)
+			}
+			if review.UUID == "" || review.UUID == report.UUID {
+				t.Errorf("UUIDs %q and %q", review.UUID, report.UUID)
+			}
+		},
+	},
+	{
+		name: "statuses",
+		setup: func(t *testing.T, db *sql.DB) {
+			w := must(CreateWorkspace(ctx, db, "Work"))(t)
+			must(CreateTask(ctx, db, w.ID, nil, "new", "", "", models.PriorityNone, nil))(t)
+			must(CreateTask(ctx, db, w.ID, nil, "started", "", "in_progress", models.PriorityNone, nil))(t)
+			must(CreateStatus(ctx, db, w.ID, "review", "yellow", false))(t)
+			progress := must(GetStatusByName(ctx, db, w.ID, "in_progress"))(t)
+			must(UpdateStatus(ctx, db, progress.ID, "doing", "", false))(t)
+			todo := must(GetStatusByName(ctx, db, w.ID, "todo"))(t)
+			if err := DeleteStatus(ctx, db, todo.ID, "review"); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, db *sql.DB) {
+			w := must(GetWorkspaceByName(ctx, db, "Work"))(t)
+			var names []string
+			for _, st := range must(ListStatuses(ctx, db, w.ID))(t) {
+				names = append(names, st.Name)
+				if st.IsDone != (st.Name == "done") {
+					t.Errorf("status %s: done %v", st.Name, st.IsDone)
+				}
+			}
+			if want := []string{"doing", "done", "review"}; !slices.Equal(names, want) {
+				t.Errorf("workflow %v, want %v", names, want)
+			}
+			for _, task := range must(ListTasks(ctx, db, w.ID, nil))(t) {
+				if want := map[string]string{"new": "review", "started": "doing"}[task.Title]; task.Status != want {
+					t.Errorf("task %s: status %s, want %s", task.Title, task.Status, want)
+				}
+			}
+		},
+	},
+	{
+		name: "dependencies",
+		setup: func(t *testing.T, db *sql.DB) {
+			w := must(CreateWorkspace(ctx, db, "Work"))(t)
+			ship := must(CreateTask(ctx, db, w.ID, nil, "ship", "", "", models.PriorityNone, nil))(t)
+			build := must(CreateTask(ctx, db, w.ID, nil, "build", "", "", models.PriorityNone, nil))(t)
+			announce := must(CreateTask(ctx, db, w.ID, nil, "announce", "", "", models.PriorityNone, nil))(t)
+			write := must(CreateTask(ctx, db, w.ID, nil, "write", "", "done", models.PriorityNone, nil))(t)
+			for _, d := range [][2]int64{{ship.ID, build.ID}, {announce.ID, write.ID}} {
+				if err := AddDependency(ctx, db, d[0], d[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := AddDependency(ctx, db, build.ID, ship.ID); err == nil {
+				t.Error("cycle accepted")
+			}
+		},
+		check: func(t *testing.T, db *sql.DB) {
+			w := must(GetWorkspaceByName(ctx, db, "Work"))(t)
+			tasks := map[string]models.Task{}
+			for _, task := range must(ListTasks(ctx, db, w.ID, nil))(t) {
+				tasks[task.Title] = task
+			}
+			if !tasks["ship"].Blocked || tasks["announce"].Blocked || tasks["build"].Blocked {
+				t.Errorf("blocked: ship %v, announce %v, build %v; want only ship",
+					tasks["ship"].Blocked, tasks["announce"].Blocked, tasks["build"].Blocked)
+			}
+			if got := taskTitles(must(ListBlockers(ctx, db, tasks["ship"].ID))(t)); !slices.Equal(got, []string{"build"}) {
+				t.Errorf("ship's blockers %v, want [build]", got)
+			}
+		},
+	},
+	{
+		name: "notes",
+		setup: func(t *testing.T, db *sql.DB) {
+			w := must(CreateWorkspace(ctx, db, "Work"))(t)
+			task := must(CreateTask(ctx, db, w.ID, nil, "call", "", "", models.PriorityNone, nil))(t)
+			must(AddNote(ctx, db, task.ID, "no answer"))(t)
+			must(AddNote(ctx, db, task.ID, "left a \"message\"\nsecond line"))(t)
+			if _, err := db.ExecContext(ctx, "UPDATE task_notes SET body = 'changed'"); err == nil {
+				t.Error("note changed")
+			}
+		},
+		check: func(t *testing.T, db *sql.DB) {
+			w := must(GetWorkspaceByName(ctx, db, "Work"))(t)
+			task := must(ListTasks(ctx, db, w.ID, nil))(t)[0]
+			var bodies []string
+			for _, n := range must(ListNotes(ctx, db, task.ID))(t) {
+				bodies = append(bodies, n.Body)
+			}
+			if want := []string{"no answer", "left a \"message\"\nsecond line"}; !slices.Equal(bodies, want) {
+				t.Errorf("notes %q, want %q", bodies, want)
+			}
+		},
+	},
+	{
+		name: "fields",
+		setup: func(t *testing.T, db *sql.DB) {
+			w := must(CreateWorkspace(ctx, db, "Work"))(t)
+			must(CreateField(ctx, db, w.ID, "ticket", "text", nil))(t)
+			must(CreateField(ctx, db, w.ID, "points", "number", nil))(t)
+			must(CreateField(ctx, db, w.ID, "kind", "enum", []string{"bug", "feature"}))(t)
+			task := must(CreateTask(ctx, db, w.ID, nil, "fix login", "", "", models.PriorityNone, nil))(t)
+			must(SetTaskFields(ctx, db, task.ID, map[string]string{"ticket": "ABC-1", "points": "3", "kind": "bug"}))(t)
+			if _, err := SetTaskFields(ctx, db, task.ID, map[string]string{"kind": "chore"}); err == nil {
+				t.Error("enum value outside the options accepted")
+			}
+		},
+		check: func(t *testing.T, db *sql.DB) {
+			w := must(GetWorkspaceByName(ctx, db, "Work"))(t)
+			var names []string
+			for _, f := range must(ListFields(ctx, db, w.ID))(t) {
+				names = append(names, f.Name)
+			}
+			if want := []string{"ticket", "points", "kind"}; !slices.Equal(names, want) {
+				t.Errorf("fields %v, want %v", names, want)
+			}
+			task := must(ListTasks(ctx, db, w.ID, nil))(t)[0]
+			if task.Fields["ticket"] != "ABC-1" || task.Fields["points"] != 3.0 || task.Fields["kind"] != "bug" {
+				t.Errorf("field values %v", task.Fields)
+			}
+		},
+	},
+}
+
+var ctx = context.Background()
+
+func TestStoreBehavior(t *testing.T) {
+	for _, b := range backends {
+		for _, bh := range behaviors {
+			t.Run(b.name+"/"+bh.name, func(t *testing.T) {
+				path := b.path(t)
+				db := openTest(t, path)
+				bh.setup(t, db)
+				bh.check(t, db)
+				closeTest(t, db)
+				db = openTest(t, path)
+				defer closeTest(t, db)
+				bh.check(t, db)
+			})
+		}
+	}
+}
+
+func openTest(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := Open(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func closeTest(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if err := Close(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// must fails the test if err isn't nil, and returns v otherwise: must(f())(t).
+func must[T any](v T, err error) func(t *testing.T) T {
+	return func(t *testing.T) T {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+}
+
+func taskTitles(tasks []models.Task) []string {
+	var titles []string
+	for _, task := range tasks {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
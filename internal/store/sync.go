@@ -0,0 +1,1162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// syncClockLayout is how sync clocks and tombstones record time (UTC, like CURRENT_TIMESTAMP plus
+// milliseconds), so they compare as strings with each other and with created_at.
+const syncClockLayout = "2006-01-02 15:04:05.000"
+
+// Sync settings: this database's ID in sync (with the host and path it belongs to, so a copied file
+// gets an ID of its own) and, per peer, when the two last synced.
+const (
+	settingSyncID     = "sync.id"
+	settingSyncOrigin = "sync.origin"
+	settingSyncPeer   = "sync.peer." // + the peer's ID
+)
+
+// syncKinds are the kinds of records in the order they are created; deletes go the other way.
+var syncKinds = []string{"workspace", "project", "task"}
+
+// syncDerivedFields take the later value without being reported as conflicts.
+var syncDerivedFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// syncValue is a field's value (nil = NULL or none) and when it last changed.
+type syncValue struct {
+	Value *string `json:"value"`
+	At    string  `json:"at"`
+}
+
+// syncNote and syncEntry are a task's notes and finished time entries; sync only ever adds them.
+type syncNote struct {
+	Body string `json:"body"`
+	At   string `json:"at"`
+}
+
+type syncEntry struct {
+	StartedAt string  `json:"started_at"`
+	EndedAt   string  `json:"ended_at"`
+	Seconds   int64   `json:"seconds"`
+	Note      *string `json:"note,omitempty"`
+}
+
+type syncRecord struct {
+	Kind    string               `json:"kind"`
+	Fields  map[string]syncValue `json:"fields"`
+	Notes   []syncNote           `json:"notes,omitempty"`
+	Entries []syncEntry          `json:"time_entries,omitempty"`
+}
+
+type syncTombstone struct {
+	Kind string `json:"kind"`
+	At   string `json:"at"`
+}
+
+// syncState is a database as sync sees it: workspaces, lists and tasks by UUID, and tombstones.
+// The change files of a sync directory hold one each.
+type syncState struct {
+	DatabaseID string                   `json:"database_id"`
+	WrittenAt  string                   `json:"written_at"`
+	Records    map[string]*syncRecord   `json:"records"`
+	Tombstones map[string]syncTombstone `json:"tombstones"`
+	// Merged is, per peer, the written_at of the peer's change file the writer merged before writing
+	// this one: the peer's changes up to then are in it.
+	Merged map[string]string `json:"merged,omitempty"`
+}
+
+// Conflict is a field both databases changed since they last synced, to different values.
+type Conflict struct {
+	Kind     string // workspace, project or task
+	UUID     string
+	Label    string // the name or title
+	Field    string
+	Local    *string // nil = empty
+	Remote   *string
+	LocalAt  string
+	RemoteAt string
+	// UseRemote is the resolution: the other database's value wins.
+	UseRemote bool
+}
+
+// ConflictResolver decides a conflict, whose UseRemote holds the last-writer-wins choice.
+type ConflictResolver func(c Conflict) (useRemote bool, err error)
+
+// SyncChanges counts the records sync added, updated and deleted in one database.
+type SyncChanges struct {
+	Added, Updated, Deleted int
+}
+
+// SyncReport tells what a sync did.
+type SyncReport struct {
+	Local     SyncChanges
+	Remote    SyncChanges // zero when syncing through a directory
+	Peers     int         // change files read from the directory
+	Conflicts []Conflict
+}
+
+// SyncDatabases merges two databases in both directions so they end up with the same workspaces,
+// lists and tasks. Each field keeps the value that was changed last; fields both sides changed since
+// the previous sync are conflicts, decided by resolve (nil: the later change wins). Before their first
+// sync nothing tells which side changed a field, so the later change wins without a conflict. Deletes travel as
+// tombstones; a record changed after the other side deleted it comes back. Notes and finished time
+// entries are merged; attachments, templates and settings stay where they are.
+func SyncDatabases(ctx context.Context, local, remote *sql.DB, resolve ConflictResolver) (SyncReport, error) {
+	var report SyncReport
+	localID, err := syncDatabaseID(ctx, local)
+	if err != nil {
+		return report, err
+	}
+	remoteID, err := syncDatabaseID(ctx, remote)
+	if err != nil {
+		return report, err
+	}
+	if localID == remoteID {
+		return report, fmt.Errorf("cannot sync a database with itself")
+	}
+	since, err := GetSetting(ctx, local, settingSyncPeer+remoteID)
+	if err != nil {
+		return report, err
+	}
+	now := time.Now().UTC().Format(syncClockLayout)
+	ls, err := loadSyncState(ctx, local)
+	if err != nil {
+		return report, err
+	}
+	rs, err := loadSyncState(ctx, remote)
+	if err != nil {
+		return report, err
+	}
+	merged, conflicts, err := mergeSyncStates(ls, rs, since, "", resolve)
+	if err != nil {
+		return report, err
+	}
+	report.Conflicts = conflicts
+	err = WithTx(ctx, remote, func(tx DBTX) error {
+		if report.Remote, err = applySyncState(ctx, tx, merged); err != nil {
+			return err
+		}
+		return SetSetting(ctx, tx, settingSyncPeer+localID, now)
+	})
+	if err != nil {
+		return report, fmt.Errorf("other database: %w", err)
+	}
+	err = WithTx(ctx, local, func(tx DBTX) error {
+		if report.Local, err = applySyncState(ctx, tx, merged); err != nil {
+			return err
+		}
+		return SetSetting(ctx, tx, settingSyncPeer+remoteID, now)
+	})
+	return report, err
+}
+
+// SyncDir syncs through a directory of change files, e.g. one shared by a file sync service: it merges
+// every other database's file into db like SyncDatabases, then writes db's own file for the others.
+func SyncDir(ctx context.Context, db *sql.DB, dir string, resolve ConflictResolver) (SyncReport, error) {
+	var report SyncReport
+	id, err := syncDatabaseID(ctx, db)
+	if err != nil {
+		return report, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return report, err
+	}
+	now := time.Now().UTC().Format(syncClockLayout)
+	merged, err := loadSyncState(ctx, db)
+	if err != nil {
+		return report, err
+	}
+	var peers []string
+	seen := map[string]string{}
+	for _, file := range files {
+		if filepath.Base(file) == id+".json" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return report, err
+		}
+		var peer syncState
+		if err := json.Unmarshal(data, &peer); err != nil {
+			return report, fmt.Errorf("%s: %w", file, err)
+		}
+		since, err := GetSetting(ctx, db, settingSyncPeer+peer.DatabaseID)
+		if err != nil {
+			return report, err
+		}
+		var conflicts []Conflict
+		if merged, conflicts, err = mergeSyncStates(merged, &peer, since, peer.Merged[id], resolve); err != nil {
+			return report, err
+		}
+		report.Conflicts = append(report.Conflicts, conflicts...)
+		peers = append(peers, peer.DatabaseID)
+		seen[peer.DatabaseID] = peer.WrittenAt
+	}
+	report.Peers = len(peers)
+	err = WithTx(ctx, db, func(tx DBTX) error {
+		if report.Local, err = applySyncState(ctx, tx, merged); err != nil {
+			return err
+		}
+		for _, peer := range peers {
+			if err := SetSetting(ctx, tx, settingSyncPeer+peer, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	own, err := loadSyncState(ctx, db)
+	if err != nil {
+		return report, err
+	}
+	own.DatabaseID, own.WrittenAt, own.Merged = id, now, seen
+	data, err := json.Marshal(own)
+	if err != nil {
+		return report, err
+	}
+	// Write and rename so other machines never read a half-written file.
+	tmp := filepath.Join(dir, "."+id+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return report, err
+	}
+	return report, os.Rename(tmp, filepath.Join(dir, id+".json"))
+}
+
+// syncDatabaseID returns the database's sync ID, creating one when the database has none yet or was
+// copied from another host or path.
+func syncDatabaseID(ctx context.Context, db DBTX) (string, error) {
+	host, _ := os.Hostname()
+	file, err := DatabaseFile(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	origin := host + ":" + file
+	id, err := GetSetting(ctx, db, settingSyncID)
+	if err != nil {
+		return "", err
+	}
+	saved, err := GetSetting(ctx, db, settingSyncOrigin)
+	if err != nil {
+		return "", err
+	}
+	if id != "" && saved == origin {
+		return id, nil
+	}
+	if err := db.QueryRowContext(ctx, "SELECT "+newUUIDSQL).Scan(&id); err != nil {
+		return "", err
+	}
+	if err := SetSetting(ctx, db, settingSyncID, id); err != nil {
+		return "", err
+	}
+	return id, SetSetting(ctx, db, settingSyncOrigin, origin)
+}
+
+// eachRow runs query and calls fn with each row's Scan.
+func eachRow(ctx context.Context, db DBTX, query string, fn func(scan func(...any) error) error) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows.Scan); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// loadSyncState reads the database's records with their field clocks. Values are read as stored
+// (CAST AS TEXT keeps the driver from converting them) so writing them back reproduces them exactly.
+func loadSyncState(ctx context.Context, db DBTX) (*syncState, error) {
+	st := &syncState{Records: map[string]*syncRecord{}, Tombstones: map[string]syncTombstone{}}
+	set := func(uuid, field string, value *string) {
+		if rec := st.Records[uuid]; rec != nil {
+			rec.Fields[field] = syncValue{Value: value}
+		}
+	}
+	add := func(uuid, kind string, created sql.NullString) {
+		st.Records[uuid] = &syncRecord{Kind: kind, Fields: map[string]syncValue{}}
+		set(uuid, "created_at", nullStringPtr(created))
+	}
+	err := eachRow(ctx, db, "SELECT uuid, name, color, CAST(created_at AS TEXT) FROM workspaces WHERE uuid IS NOT NULL",
+		func(scan func(...any) error) error {
+			var uuid string
+			var name, color, created sql.NullString
+			if err := scan(&uuid, &name, &color, &created); err != nil {
+				return err
+			}
+			add(uuid, "workspace", created)
+			set(uuid, "name", nullStringPtr(name))
+			set(uuid, "color", nullStringPtr(color))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = eachRow(ctx, db, `SELECT p.uuid, w.uuid, p.name, p.color, CAST(p.created_at AS TEXT)
+		FROM projects p JOIN workspaces w ON w.id = p.workspace_id WHERE p.uuid IS NOT NULL`,
+		func(scan func(...any) error) error {
+			var uuid string
+			var ws, name, color, created sql.NullString
+			if err := scan(&uuid, &ws, &name, &color, &created); err != nil {
+				return err
+			}
+			add(uuid, "project", created)
+			set(uuid, "workspace", nullStringPtr(ws))
+			set(uuid, "name", nullStringPtr(name))
+			set(uuid, "color", nullStringPtr(color))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = eachRow(ctx, db, `SELECT t.uuid, w.uuid, p.uuid, t.title, t.description, t.status, t.priority,
+		CAST(t.due_date AS TEXT), CAST(t.estimate_minutes AS TEXT), CAST(t.estimate_points AS TEXT),
+		CAST(t.created_at AS TEXT), CAST(t.updated_at AS TEXT)
+		FROM tasks t JOIN workspaces w ON w.id = t.workspace_id LEFT JOIN projects p ON p.id = t.project_id
+		WHERE t.uuid IS NOT NULL`,
+		func(scan func(...any) error) error {
+			var uuid string
+			v := make([]sql.NullString, 11)
+			if err := scan(&uuid, &v[0], &v[1], &v[2], &v[3], &v[4], &v[5], &v[6], &v[7], &v[8], &v[9], &v[10]); err != nil {
+				return err
+			}
+			add(uuid, "task", v[9])
+			for i, field := range []string{"workspace", "project", "title", "description", "status", "priority",
+				"due_date", "estimate_minutes", "estimate_points"} {
+				set(uuid, field, nullStringPtr(v[i]))
+			}
+			set(uuid, "updated_at", nullStringPtr(v[10]))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	// Set-valued fields: tags and dependencies, sorted and comma-separated.
+	lists := map[string]map[string][]string{"tags": {}, "depends_on": {}}
+	for field, query := range map[string]string{
+		"tags":       "SELECT t.uuid, g.tag FROM task_tags g JOIN tasks t ON t.id = g.task_id ORDER BY g.tag",
+		"depends_on": "SELECT t.uuid, o.uuid FROM task_dependencies d JOIN tasks t ON t.id = d.task_id JOIN tasks o ON o.id = d.depends_on_id ORDER BY o.uuid",
+	} {
+		err := eachRow(ctx, db, query, func(scan func(...any) error) error {
+			var uuid, item string
+			if err := scan(&uuid, &item); err != nil {
+				return err
+			}
+			lists[field][uuid] = append(lists[field][uuid], item)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for field, byTask := range lists {
+		for uuid, items := range byTask {
+			joined := strings.Join(items, ",")
+			set(uuid, field, &joined)
+		}
+	}
+	err = eachRow(ctx, db, `SELECT t.uuid, f.name, CAST(v.value AS TEXT) FROM task_field_values v
+		JOIN tasks t ON t.id = v.task_id JOIN custom_fields f ON f.id = v.field_id WHERE v.value IS NOT NULL`,
+		func(scan func(...any) error) error {
+			var uuid, name, value string
+			if err := scan(&uuid, &name, &value); err != nil {
+				return err
+			}
+			set(uuid, "field:"+name, &value)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	// Workflows and custom field definitions, one JSON value per workspace.
+	statuses := map[string][]models.ExportStatus{}
+	err = eachRow(ctx, db, `SELECT w.uuid, s.name, COALESCE(s.color, ''), s.is_done FROM statuses s
+		JOIN workspaces w ON w.id = s.workspace_id ORDER BY s.position, s.id`,
+		func(scan func(...any) error) error {
+			var uuid string
+			var s models.ExportStatus
+			if err := scan(&uuid, &s.Name, &s.Color, &s.IsDone); err != nil {
+				return err
+			}
+			statuses[uuid] = append(statuses[uuid], s)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string][]models.ExportField{}
+	err = eachRow(ctx, db, `SELECT w.uuid, f.name, f.type, COALESCE(f.options, '') FROM custom_fields f
+		JOIN workspaces w ON w.id = f.workspace_id ORDER BY f.position, f.id`,
+		func(scan func(...any) error) error {
+			var uuid, options string
+			var f models.ExportField
+			if err := scan(&uuid, &f.Name, &f.Type, &options); err != nil {
+				return err
+			}
+			if options != "" {
+				f.Options = strings.Split(options, ",")
+			}
+			fields[uuid] = append(fields[uuid], f)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	for uuid, rec := range st.Records {
+		if rec.Kind != "workspace" {
+			continue
+		}
+		// Empty lists, not null, so a workspace without custom fields has "[]".
+		list := map[string]any{"statuses": append([]models.ExportStatus{}, statuses[uuid]...), "fields": append([]models.ExportField{}, fields[uuid]...)}
+		for field, v := range list {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			value := string(data)
+			set(uuid, field, &value)
+		}
+	}
+	err = eachRow(ctx, db, `SELECT t.uuid, n.body, CAST(n.created_at AS TEXT) FROM task_notes n
+		JOIN tasks t ON t.id = n.task_id ORDER BY n.id`,
+		func(scan func(...any) error) error {
+			var uuid string
+			var n syncNote
+			if err := scan(&uuid, &n.Body, &n.At); err != nil {
+				return err
+			}
+			if rec := st.Records[uuid]; rec != nil {
+				rec.Notes = append(rec.Notes, n)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = eachRow(ctx, db, `SELECT t.uuid, CAST(e.started_at AS TEXT), CAST(e.ended_at AS TEXT), e.seconds, e.note
+		FROM time_entries e JOIN tasks t ON t.id = e.task_id WHERE e.ended_at IS NOT NULL ORDER BY e.started_at`,
+		func(scan func(...any) error) error {
+			var uuid string
+			var e syncEntry
+			var note sql.NullString
+			if err := scan(&uuid, &e.StartedAt, &e.EndedAt, &e.Seconds, &note); err != nil {
+				return err
+			}
+			e.Note = nullStringPtr(note)
+			if rec := st.Records[uuid]; rec != nil {
+				rec.Entries = append(rec.Entries, e)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	// Clocks: a field without one last changed when its row was created. A clock without a value
+	// is a field that was cleared (e.g. the last tag removed).
+	err = eachRow(ctx, db, "SELECT uuid, field, changed_at FROM sync_clocks", func(scan func(...any) error) error {
+		var uuid, field, at string
+		if err := scan(&uuid, &field, &at); err != nil {
+			return err
+		}
+		if rec := st.Records[uuid]; rec != nil {
+			v := rec.Fields[field]
+			v.At = at
+			rec.Fields[field] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range st.Records {
+		created := ""
+		if v := rec.Fields["created_at"].Value; v != nil {
+			created = *v
+		}
+		for field, v := range rec.Fields {
+			if v.At != "" {
+				continue
+			}
+			switch {
+			case syncDerivedFields[field] && v.Value != nil:
+				v.At = *v.Value
+			default:
+				v.At = created
+			}
+			rec.Fields[field] = v
+		}
+	}
+	err = eachRow(ctx, db, "SELECT uuid, kind, deleted_at FROM sync_tombstones", func(scan func(...any) error) error {
+		var uuid string
+		var t syncTombstone
+		if err := scan(&uuid, &t.Kind, &t.At); err != nil {
+			return err
+		}
+		st.Tombstones[uuid] = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// lastChange is when any field of the record last changed.
+func (r *syncRecord) lastChange() string {
+	last := ""
+	for _, v := range r.Fields {
+		last = max(last, v.At)
+	}
+	return last
+}
+
+// label names the record in conflicts.
+func (r *syncRecord) label() string {
+	for _, field := range []string{"title", "name"} {
+		if v := r.Fields[field].Value; v != nil {
+			return *v
+		}
+	}
+	return ""
+}
+
+func sameSyncValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func syncValueString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// rename gives a record another UUID, updating the workspace and project references to it.
+func (st *syncState) rename(from, to string) {
+	if from == to || st.Records[from] == nil {
+		return
+	}
+	st.Records[to] = st.Records[from]
+	delete(st.Records, from)
+	for _, rec := range st.Records {
+		for _, field := range []string{"workspace", "project"} {
+			if v, ok := rec.Fields[field]; ok && v.Value != nil && *v.Value == from {
+				v.Value = &to
+				rec.Fields[field] = v
+			}
+		}
+	}
+}
+
+// unifyNames treats a workspace (or a list in the same workspace) that each side created on its own
+// under the same name as one, giving both the smaller of the two UUIDs so they converge.
+func unifyNames(local, remote *syncState) {
+	for _, kind := range []string{"workspace", "project"} {
+		key := func(rec *syncRecord) string {
+			return syncValueString(rec.Fields["workspace"].Value) + "/" + syncValueString(rec.Fields["name"].Value)
+		}
+		only := func(st, other *syncState) map[string]string {
+			byName := map[string]string{}
+			for uuid, rec := range st.Records {
+				if _, deleted := other.Tombstones[uuid]; rec.Kind == kind && other.Records[uuid] == nil && !deleted {
+					byName[key(rec)] = uuid
+				}
+			}
+			return byName
+		}
+		remoteByName := only(remote, local)
+		for name, lu := range only(local, remote) {
+			if ru, ok := remoteByName[name]; ok {
+				keep := min(lu, ru)
+				local.rename(lu, keep)
+				remote.rename(ru, keep)
+			}
+		}
+	}
+}
+
+// mergeSyncStates combines two states: per field the later change, per record the later of its last
+// change and a tombstone. since is when local last merged remote's changes, seen when remote last
+// merged local's ("" = never). The inputs may be modified.
+func mergeSyncStates(local, remote *syncState, since, seen string, resolve ConflictResolver) (*syncState, []Conflict, error) {
+	unifyNames(local, remote)
+	merged := &syncState{Records: map[string]*syncRecord{}, Tombstones: map[string]syncTombstone{}}
+	for _, st := range []*syncState{local, remote} {
+		for uuid, t := range st.Tombstones {
+			if old, ok := merged.Tombstones[uuid]; !ok || t.At > old.At {
+				merged.Tombstones[uuid] = t
+			}
+		}
+	}
+	var uuids []string
+	for _, st := range []*syncState{local, remote} {
+		for uuid := range st.Records {
+			if _, seen := merged.Records[uuid]; !seen {
+				merged.Records[uuid] = nil
+				uuids = append(uuids, uuid)
+			}
+		}
+	}
+	sort.Strings(uuids)
+	var conflicts []Conflict
+	for _, uuid := range uuids {
+		l, r := local.Records[uuid], remote.Records[uuid]
+		rec := l
+		switch {
+		case l == nil:
+			rec = r
+		case r != nil:
+			var err error
+			if rec, err = mergeSyncRecord(uuid, l, r, since, seen, resolve, &conflicts); err != nil {
+				return nil, nil, err
+			}
+		}
+		if t, ok := merged.Tombstones[uuid]; ok && t.At >= rec.lastChange() {
+			delete(merged.Records, uuid)
+			continue
+		}
+		delete(merged.Tombstones, uuid)
+		merged.Records[uuid] = rec
+	}
+	// Lists and tasks of a deleted workspace go with it.
+	for _, kind := range []string{"project", "task"} {
+		for uuid, rec := range merged.Records {
+			if rec == nil || rec.Kind != kind {
+				continue
+			}
+			ws := syncValueString(rec.Fields["workspace"].Value)
+			if merged.Records[ws] == nil {
+				at := rec.lastChange()
+				if t, ok := merged.Tombstones[ws]; ok {
+					at = t.At
+				}
+				merged.Tombstones[uuid] = syncTombstone{Kind: kind, At: at}
+				delete(merged.Records, uuid)
+			}
+		}
+	}
+	for uuid, rec := range merged.Records {
+		if rec == nil {
+			delete(merged.Records, uuid)
+		}
+	}
+	return merged, conflicts, nil
+}
+
+func mergeSyncRecord(uuid string, l, r *syncRecord, since, seen string, resolve ConflictResolver, conflicts *[]Conflict) (*syncRecord, error) {
+	rec := &syncRecord{Kind: l.Kind, Fields: map[string]syncValue{}}
+	fields := map[string]bool{}
+	for field := range l.Fields {
+		fields[field] = true
+	}
+	for field := range r.Fields {
+		fields[field] = true
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	for _, field := range names {
+		lv, lok := l.Fields[field]
+		rv, rok := r.Fields[field]
+		switch {
+		case !rok:
+			rec.Fields[field] = lv
+			continue
+		case !lok:
+			rec.Fields[field] = rv
+			continue
+		case sameSyncValue(lv.Value, rv.Value):
+			rec.Fields[field] = syncValue{Value: lv.Value, At: max(lv.At, rv.At)}
+			continue
+		}
+		// Last writer wins; on a tie the larger value, so every database picks the same one.
+		useRemote := rv.At > lv.At || (rv.At == lv.At && syncValueString(rv.Value) > syncValueString(lv.Value))
+		// A conflict is a change remote hasn't seen against one local hasn't. Local's changes up to the
+		// later of since and seen reached remote; before any exchange that is unknown, so there are none.
+		known := max(since, seen)
+		if !syncDerivedFields[field] && known != "" && lv.At > known && rv.At > since {
+			c := Conflict{Kind: l.Kind, UUID: uuid, Label: l.label(), Field: field,
+				Local: lv.Value, Remote: rv.Value, LocalAt: lv.At, RemoteAt: rv.At, UseRemote: useRemote}
+			if resolve != nil {
+				var err error
+				if c.UseRemote, err = resolve(c); err != nil {
+					return nil, err
+				}
+			}
+			*conflicts = append(*conflicts, c)
+			// The chosen value gets the later clock, so it also wins the next sync.
+			v := lv
+			if c.UseRemote {
+				v = rv
+			}
+			rec.Fields[field] = syncValue{Value: v.Value, At: max(lv.At, rv.At)}
+			continue
+		}
+		if useRemote {
+			rec.Fields[field] = rv
+		} else {
+			rec.Fields[field] = lv
+		}
+	}
+	rec.Notes = append(rec.Notes, l.Notes...)
+	for _, n := range r.Notes {
+		if !containsNote(rec.Notes, n) {
+			rec.Notes = append(rec.Notes, n)
+		}
+	}
+	rec.Entries = append(rec.Entries, l.Entries...)
+	for _, e := range r.Entries {
+		if !containsEntry(rec.Entries, e) {
+			rec.Entries = append(rec.Entries, e)
+		}
+	}
+	return rec, nil
+}
+
+func containsNote(notes []syncNote, n syncNote) bool {
+	for _, o := range notes {
+		if o == n {
+			return true
+		}
+	}
+	return false
+}
+
+func containsEntry(entries []syncEntry, e syncEntry) bool {
+	for _, o := range entries {
+		if o.StartedAt == e.StartedAt {
+			return true
+		}
+	}
+	return false
+}
+
+// syncTableOf maps a kind to its table.
+var syncTableOf = map[string]string{"workspace": "workspaces", "project": "projects", "task": "tasks"}
+
+// syncColumnOf maps a kind's plain fields to their columns.
+var syncColumnOf = func() map[string]map[string]string {
+	m := map[string]map[string]string{}
+	for _, t := range syncTables {
+		m[t.kind] = map[string]string{"created_at": "created_at"}
+		for _, c := range t.columns {
+			m[t.kind][c.field] = c.column
+		}
+	}
+	m["task"]["updated_at"] = "updated_at"
+	return m
+}()
+
+// syncApplier writes a merged state into one database.
+type syncApplier struct {
+	ctx    context.Context
+	tx     DBTX
+	merged *syncState
+	ids    map[string]int64 // UUID → row ID
+}
+
+// applySyncState makes the database match the merged state. Fields the database changed after the
+// state was read keep their newer value.
+func applySyncState(ctx context.Context, tx DBTX, merged *syncState) (SyncChanges, error) {
+	var changes SyncChanges
+	cur, err := loadSyncState(ctx, tx)
+	if err != nil {
+		return changes, err
+	}
+	a := &syncApplier{ctx: ctx, tx: tx, merged: merged, ids: map[string]int64{}}
+	for _, table := range syncTableOf {
+		err := eachRow(ctx, tx, "SELECT uuid, id FROM "+table+" WHERE uuid IS NOT NULL", func(scan func(...any) error) error {
+			var uuid string
+			var id int64
+			if err := scan(&uuid, &id); err != nil {
+				return err
+			}
+			a.ids[uuid] = id
+			return nil
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+	uuids := make([]string, 0, len(merged.Records))
+	for uuid := range merged.Records {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	type dependency struct {
+		uuid  string
+		value syncValue
+	}
+	var deps []dependency
+	for _, kind := range syncKinds {
+		for _, uuid := range uuids {
+			rec := merged.Records[uuid]
+			if rec.Kind != kind {
+				continue
+			}
+			old := cur.Records[uuid]
+			if old == nil {
+				adopted, err := a.insert(uuid, rec)
+				if err != nil {
+					return changes, fmt.Errorf("%s %q: %w", kind, rec.label(), err)
+				}
+				old = cur.Records[adopted]
+				if old == nil {
+					old = &syncRecord{Fields: map[string]syncValue{}}
+					changes.Added++
+				}
+			}
+			updated := false
+			for _, field := range syncFieldOrder(rec) {
+				v := rec.Fields[field]
+				ov, ok := old.Fields[field]
+				if ok && (ov.At > v.At || (ov.At == v.At && sameSyncValue(ov.Value, v.Value))) {
+					continue
+				}
+				if field == "depends_on" {
+					deps = append(deps, dependency{uuid, v})
+					continue
+				}
+				if ok && sameSyncValue(ov.Value, v.Value) {
+					// Only the clock moved.
+					if err := a.setClock(uuid, field, v.At); err != nil {
+						return changes, err
+					}
+					continue
+				}
+				if err := a.set(uuid, rec.Kind, field, v); err != nil {
+					return changes, fmt.Errorf("%s %q: %s: %w", kind, rec.label(), field, err)
+				}
+				updated = true
+			}
+			added, err := a.addHistory(uuid, rec)
+			if err != nil {
+				return changes, err
+			}
+			if (updated || added) && cur.Records[uuid] != nil {
+				changes.Updated++
+			}
+		}
+	}
+	for _, d := range deps {
+		if err := a.set(d.uuid, "task", "depends_on", d.value); err != nil {
+			return changes, err
+		}
+	}
+	for i := len(syncKinds) - 1; i >= 0; i-- {
+		for uuid, t := range merged.Tombstones {
+			if t.Kind != syncKinds[i] {
+				continue
+			}
+			res, err := tx.ExecContext(ctx, "DELETE FROM "+syncTableOf[t.Kind]+" WHERE uuid = ?", uuid)
+			if err != nil {
+				return changes, err
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				changes.Deleted++
+			}
+		}
+	}
+	for uuid, t := range merged.Tombstones {
+		if old, ok := cur.Tombstones[uuid]; ok && old.At >= t.At {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO sync_tombstones (uuid, kind, deleted_at) VALUES (?, ?, ?)", uuid, t.Kind, t.At); err != nil {
+			return changes, err
+		}
+	}
+	return changes, pruneAttachmentFiles(ctx, tx)
+}
+
+// syncFieldOrder lists a record's fields with workspace and project first, since field values and
+// statuses depend on the workspace.
+func syncFieldOrder(rec *syncRecord) []string {
+	var names []string
+	for field := range rec.Fields {
+		if field != "workspace" && field != "project" {
+			names = append(names, field)
+		}
+	}
+	sort.Strings(names)
+	for _, field := range []string{"project", "workspace"} {
+		if _, ok := rec.Fields[field]; ok {
+			names = append([]string{field}, names...)
+		}
+	}
+	return names
+}
+
+// insert creates the row for a record, or adopts a row with the same name that the merged state
+// doesn't know (see unifyNames). It returns the UUID the row had before.
+func (a *syncApplier) insert(uuid string, rec *syncRecord) (string, error) {
+	name := syncValueString(rec.Fields["name"].Value)
+	var query string
+	var args []any
+	switch rec.Kind {
+	case "workspace":
+		query, args = "SELECT uuid FROM workspaces WHERE name = ?", []any{name}
+	case "project":
+		query, args = "SELECT uuid FROM projects WHERE workspace_id = ? AND name = ?", []any{a.ref(rec, "workspace"), name}
+	}
+	if query != "" {
+		var existing string
+		err := a.tx.QueryRowContext(a.ctx, query, args...).Scan(&existing)
+		if err == nil && a.merged.Records[existing] == nil {
+			table := syncTableOf[rec.Kind]
+			if _, err := a.tx.ExecContext(a.ctx, "UPDATE "+table+" SET uuid = ? WHERE uuid = ?", uuid, existing); err != nil {
+				return "", err
+			}
+			if _, err := a.tx.ExecContext(a.ctx, "UPDATE sync_clocks SET uuid = ? WHERE uuid = ?", uuid, existing); err != nil {
+				return "", err
+			}
+			a.ids[uuid] = a.ids[existing]
+			return existing, nil
+		}
+	}
+	var res sql.Result
+	var err error
+	switch rec.Kind {
+	case "workspace":
+		res, err = a.tx.ExecContext(a.ctx, "INSERT INTO workspaces (uuid, name) VALUES (?, ?)", uuid, name)
+	case "project":
+		res, err = a.tx.ExecContext(a.ctx, "INSERT INTO projects (uuid, workspace_id, name) VALUES (?, ?, ?)", uuid, a.ref(rec, "workspace"), name)
+	default:
+		res, err = a.tx.ExecContext(a.ctx, "INSERT INTO tasks (uuid, workspace_id, title, status) VALUES (?, ?, ?, ?)",
+			uuid, a.ref(rec, "workspace"), syncValueString(rec.Fields["title"].Value), syncValueString(rec.Fields["status"].Value))
+	}
+	if err != nil {
+		return "", duplicate(err, fmt.Sprintf("%s %q", rec.Kind, name))
+	}
+	a.ids[uuid], _ = res.LastInsertId()
+	return "", nil
+}
+
+// ref returns the row ID of the workspace or project a record refers to (nil = none or unknown).
+func (a *syncApplier) ref(rec *syncRecord, field string) any {
+	v := rec.Fields[field].Value
+	if v == nil {
+		return nil
+	}
+	if id, ok := a.ids[*v]; ok {
+		return id
+	}
+	return nil
+}
+
+func (a *syncApplier) setClock(uuid, field, at string) error {
+	if syncDerivedFields[field] {
+		return nil
+	}
+	_, err := a.tx.ExecContext(a.ctx, "INSERT OR REPLACE INTO sync_clocks (uuid, field, changed_at) VALUES (?, ?, ?)", uuid, field, at)
+	return err
+}
+
+// set writes one field, then its clock (overwriting the one the triggers just recorded).
+func (a *syncApplier) set(uuid, kind, field string, v syncValue) error {
+	id := a.ids[uuid]
+	table := syncTableOf[kind]
+	var err error
+	switch {
+	case field == "workspace" || field == "project":
+		_, err = a.tx.ExecContext(a.ctx, "UPDATE "+table+" SET "+syncColumnOf[kind][field]+" = ? WHERE id = ?",
+			a.ref(&syncRecord{Fields: map[string]syncValue{field: v}}, field), id)
+	case syncColumnOf[kind][field] != "":
+		_, err = a.tx.ExecContext(a.ctx, "UPDATE "+table+" SET "+syncColumnOf[kind][field]+" = ? WHERE id = ?", v.Value, id)
+	case field == "tags":
+		err = a.setTags(id, v.Value)
+	case field == "depends_on":
+		err = a.setDependencies(id, v.Value)
+	case strings.HasPrefix(field, "field:"):
+		err = a.setFieldValue(id, strings.TrimPrefix(field, "field:"), v.Value)
+	case field == "statuses":
+		err = a.setWorkflow(id, v.Value)
+	case field == "fields":
+		err = a.setFieldDefinitions(id, v.Value)
+	default:
+		return nil // a field this version doesn't know
+	}
+	if err != nil {
+		return err
+	}
+	return a.setClock(uuid, field, v.At)
+}
+
+func (a *syncApplier) setTags(taskID int64, value *string) error {
+	if _, err := a.tx.ExecContext(a.ctx, "DELETE FROM task_tags WHERE task_id = ?", taskID); err != nil {
+		return err
+	}
+	for _, tag := range strings.Split(syncValueString(value), ",") {
+		if tag == "" {
+			continue
+		}
+		if _, err := a.tx.ExecContext(a.ctx, "INSERT OR IGNORE INTO task_tags (task_id, tag) VALUES (?, ?)", taskID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *syncApplier) setDependencies(taskID int64, value *string) error {
+	if _, err := a.tx.ExecContext(a.ctx, "DELETE FROM task_dependencies WHERE task_id = ?", taskID); err != nil {
+		return err
+	}
+	for _, uuid := range strings.Split(syncValueString(value), ",") {
+		on, ok := a.ids[uuid]
+		if !ok || on == taskID {
+			continue
+		}
+		if _, err := a.tx.ExecContext(a.ctx, "INSERT OR IGNORE INTO task_dependencies (task_id, depends_on_id) VALUES (?, ?)", taskID, on); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setFieldValue stores a custom field value, typed like "todo task edit --field" would. Values of
+// fields the workspace doesn't have are dropped.
+func (a *syncApplier) setFieldValue(taskID int64, name string, value *string) error {
+	f, err := scanField(a.tx.QueryRowContext(a.ctx, "SELECT "+fieldColumns+
+		" FROM custom_fields WHERE name = ? AND workspace_id = (SELECT workspace_id FROM tasks WHERE id = ?)", name, taskID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		_, err := a.tx.ExecContext(a.ctx, "DELETE FROM task_field_values WHERE task_id = ? AND field_id = ?", taskID, f.ID)
+		return err
+	}
+	var stored any = *value
+	if typed, err := parseFieldValue(f, *value); err == nil {
+		stored = typed
+	}
+	_, err = a.tx.ExecContext(a.ctx, "INSERT OR REPLACE INTO task_field_values (task_id, field_id, value) VALUES (?, ?, ?)", taskID, f.ID, stored)
+	return err
+}
+
+// setWorkflow makes the workspace's statuses match; a status still used by a task is kept.
+func (a *syncApplier) setWorkflow(workspaceID int64, value *string) error {
+	if value == nil {
+		return nil
+	}
+	var list []models.ExportStatus
+	if err := json.Unmarshal([]byte(syncValueString(value)), &list); err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for i, s := range list {
+		keep[s.Name] = true
+		_, err := a.tx.ExecContext(a.ctx, `INSERT INTO statuses (workspace_id, name, position, color, is_done) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (workspace_id, name) DO UPDATE SET position = excluded.position, color = excluded.color, is_done = excluded.is_done`,
+			workspaceID, s.Name, i, nullString(s.Color), s.IsDone)
+		if err != nil {
+			return err
+		}
+	}
+	return a.removeMissing(workspaceID, "statuses", keep,
+		"DELETE FROM statuses WHERE workspace_id = ? AND name = ? AND NOT EXISTS (SELECT 1 FROM tasks WHERE workspace_id = statuses.workspace_id AND status = statuses.name)")
+}
+
+// setFieldDefinitions makes the workspace's custom fields match.
+func (a *syncApplier) setFieldDefinitions(workspaceID int64, value *string) error {
+	var list []models.ExportField
+	if value == nil {
+		value = new(string)
+		*value = "[]"
+	}
+	if err := json.Unmarshal([]byte(*value), &list); err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for i, f := range list {
+		keep[f.Name] = true
+		_, err := a.tx.ExecContext(a.ctx, `INSERT INTO custom_fields (workspace_id, name, type, options, position) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (workspace_id, name) DO UPDATE SET type = excluded.type, options = excluded.options, position = excluded.position`,
+			workspaceID, f.Name, f.Type, nullString(strings.Join(f.Options, ",")), i)
+		if err != nil {
+			return err
+		}
+	}
+	return a.removeMissing(workspaceID, "custom_fields", keep, "DELETE FROM custom_fields WHERE workspace_id = ? AND name = ?")
+}
+
+func (a *syncApplier) removeMissing(workspaceID int64, table string, keep map[string]bool, deleteSQL string) error {
+	var names []string
+	err := eachRow(a.ctx, a.tx, fmt.Sprintf("SELECT name FROM %s WHERE workspace_id = %d", table, workspaceID), func(scan func(...any) error) error {
+		var name string
+		if err := scan(&name); err != nil {
+			return err
+		}
+		if !keep[name] {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := a.tx.ExecContext(a.ctx, deleteSQL, workspaceID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addHistory adds the notes and finished time entries of a task the database doesn't have yet.
+func (a *syncApplier) addHistory(uuid string, rec *syncRecord) (bool, error) {
+	id := a.ids[uuid]
+	added := false
+	for _, n := range rec.Notes {
+		res, err := a.tx.ExecContext(a.ctx, `INSERT INTO task_notes (task_id, body, created_at) SELECT ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM task_notes WHERE task_id = ? AND body = ? AND CAST(created_at AS TEXT) = ?)`,
+			id, n.Body, n.At, id, n.Body, n.At)
+		if err != nil {
+			return false, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added = true
+		}
+	}
+	for _, e := range rec.Entries {
+		res, err := a.tx.ExecContext(a.ctx, `INSERT INTO time_entries (task_id, started_at, ended_at, seconds, note) SELECT ?, ?, ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM time_entries WHERE task_id = ? AND CAST(started_at AS TEXT) = ?)`,
+			id, e.StartedAt, e.EndedAt, e.Seconds, e.Note, id, e.StartedAt)
+		if err != nil {
+			return false, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added = true
+		}
+	}
+	return added, nil
+}
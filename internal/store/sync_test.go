@@ -0,0 +1,223 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// syncedPair returns two databases that synced once, with a workspace "Work" holding the task "plan".
+func syncedPair(t *testing.T) (a, b *sql.DB, task models.Task) {
+	t.Helper()
+	dir := t.TempDir()
+	a, b = openTest(t, filepath.Join(dir, "a.db")), openTest(t, filepath.Join(dir, "b.db"))
+	t.Cleanup(func() { closeTest(t, a); closeTest(t, b) })
+	w := must(CreateWorkspace(ctx, a, "Work"))(t)
+	task = must(CreateTask(ctx, a, w.ID, nil, "plan", "", "", models.PriorityNone, nil))(t)
+	syncTest(t, a, b, nil)
+	return a, b, task
+}
+
+// syncTest syncs a with b and fails the test on an error. Sync clocks have millisecond resolution:
+// it waits a little before and after, so changes on either side of it are ordered.
+func syncTest(t *testing.T, a, b *sql.DB, resolve ConflictResolver) SyncReport {
+	t.Helper()
+	tick()
+	report, err := SyncDatabases(ctx, a, b, resolve)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	tick()
+	return report
+}
+
+func tick() { time.Sleep(5 * time.Millisecond) }
+
+// taskByUUID returns the task with uuid in db, or ErrNotFound.
+func taskByUUID(t *testing.T, db *sql.DB, uuid string) (models.Task, error) {
+	t.Helper()
+	id, err := ResolveTaskID(ctx, db, uuid)
+	if err != nil {
+		return models.Task{}, err
+	}
+	return GetTask(ctx, db, id)
+}
+
+func editTestTask(t *testing.T, db *sql.DB, uuid string, fn func(task *models.Task)) {
+	t.Helper()
+	task := must(taskByUUID(t, db, uuid))(t)
+	fn(&task)
+	must(UpdateTask(ctx, db, task.ID, task.Title, task.Description, task.Status, task.Priority, task.DueDate))(t)
+}
+
+func TestSyncMergesEditsToDifferentFields(t *testing.T) {
+	a, b, task := syncedPair(t)
+	if got := must(taskByUUID(t, b, task.UUID))(t); got.Title != "plan" {
+		t.Fatalf("first sync: title %q, want plan", got.Title)
+	}
+	editTestTask(t, a, task.UUID, func(task *models.Task) { task.Title = "plan the release" })
+	editTestTask(t, b, task.UUID, func(task *models.Task) { task.Priority = models.PriorityHigh })
+	if report := syncTest(t, a, b, nil); len(report.Conflicts) != 0 {
+		t.Errorf("conflicts %+v, want none", report.Conflicts)
+	}
+	for name, db := range map[string]*sql.DB{"a": a, "b": b} {
+		got := must(taskByUUID(t, db, task.UUID))(t)
+		if got.Title != "plan the release" || got.Priority != models.PriorityHigh {
+			t.Errorf("%s: title %q, priority %q; want both edits", name, got.Title, got.Priority)
+		}
+	}
+}
+
+func TestSyncConflictingEdits(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		useRemote bool
+		want      string
+	}{
+		{"later wins", true, "b's title"},
+		{"keep local", false, "a's title"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b, task := syncedPair(t)
+			editTestTask(t, a, task.UUID, func(task *models.Task) { task.Title = "a's title" })
+			tick()
+			editTestTask(t, b, task.UUID, func(task *models.Task) { task.Title = "b's title" })
+			var asked []Conflict
+			report := syncTest(t, a, b, func(c Conflict) (bool, error) {
+				asked = append(asked, c)
+				return tc.useRemote, nil
+			})
+			if len(asked) != 1 || asked[0].Field != "title" || !asked[0].UseRemote {
+				t.Fatalf("resolver asked %+v, want title with the later (remote) change chosen", asked)
+			}
+			if len(report.Conflicts) != 1 || report.Conflicts[0].UseRemote != tc.useRemote {
+				t.Errorf("reported %+v", report.Conflicts)
+			}
+			for name, db := range map[string]*sql.DB{"a": a, "b": b} {
+				if got := must(taskByUUID(t, db, task.UUID))(t); got.Title != tc.want {
+					t.Errorf("%s: title %q, want %q", name, got.Title, tc.want)
+				}
+			}
+			// The choice sticks: the next sync has nothing to decide.
+			if report := syncTest(t, a, b, nil); len(report.Conflicts) != 0 {
+				t.Errorf("second sync: conflicts %+v", report.Conflicts)
+			}
+		})
+	}
+}
+
+func TestSyncDeleteAgainstEdit(t *testing.T) {
+	t.Run("delete after edit", func(t *testing.T) {
+		a, b, task := syncedPair(t)
+		editTestTask(t, b, task.UUID, func(task *models.Task) { task.Title = "edited" })
+		tick()
+		if err := DeleteTask(ctx, a, must(taskByUUID(t, a, task.UUID))(t).ID); err != nil {
+			t.Fatal(err)
+		}
+		report := syncTest(t, a, b, nil)
+		for name, db := range map[string]*sql.DB{"a": a, "b": b} {
+			if _, err := taskByUUID(t, db, task.UUID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: %v, want the task deleted", name, err)
+			}
+		}
+		if report.Remote.Deleted != 1 {
+			t.Errorf("b: %+v, want one deleted", report.Remote)
+		}
+	})
+	t.Run("edit after delete", func(t *testing.T) {
+		a, b, task := syncedPair(t)
+		if err := DeleteTask(ctx, a, must(taskByUUID(t, a, task.UUID))(t).ID); err != nil {
+			t.Fatal(err)
+		}
+		tick()
+		editTestTask(t, b, task.UUID, func(task *models.Task) { task.Title = "edited" })
+		syncTest(t, a, b, nil)
+		for name, db := range map[string]*sql.DB{"a": a, "b": b} {
+			if got, err := taskByUUID(t, db, task.UUID); err != nil || got.Title != "edited" {
+				t.Errorf("%s: %q, %v; want the edited task back", name, got.Title, err)
+			}
+		}
+	})
+}
+
+// Changing a workflow or a field definition after the first sync used to break every later sync.
+func TestSyncWorkflowAndFieldChanges(t *testing.T) {
+	a, b, _ := syncedPair(t)
+	wa := must(GetWorkspaceByName(ctx, a, "Work"))(t)
+	must(CreateStatus(ctx, a, wa.ID, "blocked", "red", false))(t)
+	size := must(CreateField(ctx, a, wa.ID, "size", "enum", []string{"s", "m"}))(t)
+	syncTest(t, a, b, nil)
+
+	wb := must(GetWorkspaceByName(ctx, b, "Work"))(t)
+	if _, err := GetStatusByName(ctx, b, wb.ID, "blocked"); err != nil {
+		t.Errorf("b: status blocked: %v", err)
+	}
+	field := must(GetFieldByName(ctx, b, wb.ID, "size"))(t)
+	must(UpdateField(ctx, b, field.ID, "size", []string{"s", "m", "l"}))(t)
+	blocked := must(GetStatusByName(ctx, b, wb.ID, "blocked"))(t)
+	must(UpdateStatus(ctx, b, blocked.ID, "blocked", "orange", false))(t)
+	syncTest(t, a, b, nil)
+
+	if got := must(GetField(ctx, a, size.ID))(t); !slices.Equal(got.Options, []string{"s", "m", "l"}) {
+		t.Errorf("a: size options %v, want [s m l]", got.Options)
+	}
+	if got := must(GetStatusByName(ctx, a, wa.ID, "blocked"))(t); got.Color != "orange" {
+		t.Errorf("a: blocked color %q, want orange", got.Color)
+	}
+	syncTest(t, a, b, nil)
+}
+
+func TestSyncDirRoundTrip(t *testing.T) {
+	tmp := t.TempDir()
+	shared := filepath.Join(tmp, "shared")
+	a, b := openTest(t, filepath.Join(tmp, "a.db")), openTest(t, filepath.Join(tmp, "b.db"))
+	defer closeTest(t, a)
+	defer closeTest(t, b)
+	if err := os.Mkdir(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	syncDir := func(db *sql.DB) SyncReport {
+		t.Helper()
+		tick()
+		report, err := SyncDir(ctx, db, shared, nil)
+		if err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		tick()
+		return report
+	}
+
+	w := must(CreateWorkspace(ctx, a, "Work"))(t)
+	task := must(CreateTask(ctx, a, w.ID, nil, "plan", "", "", models.PriorityNone, nil))(t)
+	syncDir(a)
+	if report := syncDir(b); report.Peers != 1 || report.Local.Added == 0 {
+		t.Fatalf("b's first sync: %+v", report)
+	}
+	syncDir(a)
+
+	editTestTask(t, a, task.UUID, func(task *models.Task) { task.Title = "plan the release" })
+	editTestTask(t, b, task.UUID, func(task *models.Task) { task.Priority = models.PriorityHigh })
+	wb := must(GetWorkspaceByName(ctx, b, "Work"))(t)
+	must(CreateStatus(ctx, b, wb.ID, "blocked", "", false))(t)
+	for _, db := range []*sql.DB{a, b, a} {
+		if report := syncDir(db); len(report.Conflicts) != 0 {
+			t.Errorf("conflicts %+v, want none", report.Conflicts)
+		}
+	}
+	for name, db := range map[string]*sql.DB{"a": a, "b": b} {
+		got := must(taskByUUID(t, db, task.UUID))(t)
+		if got.Title != "plan the release" || got.Priority != models.PriorityHigh {
+			t.Errorf("%s: title %q, priority %q; want both edits", name, got.Title, got.Priority)
+		}
+		w := must(GetWorkspaceByName(ctx, db, "Work"))(t)
+		if _, err := GetStatusByName(ctx, db, w.ID, "blocked"); err != nil {
+			t.Errorf("%s: status blocked: %v", name, err)
+		}
+	}
+}
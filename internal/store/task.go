@@ -1,60 +1,64 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cli-todo/internal/models"
 )
 
-func CreateTask(db *sql.DB, workspaceID int64, projectID *int64, title, description, status, priority string, dueDate *time.Time) (models.Task, error) {
-	if status == "" {
-		status = "todo"
+// taskColumns is the column list scanned by scanTask, qualified so it can be joined. "blocked" is derived: the task depends
+// on at least one task whose status is not marked as done in that task's workspace.
+const taskColumns = `tasks.id, COALESCE(tasks.uuid, ''), tasks.workspace_id, tasks.project_id, tasks.title, tasks.description, tasks.status, tasks.priority,
+	tasks.due_date, tasks.estimate_minutes, tasks.estimate_points,
+	EXISTS (SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id = d.depends_on_id
+	        WHERE d.task_id = tasks.id
+	          AND NOT EXISTS (SELECT 1 FROM statuses s WHERE s.workspace_id = b.workspace_id AND s.name = b.status AND s.is_done = 1)) AS blocked,
+	(SELECT GROUP_CONCAT(tag, ',') FROM (SELECT tag FROM task_tags WHERE task_id = tasks.id ORDER BY tag)) AS tags,
+	(SELECT json_group_object(f.name, CASE f.type WHEN 'bool' THEN json(CASE WHEN v.value THEN 'true' ELSE 'false' END) ELSE v.value END)
+	 FROM task_field_values v JOIN custom_fields f ON f.id = v.field_id WHERE v.task_id = tasks.id) AS fields,
+	tasks.created_at, tasks.updated_at`
+
+func CreateTask(ctx context.Context, db DBTX, workspaceID int64, projectID *int64, title, description, status string, priority models.Priority, dueDate *time.Time) (models.Task, error) {
+	pri, err := nullPriority(priority)
+	if err != nil {
+		return models.Task{}, err
+	}
+	status, err = resolveTaskStatus(ctx, db, workspaceID, status)
+	if err != nil {
+		return models.Task{}, err
 	}
-	res, err := db.Exec(
+	res, err := exec(ctx, db,
 		`INSERT INTO tasks (workspace_id, project_id, title, description, status, priority, due_date) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		workspaceID, projectID, title, description, status, nullPriority(priority), nullTime(dueDate),
+		workspaceID, projectID, title, description, status, pri, nullTime(dueDate),
 	)
 	if err != nil {
 		return models.Task{}, err
 	}
 	id, _ := res.LastInsertId()
-	return GetTask(db, id)
+	return GetTask(ctx, db, id)
 }
 
-func GetTask(db *sql.DB, id int64) (models.Task, error) {
-	var t models.Task
-	var desc, pri sql.NullString
-	var projID sql.NullInt64
-	var due sql.NullTime
-	err := db.QueryRow(
-		"SELECT id, workspace_id, project_id, title, description, status, priority, due_date, created_at, updated_at FROM tasks WHERE id = ?", id,
-	).Scan(&t.ID, &t.WorkspaceID, &projID, &t.Title, &desc, &t.Status, &pri, &due, &t.CreatedAt, &t.UpdatedAt)
-	if err != nil {
-		return models.Task{}, err
-	}
-	if projID.Valid {
-		t.ProjectID = &projID.Int64
-	}
-	t.Description = desc.String
-	t.Priority = pri.String
-	if due.Valid {
-		t.DueDate = &due.Time
-	}
-	return t, nil
+func GetTask(ctx context.Context, db DBTX, id int64) (models.Task, error) {
+	t, err := scanTask(db.QueryRowContext(ctx, "SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
+	return t, notFound(err, fmt.Sprintf("task %d", id))
 }
 
-func ListTasks(db *sql.DB, workspaceID int64, projectID *int64) ([]models.Task, error) {
+func ListTasks(ctx context.Context, db DBTX, workspaceID int64, projectID *int64) ([]models.Task, error) {
 	var rows *sql.Rows
 	var err error
 	if projectID == nil {
-		rows, err = db.Query(
-			`SELECT id, workspace_id, project_id, title, description, status, priority, due_date, created_at, updated_at FROM tasks WHERE workspace_id = ? AND project_id IS NULL ORDER BY created_at`,
+		rows, err = db.QueryContext(ctx,
+			`SELECT `+taskColumns+` FROM tasks WHERE workspace_id = ? AND project_id IS NULL ORDER BY created_at`,
 			workspaceID,
 		)
 	} else {
-		rows, err = db.Query(
-			`SELECT id, workspace_id, project_id, title, description, status, priority, due_date, created_at, updated_at FROM tasks WHERE workspace_id = ? AND project_id = ? ORDER BY created_at`,
+		rows, err = db.QueryContext(ctx,
+			`SELECT `+taskColumns+` FROM tasks WHERE workspace_id = ? AND project_id = ? ORDER BY created_at`,
 			workspaceID, *projectID,
 		)
 	}
@@ -65,9 +69,9 @@ func ListTasks(db *sql.DB, workspaceID int64, projectID *int64) ([]models.Task,
 	return scanTasks(rows)
 }
 
-func ListAllTasksInWorkspace(db *sql.DB, workspaceID int64) ([]models.Task, error) {
-	rows, err := db.Query(
-		`SELECT id, workspace_id, project_id, title, description, status, priority, due_date, created_at, updated_at FROM tasks WHERE workspace_id = ? ORDER BY project_id, created_at`,
+func ListAllTasksInWorkspace(ctx context.Context, db DBTX, workspaceID int64) ([]models.Task, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+taskColumns+` FROM tasks WHERE workspace_id = ? ORDER BY project_id, created_at`,
 		workspaceID,
 	)
 	if err != nil {
@@ -77,32 +81,111 @@ func ListAllTasksInWorkspace(db *sql.DB, workspaceID int64) ([]models.Task, erro
 	return scanTasks(rows)
 }
 
-func UpdateTask(db *sql.DB, id int64, title, description, status, priority string, dueDate *time.Time) (models.Task, error) {
-	_, err := db.Exec(
-		`UPDATE tasks SET title = ?, description = ?, status = ?, priority = ?, due_date = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		title, description, status, nullPriority(priority), nullTime(dueDate), id,
+// ListNextActions returns the workspace's actionable tasks: not done and not blocked by
+// unfinished dependencies, highest priority and earliest due first. projectID nil = all lists.
+func ListNextActions(ctx context.Context, db DBTX, workspaceID int64, projectID *int64) ([]models.Task, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT * FROM (SELECT `+taskColumns+` FROM tasks WHERE workspace_id = ? AND (? IS NULL OR project_id = ?)
+		   AND NOT EXISTS (SELECT 1 FROM statuses s WHERE s.workspace_id = tasks.workspace_id AND s.name = tasks.status AND s.is_done = 1))
+		 WHERE NOT blocked
+		 ORDER BY CASE priority WHEN 'high' THEN 0 WHEN 'medium' THEN 1 WHEN 'low' THEN 2 ELSE 3 END,
+		          due_date IS NULL, due_date, created_at`,
+		workspaceID, projectID, projectID,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTasks(rows)
+}
+
+func UpdateTask(ctx context.Context, db DBTX, id int64, title, description, status string, priority models.Priority, dueDate *time.Time) (models.Task, error) {
+	pri, err := nullPriority(priority)
 	if err != nil {
 		return models.Task{}, err
 	}
-	return GetTask(db, id)
+	var t models.Task
+	err = WithTx(ctx, db, func(tx DBTX) error {
+		var workspaceID int64
+		if err := tx.QueryRowContext(ctx, "SELECT workspace_id FROM tasks WHERE id = ?", id).Scan(&workspaceID); err != nil {
+			return notFound(err, fmt.Sprintf("task %d", id))
+		}
+		status, err := resolveTaskStatus(ctx, tx, workspaceID, status)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE tasks SET title = ?, description = ?, status = ?, priority = ?, due_date = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			title, description, status, pri, nullTime(dueDate), id,
+		)
+		if err != nil {
+			return err
+		}
+		t, err = GetTask(ctx, tx, id)
+		return err
+	})
+	return t, err
 }
 
 // SetTaskProject sets the project (list) for a task. projectID nil = default list.
-func SetTaskProject(db *sql.DB, taskID int64, projectID *int64) (models.Task, error) {
-	_, err := db.Exec(
+func SetTaskProject(ctx context.Context, db DBTX, taskID int64, projectID *int64) (models.Task, error) {
+	_, err := exec(ctx, db,
 		`UPDATE tasks SET project_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
 		projectID, taskID,
 	)
 	if err != nil {
 		return models.Task{}, err
 	}
-	return GetTask(db, taskID)
+	return GetTask(ctx, db, taskID)
 }
 
-func DeleteTask(db *sql.DB, id int64) error {
-	_, err := db.Exec("DELETE FROM tasks WHERE id = ?", id)
-	return err
+// AddTaskTags attaches tags to a task; tags it already has are ignored. Either all tags are added or none.
+func AddTaskTags(ctx context.Context, db DBTX, taskID int64, tags []string) (models.Task, error) {
+	var t models.Task
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		for _, tag := range tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			if strings.Contains(tag, ",") {
+				return fmt.Errorf("tag %q must not contain a comma", tag)
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO task_tags (task_id, tag) VALUES (?, ?)", taskID, tag); err != nil {
+				return err
+			}
+		}
+		var err error
+		t, err = GetTask(ctx, tx, taskID)
+		return err
+	})
+	return t, err
+}
+
+func RemoveTaskTags(ctx context.Context, db DBTX, taskID int64, tags []string) (models.Task, error) {
+	var t models.Task
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		for _, tag := range tags {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM task_tags WHERE task_id = ? AND tag = ?", taskID, strings.TrimSpace(tag)); err != nil {
+				return err
+			}
+		}
+		var err error
+		t, err = GetTask(ctx, tx, taskID)
+		return err
+	})
+	return t, err
+}
+
+func DeleteTask(ctx context.Context, db DBTX, id int64) error {
+	res, err := exec(ctx, db, "DELETE FROM tasks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res, fmt.Sprintf("task %d", id)); err != nil {
+		return err
+	}
+	return pruneAttachmentFiles(ctx, db)
 }
 
 func nullTime(t *time.Time) interface{} {
@@ -112,36 +195,89 @@ func nullTime(t *time.Time) interface{} {
 	return *t
 }
 
-// nullPriority returns nil for empty/invalid priority so SQL stores NULL (CHECK allows only 'low'|'medium'|'high'|NULL).
-func nullPriority(priority string) interface{} {
-	if priority == "" {
-		return nil
+// priorityNames accepts the full names and their abbreviations.
+var priorityNames = map[string]models.Priority{
+	"low": models.PriorityLow, "l": models.PriorityLow,
+	"medium": models.PriorityMedium, "med": models.PriorityMedium, "m": models.PriorityMedium,
+	"high": models.PriorityHigh, "h": models.PriorityHigh,
+}
+
+// ParsePriority reads a priority as typed by the user: low, medium or high (any case, or l, m, h).
+// Empty input is no priority.
+func ParsePriority(s string) (models.Priority, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return models.PriorityNone, nil
+	}
+	p, ok := priorityNames[strings.ToLower(s)]
+	if !ok {
+		return "", invalidPriority(s)
+	}
+	return p, nil
+}
+
+func invalidPriority(s string) error {
+	names := make([]string, len(models.Priorities))
+	for i, p := range models.Priorities {
+		names[i] = string(p)
+	}
+	return ErrInvalidField{Field: "priority", Value: s, Reason: oneOf(names)}
+}
+
+// nullPriority returns nil for no priority so SQL stores NULL, and rejects values the CHECK constraint would.
+func nullPriority(p models.Priority) (interface{}, error) {
+	if !p.Valid() {
+		return nil, invalidPriority(string(p))
+	}
+	if p == models.PriorityNone {
+		return nil, nil
+	}
+	return string(p), nil
+}
+
+func scanTask(row interface{ Scan(...any) error }) (models.Task, error) {
+	var t models.Task
+	var desc, pri sql.NullString
+	var projID sql.NullInt64
+	var due sql.NullTime
+	var tags, fields sql.NullString
+	var estMinutes sql.NullInt64
+	var estPoints sql.NullFloat64
+	if err := row.Scan(&t.ID, &t.UUID, &t.WorkspaceID, &projID, &t.Title, &desc, &t.Status, &pri, &due, &estMinutes, &estPoints, &t.Blocked, &tags, &fields, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return models.Task{}, err
 	}
-	switch priority {
-	case "low", "medium", "high":
-		return priority
+	if projID.Valid {
+		t.ProjectID = &projID.Int64
 	}
-	return nil
+	t.Description = desc.String
+	t.Priority = models.Priority(pri.String)
+	if due.Valid {
+		t.DueDate = &due.Time
+	}
+	if estMinutes.Valid {
+		t.EstimateMinutes = &estMinutes.Int64
+	}
+	if estPoints.Valid {
+		t.EstimatePoints = &estPoints.Float64
+	}
+	if tags.String != "" {
+		t.Tags = strings.Split(tags.String, ",")
+	}
+	if fields.String != "" && fields.String != "{}" {
+		if err := json.Unmarshal([]byte(fields.String), &t.Fields); err != nil {
+			return models.Task{}, fmt.Errorf("task %d fields: %w", t.ID, err)
+		}
+	}
+	return t, nil
 }
 
 func scanTasks(rows *sql.Rows) ([]models.Task, error) {
 	var list []models.Task
 	for rows.Next() {
-		var t models.Task
-		var desc, pri sql.NullString
-		var projID sql.NullInt64
-		var due sql.NullTime
-		if err := rows.Scan(&t.ID, &t.WorkspaceID, &projID, &t.Title, &desc, &t.Status, &pri, &due, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		t, err := scanTask(rows)
+		if err != nil {
 			return nil, err
 		}
-		if projID.Valid {
-			t.ProjectID = &projID.Int64
-		}
-		t.Description = desc.String
-		t.Priority = pri.String
-		if due.Valid {
-			t.DueDate = &due.Time
-		}
 		list = append(list, t)
 	}
 	return list, rows.Err()
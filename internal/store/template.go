@@ -0,0 +1,326 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// templateVar matches {{name}} (spaces allowed inside the braces).
+var templateVar = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// builtinTemplateVars are always available: the name of the project being created and the start date.
+var builtinTemplateVars = []string{"project", "date"}
+
+// ParseTemplate decodes and checks a template in its JSON form.
+func ParseTemplate(data []byte) (models.Template, error) {
+	var tpl models.Template
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&tpl); err != nil {
+		return models.Template{}, fmt.Errorf("template: %w", err)
+	}
+	if len(tpl.Tasks) == 0 {
+		return models.Template{}, fmt.Errorf("template %q has no tasks", tpl.Name)
+	}
+	for i, t := range tpl.Tasks {
+		if strings.TrimSpace(t.Title) == "" {
+			return models.Template{}, fmt.Errorf("template %q: task %d has no title", tpl.Name, i+1)
+		}
+		if _, err := nullPriority(t.Priority); err != nil {
+			return models.Template{}, fmt.Errorf("template %q: task %q: %w", tpl.Name, t.Title, err)
+		}
+		if _, err := ParseDueOffset(t.Due, time.Now()); err != nil {
+			return models.Template{}, fmt.Errorf("template %q: task %q: %w", tpl.Name, t.Title, err)
+		}
+		if _, _, err := ParseEstimate(t.Estimate); err != nil {
+			return models.Template{}, fmt.Errorf("template %q: task %q: %w", tpl.Name, t.Title, err)
+		}
+	}
+	return tpl, nil
+}
+
+// LoadTemplateFile reads a template from a JSON file; the name defaults to the file name.
+func LoadTemplateFile(path string) (models.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.Template{}, err
+	}
+	tpl, err := ParseTemplate(data)
+	if err != nil {
+		return models.Template{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if tpl.Name == "" {
+		base := path[strings.LastIndexAny(path, `/\`)+1:]
+		tpl.Name = strings.TrimSuffix(base, ".json")
+	}
+	return tpl, nil
+}
+
+// SaveTemplate stores a template, replacing any template with the same name.
+func SaveTemplate(ctx context.Context, db DBTX, tpl models.Template) (models.Template, error) {
+	if strings.TrimSpace(tpl.Name) == "" {
+		return models.Template{}, fmt.Errorf("template name is empty")
+	}
+	body, err := json.MarshalIndent(tpl, "", "  ")
+	if err != nil {
+		return models.Template{}, err
+	}
+	if _, err := ParseTemplate(body); err != nil {
+		return models.Template{}, err
+	}
+	if _, err := exec(ctx, db,
+		"INSERT INTO templates (name, body) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET body = excluded.body",
+		tpl.Name, string(body),
+	); err != nil {
+		return models.Template{}, err
+	}
+	return GetTemplate(ctx, db, tpl.Name)
+}
+
+func scanTemplate(row interface{ Scan(...any) error }) (models.Template, error) {
+	var id int64
+	var name, body string
+	var created time.Time
+	if err := row.Scan(&id, &name, &body, &created); err != nil {
+		return models.Template{}, err
+	}
+	var tpl models.Template
+	if err := json.Unmarshal([]byte(body), &tpl); err != nil {
+		return models.Template{}, fmt.Errorf("template %q: %w", name, err)
+	}
+	tpl.ID, tpl.Name, tpl.CreatedAt = id, name, created
+	return tpl, nil
+}
+
+func GetTemplate(ctx context.Context, db DBTX, name string) (models.Template, error) {
+	tpl, err := scanTemplate(db.QueryRowContext(ctx, "SELECT id, name, body, created_at FROM templates WHERE name = ?", name))
+	return tpl, notFound(err, fmt.Sprintf("template %q", name))
+}
+
+func ListTemplates(ctx context.Context, db DBTX) ([]models.Template, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, name, body, created_at FROM templates ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.Template
+	for rows.Next() {
+		tpl, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, tpl)
+	}
+	return list, rows.Err()
+}
+
+func DeleteTemplate(ctx context.Context, db DBTX, name string) error {
+	res, err := exec(ctx, db, "DELETE FROM templates WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, fmt.Sprintf("template %q", name))
+}
+
+// TemplateFromTasks captures tasks as a template. Due dates become offsets from the earliest due
+// date among the tasks; statuses are left out so instantiated tasks start at the beginning of the workflow.
+func TemplateFromTasks(name string, tasks []models.Task) models.Template {
+	tpl := models.Template{Name: name}
+	var first *time.Time
+	for _, t := range tasks {
+		if t.DueDate != nil && (first == nil || t.DueDate.Before(*first)) {
+			first = t.DueDate
+		}
+	}
+	day := func(d time.Time) time.Time { return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC) }
+	for _, t := range tasks {
+		tt := models.TemplateTask{
+			Title:       t.Title,
+			Description: t.Description,
+			Priority:    t.Priority,
+			Estimate:    FormatEstimate(t),
+			Tags:        t.Tags,
+		}
+		if t.DueDate != nil {
+			tt.Due = fmt.Sprintf("%+dd", int(day(*t.DueDate).Sub(day(*first)).Hours()/24))
+		}
+		tpl.Tasks = append(tpl.Tasks, tt)
+	}
+	return tpl
+}
+
+// TemplateVars returns the variables a template needs, besides the built-in project and date.
+func TemplateVars(tpl models.Template) []string {
+	seen := map[string]bool{}
+	for _, b := range builtinTemplateVars {
+		seen[b] = true
+	}
+	var vars []string
+	collect := func(s string) {
+		for _, m := range templateVar.FindAllStringSubmatch(s, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				vars = append(vars, m[1])
+			}
+		}
+	}
+	collect(tpl.Description)
+	for _, t := range tpl.Tasks {
+		collect(t.Title)
+		collect(t.Description)
+		for _, tag := range t.Tags {
+			collect(tag)
+		}
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// ParseDueOffset parses a template due value: "+3d", "2w", "-1d" relative to start, or YYYY-MM-DD.
+// An empty string means no due date.
+func ParseDueOffset(s string, start time.Time) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if d, err := time.Parse("2006-01-02", s); err == nil {
+		return &d, nil
+	}
+	unit := s[len(s)-1]
+	days := 1
+	switch unit {
+	case 'd':
+	case 'w':
+		days = 7
+	default:
+		return nil, fmt.Errorf("due %q: use an offset like +3d or +2w, or YYYY-MM-DD", s)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(s[:len(s)-1], "+"))
+	if err != nil {
+		return nil, fmt.Errorf("due %q: use an offset like +3d or +2w, or YYYY-MM-DD", s)
+	}
+	d := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, n*days)
+	return &d, nil
+}
+
+// CreateProjectFromTemplate creates a project named name in the workspace with the template's tasks,
+// all or nothing. vars fill the template's {{variables}}; due offsets count from start.
+func CreateProjectFromTemplate(ctx context.Context, db DBTX, workspaceID int64, name string, tpl models.Template, vars map[string]string, start time.Time) (models.Project, error) {
+	tasks, err := expandTemplate(ctx, db, workspaceID, name, tpl, vars, start)
+	if err != nil {
+		return models.Project{}, err
+	}
+	var p models.Project
+	err = WithTx(ctx, db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx, "INSERT INTO projects (workspace_id, name, color) VALUES (?, ?, ?)", workspaceID, name, nullString(tpl.Color))
+		if err != nil {
+			return err
+		}
+		id, _ := res.LastInsertId()
+		if err := insertTemplateTasks(ctx, tx, &id, tasks); err != nil {
+			return err
+		}
+		p, err = GetProject(ctx, tx, id)
+		return err
+	})
+	return p, err
+}
+
+// ApplyTemplate adds the template's tasks to an existing list (projectID nil = default list), all or nothing.
+func ApplyTemplate(ctx context.Context, db DBTX, workspaceID int64, projectID *int64, tpl models.Template, vars map[string]string, start time.Time) error {
+	listName := "Default"
+	if projectID != nil {
+		p, err := GetProject(ctx, db, *projectID)
+		if err != nil {
+			return err
+		}
+		listName = p.Name
+	}
+	tasks, err := expandTemplate(ctx, db, workspaceID, listName, tpl, vars, start)
+	if err != nil {
+		return err
+	}
+	return WithTx(ctx, db, func(tx DBTX) error {
+		return insertTemplateTasks(ctx, tx, projectID, tasks)
+	})
+}
+
+// expandTemplate fills in the template's variables and resolves statuses, due dates and estimates
+// into tasks ready to insert.
+func expandTemplate(ctx context.Context, db DBTX, workspaceID int64, projectName string, tpl models.Template, vars map[string]string, start time.Time) ([]models.Task, error) {
+	all := map[string]string{"project": projectName, "date": start.Format("2006-01-02")}
+	for k, v := range vars {
+		all[k] = v
+	}
+	var missing []string
+	for _, v := range TemplateVars(tpl) {
+		if _, ok := all[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("template %q needs --var %s", tpl.Name, strings.Join(missing, "=... --var ")+"=...")
+	}
+	expand := func(s string) string {
+		return templateVar.ReplaceAllStringFunc(s, func(m string) string {
+			return all[templateVar.FindStringSubmatch(m)[1]]
+		})
+	}
+	var tasks []models.Task
+	for _, tt := range tpl.Tasks {
+		t := models.Task{WorkspaceID: workspaceID, Title: expand(tt.Title), Description: expand(tt.Description), Priority: tt.Priority}
+		var err error
+		if t.Status, err = resolveTaskStatus(ctx, db, workspaceID, tt.Status); err != nil {
+			return nil, fmt.Errorf("task %q: %w", tt.Title, err)
+		}
+		if t.DueDate, err = ParseDueOffset(tt.Due, start); err != nil {
+			return nil, fmt.Errorf("task %q: %w", tt.Title, err)
+		}
+		if t.EstimateMinutes, t.EstimatePoints, err = ParseEstimate(tt.Estimate); err != nil {
+			return nil, fmt.Errorf("task %q: %w", tt.Title, err)
+		}
+		for _, tag := range tt.Tags {
+			if tag = strings.TrimSpace(expand(tag)); tag != "" {
+				if strings.Contains(tag, ",") {
+					return nil, fmt.Errorf("task %q: tag %q must not contain a comma", tt.Title, tag)
+				}
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func insertTemplateTasks(ctx context.Context, tx DBTX, projectID *int64, tasks []models.Task) error {
+	for _, t := range tasks {
+		pri, err := nullPriority(t.Priority)
+		if err != nil {
+			return fmt.Errorf("task %q: %w", t.Title, err)
+		}
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO tasks (workspace_id, project_id, title, description, status, priority, due_date, estimate_minutes, estimate_points)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			t.WorkspaceID, projectID, t.Title, t.Description, t.Status, pri, nullTime(t.DueDate), t.EstimateMinutes, t.EstimatePoints,
+		)
+		if err != nil {
+			return fmt.Errorf("task %q: %w", t.Title, err)
+		}
+		id, _ := res.LastInsertId()
+		for _, tag := range t.Tags {
+			if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO task_tags (task_id, tag) VALUES (?, ?)", id, tag); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cli-todo/internal/models"
+)
+
+// sqlTimeLayout matches CURRENT_TIMESTAMP so SQLite date functions work on stored values.
+const sqlTimeLayout = "2006-01-02 15:04:05"
+
+// entrySecondsSQL is the duration of time entry "e"; running timers count up to now.
+const entrySecondsSQL = `CASE WHEN e.ended_at IS NULL
+	THEN CAST(strftime('%s', 'now') AS INTEGER) - CAST(strftime('%s', e.started_at) AS INTEGER)
+	ELSE e.seconds END`
+
+const timeEntryColumns = "e.id, e.task_id, e.started_at, e.ended_at, " + entrySecondsSQL + ", e.note"
+
+func sqlTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeLayout)
+}
+
+func scanTimeEntry(row interface{ Scan(...any) error }) (models.TimeEntry, error) {
+	var e models.TimeEntry
+	var ended sql.NullTime
+	var note sql.NullString
+	if err := row.Scan(&e.ID, &e.TaskID, &e.StartedAt, &ended, &e.Seconds, &note); err != nil {
+		return models.TimeEntry{}, err
+	}
+	if ended.Valid {
+		e.EndedAt = &ended.Time
+	}
+	e.Note = note.String
+	return e, nil
+}
+
+// StartTimer starts a timer on the task, stopping any other running timer first,
+// and moves the task to "in_progress" if the workspace workflow has that status.
+func StartTimer(ctx context.Context, db DBTX, taskID int64) (models.TimeEntry, error) {
+	var out models.TimeEntry
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		task, err := GetTask(ctx, tx, taskID)
+		if err != nil {
+			return err
+		}
+		if _, err := StopTimer(ctx, tx); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, "INSERT INTO time_entries (task_id, started_at) VALUES (?, ?)", taskID, sqlTime(time.Now()))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE tasks SET status = 'in_progress', updated_at = CURRENT_TIMESTAMP
+			 WHERE id = ? AND status <> 'in_progress'
+			   AND EXISTS (SELECT 1 FROM statuses WHERE workspace_id = ? AND name = 'in_progress')`,
+			taskID, task.WorkspaceID,
+		); err != nil {
+			return err
+		}
+		id, _ := res.LastInsertId()
+		out, err = GetTimeEntry(ctx, tx, id)
+		return err
+	})
+	return out, err
+}
+
+// StopTimer stops the running timer and returns the finished entry (ErrNotFound if none is running).
+func StopTimer(ctx context.Context, db DBTX) (models.TimeEntry, error) {
+	var out models.TimeEntry
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		running, err := RunningTimer(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if running == nil {
+			return fmt.Errorf("running timer %w", ErrNotFound)
+		}
+		now := time.Now()
+		seconds := max(0, int64(now.Sub(running.StartedAt).Seconds()))
+		if _, err := tx.ExecContext(ctx, "UPDATE time_entries SET ended_at = ?, seconds = ? WHERE id = ?", sqlTime(now), seconds, running.ID); err != nil {
+			return err
+		}
+		out, err = GetTimeEntry(ctx, tx, running.ID)
+		return err
+	})
+	return out, err
+}
+
+// RunningTimer returns the running timer, or nil if none is running.
+func RunningTimer(ctx context.Context, db DBTX) (*models.TimeEntry, error) {
+	e, err := scanTimeEntry(db.QueryRowContext(ctx, "SELECT "+timeEntryColumns+" FROM time_entries e WHERE e.ended_at IS NULL"))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// AddTimeEntry logs a finished block of work on a task.
+func AddTimeEntry(ctx context.Context, db DBTX, taskID int64, startedAt time.Time, d time.Duration, note string) (models.TimeEntry, error) {
+	if d <= 0 {
+		return models.TimeEntry{}, fmt.Errorf("duration must be positive")
+	}
+	if _, err := GetTask(ctx, db, taskID); err != nil {
+		return models.TimeEntry{}, err
+	}
+	res, err := exec(ctx, db,
+		"INSERT INTO time_entries (task_id, started_at, ended_at, seconds, note) VALUES (?, ?, ?, ?, ?)",
+		taskID, sqlTime(startedAt), sqlTime(startedAt.Add(d)), int64(d.Seconds()), nullString(note),
+	)
+	if err != nil {
+		return models.TimeEntry{}, err
+	}
+	id, _ := res.LastInsertId()
+	return GetTimeEntry(ctx, db, id)
+}
+
+func GetTimeEntry(ctx context.Context, db DBTX, id int64) (models.TimeEntry, error) {
+	e, err := scanTimeEntry(db.QueryRowContext(ctx, "SELECT "+timeEntryColumns+" FROM time_entries e WHERE e.id = ?", id))
+	return e, notFound(err, fmt.Sprintf("time entry %d", id))
+}
+
+func ListTimeEntries(ctx context.Context, db DBTX, taskID int64) ([]models.TimeEntry, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+timeEntryColumns+" FROM time_entries e WHERE e.task_id = ? ORDER BY e.started_at", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.TimeEntry
+	for rows.Next() {
+		e, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func DeleteTimeEntry(ctx context.Context, db DBTX, id int64) error {
+	res, err := exec(ctx, db, "DELETE FROM time_entries WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, fmt.Sprintf("time entry %d", id))
+}
+
+// TaskTimeTotals returns logged seconds per task for a list (projectID nil = default list).
+func TaskTimeTotals(ctx context.Context, db DBTX, workspaceID int64, projectID *int64) (map[int64]int64, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.id, SUM(`+entrySecondsSQL+`) FROM time_entries e JOIN tasks t ON t.id = e.task_id
+		 WHERE t.workspace_id = ? AND t.project_id IS ?
+		 GROUP BY t.id`,
+		workspaceID, projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	totals := make(map[int64]int64)
+	for rows.Next() {
+		var id, seconds int64
+		if err := rows.Scan(&id, &seconds); err != nil {
+			return nil, err
+		}
+		totals[id] = seconds
+	}
+	return totals, rows.Err()
+}
+
+// ProjectTimeTotals returns logged time per list of a workspace, default list first.
+func ProjectTimeTotals(ctx context.Context, db DBTX, workspaceID int64) ([]models.TimeTotal, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.project_id, COALESCE(p.name, 'Default'), SUM(`+entrySecondsSQL+`)
+		 FROM time_entries e JOIN tasks t ON t.id = e.task_id LEFT JOIN projects p ON p.id = t.project_id
+		 WHERE t.workspace_id = ?
+		 GROUP BY t.project_id ORDER BY t.project_id IS NOT NULL, p.name`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.TimeTotal
+	for rows.Next() {
+		var tt models.TimeTotal
+		var projID sql.NullInt64
+		if err := rows.Scan(&projID, &tt.Name, &tt.Seconds); err != nil {
+			return nil, err
+		}
+		if projID.Valid {
+			tt.ProjectID = &projID.Int64
+		}
+		list = append(list, tt)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A tree is the database as text files, one per workspace, list and task, for keeping in git:
+//
+//	workspaces/<uuid>.txt   projects/<uuid>.txt   tasks/<uuid>.txt
+//	notes/<task uuid>.jsonl time/<task uuid>.jsonl
+//
+// Record files have one "field = value" line per field (values are JSON), separated by blank lines so
+// git merges edits of different fields cleanly. Notes and time entries only grow; their files use git's
+// union merge, so entries added by two people are both kept.
+
+// treeDirs maps kinds to their directories.
+var treeDirs = map[string]string{"workspace": "workspaces", "project": "projects", "task": "tasks"}
+
+// treeRawFields hold JSON arrays, written as they are rather than as a quoted string.
+var treeRawFields = map[string]bool{"statuses": true, "fields": true}
+
+const treeAttributes = "# Written by todo: notes and time entries only grow, so keep both sides' lines when merging.\n" +
+	"notes/*.jsonl merge=union\ntime/*.jsonl merge=union\n"
+
+// WriteTree writes the database to dir as a tree, removing the files of records that no longer exist.
+// Files are only rewritten when their content changes.
+func WriteTree(ctx context.Context, db DBTX, dir string) error {
+	st, err := loadSyncState(ctx, db)
+	if err != nil {
+		return err
+	}
+	want := map[string][]byte{".gitattributes": []byte(treeAttributes)}
+	for uuid, rec := range st.Records {
+		want[filepath.Join(treeDirs[rec.Kind], uuid+".txt")] = formatTreeRecord(rec)
+		if len(rec.Notes) > 0 {
+			// Row order differs between databases; sort so every copy writes the same file.
+			sort.Slice(rec.Notes, func(i, j int) bool {
+				a, b := rec.Notes[i], rec.Notes[j]
+				return a.At < b.At || (a.At == b.At && a.Body < b.Body)
+			})
+			want[filepath.Join("notes", uuid+".jsonl")] = jsonLines(rec.Notes)
+		}
+		if len(rec.Entries) > 0 {
+			want[filepath.Join("time", uuid+".jsonl")] = jsonLines(rec.Entries)
+		}
+	}
+	for _, sub := range []string{"workspaces", "projects", "tasks", "notes", "time"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			name := filepath.Join(sub, e.Name())
+			if _, ok := want[name]; !ok && !e.IsDir() {
+				if err := os.Remove(filepath.Join(dir, name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for name, data := range want {
+		path := filepath.Join(dir, name)
+		if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+			continue
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadTree makes the database match the tree in dir, e.g. after git merged a teammate's changes.
+// Records and fields the database changed after since (a sync clock, see TreeClock) are kept, so edits
+// not yet written to the tree survive; everything else takes the tree's value, and records missing
+// from the tree are deleted.
+func LoadTree(ctx context.Context, db DBTX, dir, since string) (SyncChanges, error) {
+	tree, err := readTree(dir)
+	if err != nil {
+		return SyncChanges{}, err
+	}
+	var changes SyncChanges
+	err = WithTx(ctx, db, func(tx DBTX) error {
+		cur, err := loadSyncState(ctx, tx)
+		if err != nil {
+			return err
+		}
+		now := TreeClock()
+		for uuid, rec := range tree.Records {
+			old := cur.Records[uuid]
+			if old == nil {
+				for field, v := range rec.Fields {
+					v.At = now
+					rec.Fields[field] = v
+				}
+				continue
+			}
+			changed := false
+			for field, ov := range old.Fields {
+				if syncDerivedFields[field] {
+					continue
+				}
+				v, ok := rec.Fields[field]
+				switch {
+				case ok && sameSyncValue(v.Value, ov.Value), ov.At > since:
+					// Unchanged, or changed here since the tree was last written: keep the database's.
+					rec.Fields[field] = ov
+				default:
+					rec.Fields[field] = syncValue{Value: v.Value, At: now}
+					changed = true
+				}
+			}
+			for field, v := range rec.Fields {
+				if _, ok := old.Fields[field]; !ok {
+					rec.Fields[field] = syncValue{Value: v.Value, At: now}
+					changed = true
+				}
+			}
+			if rec.Kind == "task" && changed {
+				rec.Fields["updated_at"] = syncValue{Value: &now, At: now}
+			}
+		}
+		for uuid, rec := range cur.Records {
+			if tree.Records[uuid] == nil {
+				if rec.lastChange() > since {
+					tree.Records[uuid] = rec // new here, not written to the tree yet
+				} else {
+					tree.Tombstones[uuid] = syncTombstone{Kind: rec.Kind, At: now}
+				}
+			}
+		}
+		changes, err = applySyncState(ctx, tx, tree)
+		return err
+	})
+	return changes, err
+}
+
+// TreeClock returns the current time as a sync clock, to pass to LoadTree later.
+func TreeClock() string {
+	return time.Now().UTC().Format(syncClockLayout)
+}
+
+func formatTreeRecord(rec *syncRecord) []byte {
+	var b bytes.Buffer
+	names := make([]string, 0, len(rec.Fields))
+	for field := range rec.Fields {
+		if field != "updated_at" { // changes with every edit; it would make every concurrent edit conflict
+			names = append(names, field)
+		}
+	}
+	sort.Strings(names)
+	for i, field := range names {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		v := rec.Fields[field].Value
+		switch {
+		case v != nil && treeRawFields[field]:
+			fmt.Fprintf(&b, "%s = %s\n", field, *v)
+		default:
+			fmt.Fprintf(&b, "%s = %s\n", field, jsonValue(v))
+		}
+	}
+	return b.Bytes()
+}
+
+// jsonValue encodes v on one line without escaping <, > and &, which git diffs would show.
+func jsonValue(v any) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return bytes.TrimRight(b.Bytes(), "\n")
+}
+
+func jsonLines[T any](items []T) []byte {
+	var b bytes.Buffer
+	for _, item := range items {
+		b.Write(jsonValue(item))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+func readTree(dir string) (*syncState, error) {
+	st := &syncState{Records: map[string]*syncRecord{}, Tombstones: map[string]syncTombstone{}}
+	for _, kind := range syncKinds {
+		files, err := filepath.Glob(filepath.Join(dir, treeDirs[kind], "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			rec, err := readTreeRecord(file, kind)
+			if err != nil {
+				return nil, err
+			}
+			st.Records[strings.TrimSuffix(filepath.Base(file), ".txt")] = rec
+		}
+	}
+	for uuid, rec := range st.Records {
+		if rec.Kind != "task" {
+			continue
+		}
+		if err := readJSONLines(filepath.Join(dir, "notes", uuid+".jsonl"), &rec.Notes); err != nil {
+			return nil, err
+		}
+		if err := readJSONLines(filepath.Join(dir, "time", uuid+".jsonl"), &rec.Entries); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func readTreeRecord(file, kind string) (*syncRecord, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rec := &syncRecord{Kind: kind, Fields: map[string]syncValue{}}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "<<<<<<<") || strings.HasPrefix(line, ">>>>>>>") || strings.HasPrefix(line, "=======") {
+			return nil, fmt.Errorf("%s:%d: unresolved merge conflict", file, n+1)
+		}
+		field, raw, ok := strings.Cut(line, " = ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected field = value", file, n+1)
+		}
+		var value *string
+		if treeRawFields[field] && raw != "null" {
+			value = &raw
+		} else if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", file, n+1, field, err)
+		}
+		rec.Fields[field] = syncValue{Value: value}
+	}
+	return rec, nil
+}
+
+func readJSONLines[T any](file string, into *[]T) error {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for n := 1; sc.Scan(); n++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var item T
+		if err := json.Unmarshal(sc.Bytes(), &item); err != nil {
+			return fmt.Errorf("%s:%d: %w", file, n, err)
+		}
+		*into = append(*into, item)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX is what store functions run on: the database itself (*sql.DB) or a transaction (*sql.Tx)
+// started by WithTx, so several calls can be made atomic together.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// WithTx runs fn in a transaction: committed when fn returns nil, rolled back when it fails or ctx is
+// cancelled. Inside a transaction already (db is a *sql.Tx), fn simply joins it.
+func WithTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	var tx *sql.Tx
+	err := retryBusy(ctx, func() error {
+		var err error
+		tx, err = conn.BeginTx(ctx, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
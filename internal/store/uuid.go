@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minShortID is the shortest UUID prefix shown for a task, like git's short commit hashes.
+const minShortID = 7
+
+// minIDPrefix is the shortest UUID prefix accepted as a task ID.
+const minIDPrefix = 4
+
+// ShortIDLength returns the prefix length (at least 7) at which every task UUID is still unique.
+func ShortIDLength(ctx context.Context, db DBTX) (int, error) {
+	for n := minShortID; n < 36; n++ {
+		var unique bool
+		err := db.QueryRowContext(ctx, "SELECT COUNT(DISTINCT substr(uuid, 1, ?)) = COUNT(uuid) FROM tasks", n).Scan(&unique)
+		if err != nil {
+			return 0, err
+		}
+		if unique {
+			return n, nil
+		}
+	}
+	return 36, nil
+}
+
+// ShortID shortens a UUID to n characters (see ShortIDLength).
+func ShortID(uuid string, n int) string {
+	if len(uuid) <= n {
+		return uuid
+	}
+	return uuid[:n]
+}
+
+// ResolveTaskID reads a task reference: the integer ID, or a unique prefix of the task's UUID
+// (at least 4 characters).
+func ResolveTaskID(ctx context.Context, db DBTX, ref string) (int64, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		var exists bool
+		if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM tasks WHERE id = ?)", id).Scan(&exists); err != nil {
+			return 0, err
+		}
+		// Digits can also start a UUID; fall back to a prefix match when no task has that number.
+		if exists || len(ref) < minIDPrefix {
+			return id, nil
+		}
+	}
+	if len(ref) < minIDPrefix || strings.Trim(ref, "0123456789abcdef-") != "" {
+		return 0, ErrInvalidField{Field: "task id", Value: ref, Reason: fmt.Sprintf("use a number or at least %d characters of the task's UUID", minIDPrefix)}
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM tasks WHERE uuid GLOB ? LIMIT 2", ref+"*")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("task %s %w", ref, ErrNotFound)
+	case 1:
+		return ids[0], nil
+	}
+	return 0, ErrInvalidField{Field: "task id", Value: ref, Reason: "several tasks start with it; give more characters"}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Watcher notices commits made through other connections, e.g. by another todo process.
+// It holds a connection of its own because PRAGMA data_version is per connection.
+type Watcher struct {
+	conn    *sql.Conn
+	version int64
+}
+
+// NewWatcher starts watching db for changes.
+func NewWatcher(ctx context.Context, db *sql.DB) (*Watcher, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{conn: conn}
+	if w.version, err = w.dataVersion(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Changed reports whether anything was committed since the last call (or NewWatcher).
+// Commits made by this process through other connections of the pool count too.
+func (w *Watcher) Changed(ctx context.Context) (bool, error) {
+	v, err := w.dataVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	changed := v != w.version
+	w.version = v
+	return changed, nil
+}
+
+// Close releases the watcher's connection.
+func (w *Watcher) Close() error {
+	return w.conn.Close()
+}
+
+func (w *Watcher) dataVersion(ctx context.Context) (int64, error) {
+	var v int64
+	err := w.conn.QueryRowContext(ctx, "PRAGMA data_version").Scan(&v)
+	return v, err
+}
@@ -1,27 +1,37 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/cli-todo/internal/models"
 )
 
-func CreateWorkspace(db *sql.DB, name string) (models.Workspace, error) {
-	res, err := db.Exec("INSERT INTO workspaces (name) VALUES (?)", name)
-	if err != nil {
-		return models.Workspace{}, err
-	}
-	id, _ := res.LastInsertId()
-	return GetWorkspace(db, id)
+func CreateWorkspace(ctx context.Context, db DBTX, name string) (models.Workspace, error) {
+	var w models.Workspace
+	err := WithTx(ctx, db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx, "INSERT INTO workspaces (name) VALUES (?)", name)
+		if err != nil {
+			return duplicate(err, fmt.Sprintf("workspace %q", name))
+		}
+		id, _ := res.LastInsertId()
+		if _, err := tx.ExecContext(ctx, seedStatusesSQL+" WHERE w.id = ?", id); err != nil {
+			return err
+		}
+		w, err = GetWorkspace(ctx, tx, id)
+		return err
+	})
+	return w, err
 }
 
-func GetWorkspace(db *sql.DB, id int64) (models.Workspace, error) {
+func GetWorkspace(ctx context.Context, db DBTX, id int64) (models.Workspace, error) {
 	var w models.Workspace
 	var color sql.NullString
-	err := db.QueryRow("SELECT id, name, color, created_at FROM workspaces WHERE id = ?", id).
-		Scan(&w.ID, &w.Name, &color, &w.CreatedAt)
+	err := db.QueryRowContext(ctx, "SELECT id, COALESCE(uuid, ''), name, color, created_at FROM workspaces WHERE id = ?", id).
+		Scan(&w.ID, &w.UUID, &w.Name, &color, &w.CreatedAt)
 	if err != nil {
-		return models.Workspace{}, err
+		return models.Workspace{}, notFound(err, fmt.Sprintf("workspace %d", id))
 	}
 	if color.Valid {
 		w.Color = color.String
@@ -29,13 +39,13 @@ func GetWorkspace(db *sql.DB, id int64) (models.Workspace, error) {
 	return w, nil
 }
 
-func GetWorkspaceByName(db *sql.DB, name string) (models.Workspace, error) {
+func GetWorkspaceByName(ctx context.Context, db DBTX, name string) (models.Workspace, error) {
 	var w models.Workspace
 	var color sql.NullString
-	err := db.QueryRow("SELECT id, name, color, created_at FROM workspaces WHERE name = ?", name).
-		Scan(&w.ID, &w.Name, &color, &w.CreatedAt)
+	err := db.QueryRowContext(ctx, "SELECT id, COALESCE(uuid, ''), name, color, created_at FROM workspaces WHERE name = ?", name).
+		Scan(&w.ID, &w.UUID, &w.Name, &color, &w.CreatedAt)
 	if err != nil {
-		return models.Workspace{}, err
+		return models.Workspace{}, notFound(err, fmt.Sprintf("workspace %q", name))
 	}
 	if color.Valid {
 		w.Color = color.String
@@ -43,8 +53,8 @@ func GetWorkspaceByName(db *sql.DB, name string) (models.Workspace, error) {
 	return w, nil
 }
 
-func ListWorkspaces(db *sql.DB) ([]models.Workspace, error) {
-	rows, err := db.Query("SELECT id, name, color, created_at FROM workspaces ORDER BY name")
+func ListWorkspaces(ctx context.Context, db DBTX) ([]models.Workspace, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, COALESCE(uuid, ''), name, color, created_at FROM workspaces ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +63,7 @@ func ListWorkspaces(db *sql.DB) ([]models.Workspace, error) {
 	for rows.Next() {
 		var w models.Workspace
 		var color sql.NullString
-		if err := rows.Scan(&w.ID, &w.Name, &color, &w.CreatedAt); err != nil {
+		if err := rows.Scan(&w.ID, &w.UUID, &w.Name, &color, &w.CreatedAt); err != nil {
 			return nil, err
 		}
 		if color.Valid {
@@ -64,27 +74,33 @@ func ListWorkspaces(db *sql.DB) ([]models.Workspace, error) {
 	return list, rows.Err()
 }
 
-func UpdateWorkspace(db *sql.DB, id int64, name string) (models.Workspace, error) {
-	_, err := db.Exec("UPDATE workspaces SET name = ? WHERE id = ?", name, id)
+func UpdateWorkspace(ctx context.Context, db DBTX, id int64, name string) (models.Workspace, error) {
+	_, err := exec(ctx, db, "UPDATE workspaces SET name = ? WHERE id = ?", name, id)
 	if err != nil {
-		return models.Workspace{}, err
+		return models.Workspace{}, duplicate(err, fmt.Sprintf("workspace %q", name))
 	}
-	return GetWorkspace(db, id)
+	return GetWorkspace(ctx, db, id)
 }
 
-func SetWorkspaceColor(db *sql.DB, id int64, color string) (models.Workspace, error) {
+func SetWorkspaceColor(ctx context.Context, db DBTX, id int64, color string) (models.Workspace, error) {
 	var val interface{} = nil
 	if color != "" {
 		val = color
 	}
-	_, err := db.Exec("UPDATE workspaces SET color = ? WHERE id = ?", val, id)
+	_, err := exec(ctx, db, "UPDATE workspaces SET color = ? WHERE id = ?", val, id)
 	if err != nil {
 		return models.Workspace{}, err
 	}
-	return GetWorkspace(db, id)
+	return GetWorkspace(ctx, db, id)
 }
 
-func DeleteWorkspace(db *sql.DB, id int64) error {
-	_, err := db.Exec("DELETE FROM workspaces WHERE id = ?", id)
-	return err
+func DeleteWorkspace(ctx context.Context, db DBTX, id int64) error {
+	res, err := exec(ctx, db, "DELETE FROM workspaces WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res, fmt.Sprintf("workspace %d", id)); err != nil {
+		return err
+	}
+	return pruneAttachmentFiles(ctx, db)
 }
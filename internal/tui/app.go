@@ -1,12 +1,16 @@
 package tui
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 
-	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/cli-todo/internal/models"
 	"github.com/cli-todo/internal/store"
@@ -19,6 +23,7 @@ const (
 	screenWorkspaces screen = iota
 	screenProjects
 	screenTasks
+	screenTaskDetail
 )
 
 type inputKind int
@@ -30,6 +35,7 @@ const (
 	inputNewTask
 	inputNewTaskDue
 	inputNewTaskPriority
+	inputNewTaskEstimate
 	inputNewTaskStatus
 	inputEditWorkspace
 	inputEditProject
@@ -37,11 +43,36 @@ const (
 	inputTaskDueDate
 	inputWorkspaceColor
 	inputProjectColor
+	inputTaskEstimate
+	inputSearch
+	inputTaskNote
+	inputPickTemplate
+	inputTemplateVar
+	inputPickProfile
+	inputProfilePassphrase
 )
 
+// Options configure the TUI. Workspace (and optionally Project) pin where it opens, e.g. from a .todo.yaml;
+// Tags are added to tasks created in that workspace. The zero value opens the workspace list with defaults.
+type Options struct {
+	Workspace  string
+	Project    string
+	Tags       []string
+	Theme      string            // dark (default), light or mono
+	Keys       map[string]string // action -> key, see config.KeyBindings
+	DateLayout string            // Go layout for dates, default YYYY-MM-DD
+	Profile    string            // active profile ("" = none), shown in the footer
+	Profiles   map[string]string // profile name -> database path, for the profile switcher
+}
+
 type model struct {
+	ctx               context.Context // cancelled when the TUI exits or the process is interrupted
 	db                *sql.DB
 	dbPath            string
+	opts              Options
+	keys              keyMap
+	watcher           *store.Watcher // notices changes by other processes (nil if unavailable)
+	stale             bool           // changed outside the TUI while a prompt was open
 	screen            screen
 	width             int
 	height            int
@@ -50,6 +81,8 @@ type model struct {
 	workspaceCursor   int
 	projects          []models.Project
 	tasks             []models.Task
+	statuses          []models.Status // workflow of the selected workspace
+	viewCapacity      string          // remaining estimates of the open tasks on the tasks screen
 	selectedWorkspace *models.Workspace
 	selectedProjectID *int64
 	inputMode         inputKind
@@ -60,32 +93,109 @@ type model struct {
 	editProjectID     int64
 	editTaskID        int64
 	moveTaskID        int64 // when non-zero, selecting project to move task to
+	// Task detail screen
+	detailTask        models.Task
+	detailBlockers    []models.Task // tasks the detail task depends on
+	detailBlocks      []models.Task // tasks that depend on the detail task
+	detailLogged      int64         // seconds logged on the detail task
+	detailNotes       []models.Note
+	detailFields      []models.CustomField // custom fields of the detail task's workspace, in order
+	detailAttachments []models.Attachment
+	attachmentCursor  int
+	noteArea          textarea.Model // new note on the detail screen
+	// Global search overlay
+	searchHits   []models.SearchHit
+	searchCursor int
+	// New project from a template: picker, then name, then one prompt per variable
+	templates         []models.Template
+	templateCursor    int // 0 = blank project
+	pendingTemplate   *models.Template
+	newProjectName    string
+	templateVarQueue  []string // variables still to ask for
+	templateVarValues map[string]string
+	// Profile switcher
+	profileNames   []string
+	profileCursor  int
+	profilePending string // the encrypted profile the passphrase is asked for
+	// Running timer shown in the footer (nil = none), refreshed every tick
+	runningTimer     *models.TimeEntry
+	runningTaskTitle string
 	// Draft for multi-step new task (title required; due, priority, status optional)
 	newTaskTitle    string
 	newTaskDue      string
 	newTaskPriority string
+	newTaskEstimate string
 	newTaskStatus   string
 }
 
-func New(db *sql.DB) *model {
-	path, err := store.DBPath()
-	if err != nil {
+func New(ctx context.Context, db *sql.DB, opts Options) *model {
+	path, err := store.DatabaseFile(ctx, db)
+	if err != nil || path == "" {
 		path = "?"
 	}
 	ti := textinput.New()
 	ti.Placeholder = "Name..."
 	ti.CharLimit = 200
 	ti.Width = 40
-	return &model{db: db, dbPath: path, screen: screenWorkspaces, input: ti}
+	ta := textarea.New()
+	ta.Placeholder = "Write a note..."
+	ta.ShowLineNumbers = false
+	ta.SetWidth(60)
+	ta.SetHeight(5)
+	watcher, _ := store.NewWatcher(ctx, db)
+	return &model{ctx: ctx, db: db, dbPath: path, opts: opts, watcher: watcher, keys: newKeyMap(opts.Keys), screen: screenWorkspaces, input: ti, noteArea: ta}
 }
 
 func (m *model) Init() tea.Cmd {
 	m.width = 60
 	m.height = 20
-	return m.refreshList()
+	m.refreshTimer()
+	cmd := m.refreshList()
+	if m.opts.Workspace != "" {
+		cmd = m.openStart()
+	}
+	return tea.Batch(cmd, tick())
+}
+
+// openStart opens the pinned workspace, and its list when one is pinned. Unknown names leave
+// the workspace list open with an error.
+func (m *model) openStart() tea.Cmd {
+	for i := range m.workspaces {
+		if m.workspaces[i].Name != m.opts.Workspace {
+			continue
+		}
+		m.workspaceCursor = i
+		m.selectedWorkspace = &m.workspaces[i]
+		m.screen = screenProjects
+		if m.opts.Project == "" {
+			return m.refreshList()
+		}
+		projects, err := store.ListProjects(m.ctx, m.db, m.selectedWorkspace.ID)
+		if err != nil {
+			m.err = err.Error()
+			return m.refreshList()
+		}
+		for _, p := range projects {
+			if p.Name == m.opts.Project {
+				id := p.ID
+				m.selectedProjectID = &id
+				m.screen = screenTasks
+				return m.refreshList()
+			}
+		}
+		cmd := m.refreshList()
+		m.err = fmt.Sprintf("list %q not found in workspace %q", m.opts.Project, m.opts.Workspace)
+		return cmd
+	}
+	m.err = fmt.Sprintf("workspace %q not found", m.opts.Workspace)
+	return nil
 }
 
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(tickMsg); ok {
+		m.refreshTimer()
+		return m, tea.Batch(m.checkExternalChanges(), tick())
+	}
 	if m.inputMode != inputNone {
 		return m.updateInput(msg)
 	}
@@ -96,64 +206,105 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		if m.screen != screenWorkspaces {
+		if m.screen == screenProjects || m.screen == screenTasks {
 			m.list.SetSize(msg.Width, msg.Height-4)
 		}
 		return m, nil
 	case tea.KeyMsg:
 		k := msg.String()
-		if k == "ctrl+c" || k == "q" {
+		if k == "ctrl+c" || k == m.keys.Quit {
 			return m, tea.Quit
 		}
 		if k == "backspace" || k == "ctrl+h" || k == "left" {
 			return m.handleBack()
 		}
-		if k == "a" {
+		if k == m.keys.Add {
 			return m.handleAdd()
 		}
-		if k == "d" {
+		if k == m.keys.Delete {
 			return m.handleDelete()
 		}
 		if k == "enter" {
 			return m.handleSelect()
 		}
-		if k == "e" {
+		if k == m.keys.Edit {
 			return m.handleEdit()
 		}
+		if k == m.keys.Search {
+			return m.handleSearchOpen()
+		}
+		if k == m.keys.Profile {
+			return m.handleProfileOpen()
+		}
 		if m.screen == screenTasks {
-			if k == "s" {
+			if k == m.keys.Status {
 				return m.handleTaskCycleStatus()
 			}
-			if k == "p" {
+			if k == m.keys.Priority {
 				return m.handleTaskCyclePriority()
 			}
-			if k == "u" {
+			if k == m.keys.Due {
 				return m.handleTaskSetDueDate()
 			}
-			if k == "m" {
+			if k == m.keys.Move {
 				return m.handleMoveTask()
 			}
+			if k == m.keys.Timer {
+				return m.handleTaskToggleTimer()
+			}
+			if k == m.keys.Estimate {
+				return m.handleTaskSetEstimate()
+			}
+		}
+		if m.screen == screenTaskDetail {
+			switch k {
+			case m.keys.Note:
+				return m.handleNoteOpen()
+			case m.keys.Open:
+				return m.handleOpenAttachment()
+			case "up", "k":
+				m.attachmentCursor = max(0, m.attachmentCursor-1)
+				return m, nil
+			case "down", "j":
+				m.attachmentCursor = min(max(0, len(m.detailAttachments)-1), m.attachmentCursor+1)
+				return m, nil
+			}
 		}
 		if m.screen == screenWorkspaces {
-			if k == "c" {
+			if k == m.keys.Color {
 				return m.handleWorkspaceColor()
 			}
 			return m.updateWorkspaceNav(k)
 		}
 		if m.screen == screenProjects {
-			if k == "c" {
+			if k == m.keys.Color {
 				return m.handleProjectColor()
 			}
 		}
 	}
 	var cmd tea.Cmd
-	if m.screen != screenWorkspaces {
+	if m.screen == screenProjects || m.screen == screenTasks {
 		m.list, cmd = m.list.Update(msg)
 	}
 	return m, cmd
 }
 
 func (m *model) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.inputMode == inputSearch {
+		return m.updateSearch(msg)
+	}
+	if m.inputMode == inputTaskNote {
+		return m.updateNote(msg)
+	}
+	if m.inputMode == inputPickTemplate {
+		return m.updateTemplatePicker(msg)
+	}
+	if m.inputMode == inputPickProfile {
+		return m.updateProfilePicker(msg)
+	}
+	if m.inputMode == inputProfilePassphrase {
+		return m.updateProfilePassphrase(msg)
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		k := msg.String()
@@ -167,10 +318,8 @@ func (m *model) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if val == "" {
 					return m, nil
 				}
-				m.input.SetValue("")
-				m.inputMode = inputNone
-				next, cmd := m.handleInputSubmit(val, inputNewTask)
-				return next, cmd
+				m.newTaskTitle = val
+				return m.createTaskFromDraft("")
 			case inputNewTaskDue:
 				m.newTaskDue = val
 				m.input.SetValue("")
@@ -180,7 +329,13 @@ func (m *model) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case inputNewTaskPriority:
 				m.newTaskPriority = val
 				m.input.SetValue("")
-				m.input.Placeholder = "Status: todo / in_progress / done (optional)"
+				m.input.Placeholder = "Estimate: e.g. 1h30m or 3pt (optional)"
+				m.inputMode = inputNewTaskEstimate
+				return m, textinput.Blink
+			case inputNewTaskEstimate:
+				m.newTaskEstimate = val
+				m.input.SetValue("")
+				m.input.Placeholder = "Status: " + m.statusChoices() + " (optional)"
 				m.inputMode = inputNewTaskStatus
 				return m, textinput.Blink
 			case inputNewTaskStatus:
@@ -188,7 +343,7 @@ func (m *model) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			// Single-step submit for all other modes
-			if val == "" && mode != inputTaskDueDate && mode != inputWorkspaceColor && mode != inputProjectColor {
+			if val == "" && mode != inputTaskDueDate && mode != inputWorkspaceColor && mode != inputProjectColor && mode != inputTaskEstimate {
 				return m, nil
 			}
 			m.input.SetValue("")
@@ -206,7 +361,7 @@ func (m *model) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.newTaskTitle = val
 				m.input.SetValue("")
-				m.input.Placeholder = "Due date (YYYY-MM-DD, optional)"
+				m.input.Placeholder = "Due date (optional)"
 				m.inputMode = inputNewTaskDue
 				return m, textinput.Blink
 			case inputNewTaskDue:
@@ -218,7 +373,13 @@ func (m *model) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case inputNewTaskPriority:
 				m.newTaskPriority = strings.TrimSpace(m.input.Value())
 				m.input.SetValue("")
-				m.input.Placeholder = "Status: todo / in_progress / done (optional)"
+				m.input.Placeholder = "Estimate: e.g. 1h30m or 3pt (optional)"
+				m.inputMode = inputNewTaskEstimate
+				return m, textinput.Blink
+			case inputNewTaskEstimate:
+				m.newTaskEstimate = strings.TrimSpace(m.input.Value())
+				m.input.SetValue("")
+				m.input.Placeholder = "Status: " + m.statusChoices() + " (optional)"
 				m.inputMode = inputNewTaskStatus
 				return m, textinput.Blink
 			case inputNewTaskStatus:
@@ -227,9 +388,10 @@ func (m *model) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		if k == "esc" || k == "ctrl+c" {
 			m.inputMode = inputNone
+			m.err = ""
 			m.input.SetValue("")
 			m.clearNewTaskDraft()
-			m.input.Placeholder = "Name..."
+			m.clearTemplateDraft()
 			return m, nil
 		}
 	}
@@ -266,11 +428,33 @@ func (m *model) View() string {
 	return s
 }
 
-// Run starts the TUI program.
-func Run(db *sql.DB) error {
+// Run starts the TUI program on db. Store calls still running when it exits are cancelled; so is the whole TUI
+// when ctx is (e.g. on SIGTERM).
+func Run(ctx context.Context, db *sql.DB, opts Options) error {
 	// Force color output so workspace/project colors render in the terminal.
 	lipgloss.SetColorProfile(termenv.TrueColor)
-	p := tea.NewProgram(New(db), tea.WithAltScreen())
-	_, err := p.Run()
+	applyTheme(opts.Theme)
+	if opts.DateLayout != "" {
+		dateLayout = opts.DateLayout
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p := tea.NewProgram(New(ctx, db, opts), tea.WithAltScreen(), tea.WithContext(ctx))
+	final, err := p.Run()
+	cancel()
+	if fm, ok := final.(*model); ok {
+		if fm.watcher != nil {
+			fm.watcher.Close()
+		}
+		// A profile switch replaced the database the caller opened (and closed it).
+		if fm.db != db {
+			if cerr := store.Close(fm.db); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	if errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil {
+		return ctx.Err()
+	}
 	return err
 }
@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/cli-todo/internal/store"
+)
+
+// openCommand returns the command that opens a URL or file: $TODO_OPEN if set
+// (e.g. "firefox" or "code -r"), otherwise the platform's default opener.
+func openCommand() []string {
+	if c := strings.Fields(os.Getenv("TODO_OPEN")); len(c) > 0 {
+		return c
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler"}
+	}
+	return []string{"xdg-open"}
+}
+
+// handleOpenAttachment hands the selected attachment of the detail task to the open command.
+func (m *model) handleOpenAttachment() (tea.Model, tea.Cmd) {
+	if m.attachmentCursor >= len(m.detailAttachments) {
+		return m, nil
+	}
+	a := m.detailAttachments[m.attachmentCursor]
+	loc, cleanup, err := store.AttachmentLocation(m.ctx, m.db, a)
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	argv := append(openCommand(), loc)
+	c := exec.Command(argv[0], argv[1:]...)
+	if err := c.Start(); err != nil {
+		cleanup()
+		m.err = err.Error()
+		return m, nil
+	}
+	go func() {
+		c.Wait()
+		cleanup()
+	}()
+	m.err = ""
+	m.statusMsg = "Opened " + a.Name
+	return m, nil
+}
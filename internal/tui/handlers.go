@@ -1,26 +1,89 @@
 package tui
 
 import (
+	"errors"
 	"strings"
 	"time"
 
-	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbletea"
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/quickadd"
 	"github.com/cli-todo/internal/store"
 )
 
 // refreshMsg forces the list to refresh on the next update.
 type refreshMsg struct{}
 
+// tickMsg drives the once-per-second refresh of the running timer in the footer and the check for
+// changes made by other processes.
+type tickMsg time.Time
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+// refreshTimer reloads the running timer, which may have been started or stopped from the CLI.
+func (m *model) refreshTimer() {
+	e, err := store.RunningTimer(m.ctx, m.db)
+	if err != nil {
+		m.err = err.Error()
+		return
+	}
+	m.runningTimer = e
+	m.runningTaskTitle = ""
+	if e != nil {
+		if t, err := store.GetTask(m.ctx, m.db, e.TaskID); err == nil {
+			m.runningTaskTitle = t.Title
+		}
+	}
+}
+
+// checkExternalChanges reloads the current screen when the database changed outside the TUI, e.g. a
+// script added tasks. While a prompt or overlay is open the reload waits until it is closed.
+// A directory store or an encrypted database is saved when it changed and loaded again when its files
+// did, so neither the TUI nor other todo processes work on an old copy.
+func (m *model) checkExternalChanges() tea.Cmd {
+	if m.watcher == nil {
+		return nil
+	}
+	if changed, err := m.watcher.Changed(m.ctx); err == nil && changed {
+		m.stale = true
+		if err := store.Save(m.ctx, m.db); err != nil {
+			m.err = err.Error()
+		}
+	}
+	if reloaded, err := store.Reload(m.ctx, m.db); err != nil {
+		m.err = err.Error()
+	} else if reloaded {
+		m.stale = true
+		m.watcher.Changed(m.ctx) // the reload itself
+	}
+	if !m.stale || m.inputMode != inputNone {
+		return nil
+	}
+	m.stale = false
+	return m.refreshList()
+}
+
 func (m *model) refreshList() tea.Cmd {
 	switch m.screen {
 	case screenWorkspaces:
-		ws, err := store.ListWorkspaces(m.db)
+		ws, err := store.ListWorkspaces(m.ctx, m.db)
 		if err != nil {
 			m.err = err.Error()
 			return nil
 		}
+		// Keep the cursor on the same workspace when others were added or removed.
+		if m.workspaceCursor < len(m.workspaces) {
+			id := m.workspaces[m.workspaceCursor].ID
+			for i := range ws {
+				if ws[i].ID == id {
+					m.workspaceCursor = i
+				}
+			}
+		}
 		m.workspaces = ws
 		m.err = ""
 		if m.workspaceCursor >= len(m.workspaces) {
@@ -31,17 +94,33 @@ func (m *model) refreshList() tea.Cmd {
 		if m.selectedWorkspace == nil {
 			return nil
 		}
-		projs, err := store.ListProjects(m.db, m.selectedWorkspace.ID)
+		projs, err := store.ListProjects(m.ctx, m.db, m.selectedWorkspace.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		totals, err := store.EstimateTotals(m.ctx, m.db, m.selectedWorkspace.ID)
 		if err != nil {
 			m.err = err.Error()
 			return nil
 		}
+		capacity := func(projectID *int64) string {
+			for _, t := range totals {
+				if (t.ProjectID == nil && projectID == nil) || (t.ProjectID != nil && projectID != nil && *t.ProjectID == *projectID) {
+					if t.Minutes == 0 && t.Points == 0 {
+						return ""
+					}
+					return store.FormatCapacity(t.Minutes, t.Points, t.LoggedSeconds)
+				}
+			}
+			return ""
+		}
 		m.projects = projs
 		m.err = ""
 		items := make([]list.Item, 0, len(projs)+1)
-		items = append(items, projectItem{Name: "Default", IsDefault: true})
+		items = append(items, projectItem{Name: "Default", IsDefault: true, Capacity: capacity(nil)})
 		for i := range projs {
-			items = append(items, projectItem{ID: &projs[i].ID, Name: projs[i].Name, Color: projs[i].Color})
+			items = append(items, projectItem{ID: &projs[i].ID, Name: projs[i].Name, Color: projs[i].Color, Capacity: capacity(&projs[i].ID)})
 		}
 		m.setBubblesList(" "+m.selectedWorkspace.Name+" → Lists ", items)
 		return nil
@@ -49,16 +128,56 @@ func (m *model) refreshList() tea.Cmd {
 		if m.selectedWorkspace == nil {
 			return nil
 		}
-		tasks, err := store.ListTasks(m.db, m.selectedWorkspace.ID, m.selectedProjectID)
+		tasks, err := store.ListTasks(m.ctx, m.db, m.selectedWorkspace.ID, m.selectedProjectID)
 		if err != nil {
 			m.err = err.Error()
 			return nil
 		}
+		statuses, err := store.ListStatuses(m.ctx, m.db, m.selectedWorkspace.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		logged, err := store.TaskTimeTotals(m.ctx, m.db, m.selectedWorkspace.ID, m.selectedProjectID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		projs, err := store.ListProjects(m.ctx, m.db, m.selectedWorkspace.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		m.projects = projs // for quick-add #list and the detail screen
 		m.tasks = tasks
+		m.statuses = statuses
 		m.err = ""
 		items := make([]list.Item, len(tasks))
+		var minutes, loggedSeconds int64
+		var points float64
 		for i := range tasks {
-			items[i] = taskItem{Task: tasks[i]}
+			item := taskItem{Task: tasks[i]}
+			for j, st := range statuses {
+				if st.Name == tasks[i].Status {
+					item.StatusInfo = st
+					item.Initial = j == 0
+					break
+				}
+			}
+			items[i] = item
+			if item.StatusInfo.IsDone {
+				continue
+			}
+			if t := tasks[i]; t.EstimateMinutes != nil {
+				minutes += *t.EstimateMinutes
+				loggedSeconds += logged[t.ID]
+			} else if t.EstimatePoints != nil {
+				points += *t.EstimatePoints
+			}
+		}
+		m.viewCapacity = ""
+		if minutes > 0 || points > 0 {
+			m.viewCapacity = store.FormatCapacity(minutes, points, loggedSeconds)
 		}
 		title := " Default "
 		if m.selectedProjectID != nil {
@@ -71,6 +190,60 @@ func (m *model) refreshList() tea.Cmd {
 		}
 		m.setBubblesList(m.selectedWorkspace.Name+" →"+title+" Tasks ", items)
 		return nil
+	case screenTaskDetail:
+		t, err := store.GetTask(m.ctx, m.db, m.detailTask.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			m.screen = screenTasks
+			m.statusMsg = "Task " + m.detailTask.Title + " was deleted"
+			return m.refreshList()
+		}
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		blockers, err := store.ListBlockers(m.ctx, m.db, t.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		blocks, err := store.ListDependents(m.ctx, m.db, t.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		entries, err := store.ListTimeEntries(m.ctx, m.db, t.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		notes, err := store.ListNotes(m.ctx, m.db, t.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		attachments, err := store.ListAttachments(m.ctx, m.db, t.ID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		fields, err := store.ListFields(m.ctx, m.db, t.WorkspaceID)
+		if err != nil {
+			m.err = err.Error()
+			return nil
+		}
+		m.detailFields = fields
+		m.detailAttachments = attachments
+		m.attachmentCursor = min(m.attachmentCursor, max(0, len(attachments)-1))
+		m.detailTask = t
+		m.detailNotes = notes
+		m.detailBlockers = blockers
+		m.detailBlocks = blocks
+		m.detailLogged = 0
+		for _, e := range entries {
+			m.detailLogged += e.Seconds
+		}
+		m.err = ""
+		return nil
 	}
 	return nil
 }
@@ -78,7 +251,17 @@ func (m *model) refreshList() tea.Cmd {
 // setBubblesList creates or updates the list component with the given title and items.
 func (m *model) setBubblesList(title string, items []list.Item) {
 	if m.list.Title == title {
+		// Keep the selection on the same item, which may have moved (e.g. after changes by another process).
+		key, ok := itemKey(m.list.SelectedItem())
 		m.list.SetItems(items)
+		if ok && m.list.FilterState() == list.Unfiltered {
+			for i, it := range items {
+				if k, _ := itemKey(it); k == key {
+					m.list.Select(i)
+					break
+				}
+			}
+		}
 		return
 	}
 	delegate := list.NewDefaultDelegate()
@@ -103,6 +286,8 @@ func (m *model) handleBack() (tea.Model, tea.Cmd) {
 	case screenTasks:
 		m.screen = screenProjects
 		m.selectedProjectID = nil
+	case screenTaskDetail:
+		m.screen = screenTasks
 	default:
 		return m, nil
 	}
@@ -114,7 +299,7 @@ func (m *model) handleAdd() (tea.Model, tea.Cmd) {
 	case screenWorkspaces:
 		m.inputMode = inputNewWorkspace
 	case screenProjects:
-		m.inputMode = inputNewProject
+		return m.handleTemplatePickOpen()
 	case screenTasks:
 		m.inputMode = inputNewTask
 		m.clearNewTaskDraft()
@@ -131,63 +316,112 @@ func (m *model) clearNewTaskDraft() {
 	m.newTaskTitle = ""
 	m.newTaskDue = ""
 	m.newTaskPriority = ""
+	m.newTaskEstimate = ""
 	m.newTaskStatus = ""
 }
 
-func normalizePriority(s string) string {
-	switch s {
-	case "low", "medium", "high":
-		return s
-	case "l":
-		return "low"
-	case "m":
-		return "medium"
-	case "h":
-		return "high"
-	}
-	return ""
+// normalizeStatus maps input to a status of the workspace workflow: exact name, or a unique
+// prefix (e.g. "i" for in_progress). Anything else is returned unchanged, so the store rejects it
+// with the workflow's names; empty input gets the first status.
+func (m *model) normalizeStatus(s string) string {
+	var match string
+	for _, st := range m.statuses {
+		if st.Name == s {
+			return s
+		}
+		if s != "" && strings.HasPrefix(st.Name, s) {
+			if match != "" {
+				return s
+			}
+			match = st.Name
+		}
+	}
+	if match != "" {
+		return match
+	}
+	return s
 }
 
-func normalizeStatus(s string) string {
-	switch s {
-	case "todo", "in_progress", "done":
-		return s
-	case "t":
-		return "todo"
-	case "i":
-		return "in_progress"
-	case "d":
-		return "done"
-	}
-	return "todo"
+// statusChoices lists the workflow of the selected workspace for input prompts.
+func (m *model) statusChoices() string {
+	names := make([]string, len(m.statuses))
+	for i, st := range m.statuses {
+		names[i] = st.Name
+	}
+	return strings.Join(names, " / ")
 }
 
-// createTaskFromDraft creates a task from the new-task draft (title + optional due, priority, status).
+// createTaskFromDraft creates the task from the new task prompt. The title may carry quick-add
+// markup (#list @tag !priority ^due ~estimate); fields filled in the form win over markup.
 func (m *model) createTaskFromDraft(statusInput string) (tea.Model, tea.Cmd) {
-	status := strings.TrimSpace(statusInput)
-	if status == "" {
-		status = "todo"
+	qa, err := quickadd.Parse(m.newTaskTitle, time.Now())
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
 	}
-	status = normalizeStatus(status)
-	priority := normalizePriority(m.newTaskPriority)
-	var due *time.Time
-	if m.newTaskDue != "" {
-		for _, layout := range []string{"2006-01-02", "2006/01/02"} {
-			if t, err := time.Parse(layout, m.newTaskDue); err == nil {
-				due = &t
-				break
-			}
+	projectID, listName := m.selectedProjectID, ""
+	if qa.Project != "" {
+		p, ok := quickadd.MatchProject(qa.Project, m.projects)
+		if !ok {
+			m.err = "No list matches #" + qa.Project
+			return m, nil
 		}
-		if due == nil {
-			m.err = "Invalid date (use YYYY-MM-DD)"
+		projectID, listName = &p.ID, p.Name
+	}
+	status := m.normalizeStatus(strings.TrimSpace(statusInput))
+	priority := qa.Priority
+	if m.newTaskPriority != "" {
+		if priority, err = store.ParsePriority(m.newTaskPriority); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+	}
+	due := qa.Due
+	if m.newTaskDue != "" {
+		if due, err = parseDueInput(m.newTaskDue); err != nil {
+			m.err = "Invalid date (use " + dateHint() + ", today, tomorrow, a weekday or +3d)"
 			return m, nil
 		}
 	}
-	if _, err := store.CreateTask(m.db, m.selectedWorkspace.ID, m.selectedProjectID, m.newTaskTitle, "", status, priority, due); err != nil {
+	estimate := qa.Estimate
+	if m.newTaskEstimate != "" {
+		estimate = m.newTaskEstimate
+	}
+	estMinutes, estPoints, err := store.ParseEstimate(estimate)
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	tags := qa.Tags
+	if m.opts.Workspace == "" || m.opts.Workspace == m.selectedWorkspace.Name {
+		tags = append(tags, m.opts.Tags...)
+	}
+	// The task, its tags and estimate are saved together or not at all.
+	err = store.WithTx(m.ctx, m.db, func(tx store.DBTX) error {
+		t, err := store.CreateTask(m.ctx, tx, m.selectedWorkspace.ID, projectID, qa.Title, "", status, priority, due)
+		if err != nil {
+			return err
+		}
+		if len(tags) > 0 {
+			if _, err := store.AddTaskTags(m.ctx, tx, t.ID, tags); err != nil {
+				return err
+			}
+		}
+		if estMinutes != nil || estPoints != nil {
+			if _, err := store.SetTaskEstimate(m.ctx, tx, t.ID, estMinutes, estPoints); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		m.err = err.Error()
 		return m, nil
 	}
 	m.err = ""
+	if listName != "" && !sameProject(projectID, m.selectedProjectID) {
+		m.statusMsg = "Added to " + listName
+	}
 	m.clearNewTaskDraft()
 	m.input.SetValue("")
 	m.inputMode = inputNone
@@ -195,6 +429,10 @@ func (m *model) createTaskFromDraft(statusInput string) (tea.Model, tea.Cmd) {
 	return m, m.refreshList()
 }
 
+func sameProject(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
 func (m *model) handleEdit() (tea.Model, tea.Cmd) {
 	switch m.screen {
 	case screenWorkspaces:
@@ -286,26 +524,25 @@ func (m *model) getSelectedTask() (taskItem, bool) {
 	return t, ok
 }
 
-var statusOrder = []string{"todo", "in_progress", "done"}
-
+// handleTaskCycleStatus moves the selected task to the next status of the workspace workflow.
 func (m *model) handleTaskCycleStatus() (tea.Model, tea.Cmd) {
 	t, ok := m.getSelectedTask()
-	if !ok {
+	if !ok || len(m.statuses) == 0 {
 		return m, nil
 	}
-	next := t.Task.Status
-	for i, s := range statusOrder {
-		if s == t.Task.Status {
-			next = statusOrder[(i+1)%len(statusOrder)]
+	next := m.statuses[0].Name
+	for i, st := range m.statuses {
+		if st.Name == t.Task.Status {
+			next = m.statuses[(i+1)%len(m.statuses)].Name
 			break
 		}
 	}
-	task, err := store.GetTask(m.db, t.ID)
+	task, err := store.GetTask(m.ctx, m.db, t.ID)
 	if err != nil {
 		m.err = err.Error()
 		return m, nil
 	}
-	if _, err := store.UpdateTask(m.db, t.ID, task.Title, task.Description, next, task.Priority, task.DueDate); err != nil {
+	if _, err := store.UpdateTask(m.ctx, m.db, t.ID, task.Title, task.Description, next, task.Priority, task.DueDate); err != nil {
 		m.err = err.Error()
 		return m, nil
 	}
@@ -314,39 +551,77 @@ func (m *model) handleTaskCycleStatus() (tea.Model, tea.Cmd) {
 	return m, m.refreshList()
 }
 
-var priorityOrder = []string{"", "low", "medium", "high"}
+var priorityOrder = append([]models.Priority{models.PriorityNone}, models.Priorities...)
 
 func (m *model) handleTaskCyclePriority() (tea.Model, tea.Cmd) {
 	t, ok := m.getSelectedTask()
 	if !ok {
 		return m, nil
 	}
-	task, err := store.GetTask(m.db, t.ID)
+	task, err := store.GetTask(m.ctx, m.db, t.ID)
 	if err != nil {
 		m.err = err.Error()
 		return m, nil
 	}
 	cur := task.Priority
-	next := ""
+	next := models.PriorityNone
 	for i, p := range priorityOrder {
 		if p == cur {
 			next = priorityOrder[(i+1)%len(priorityOrder)]
 			break
 		}
 	}
-	if _, err := store.UpdateTask(m.db, t.ID, task.Title, task.Description, task.Status, next, task.DueDate); err != nil {
+	if _, err := store.UpdateTask(m.ctx, m.db, t.ID, task.Title, task.Description, task.Status, next, task.DueDate); err != nil {
 		m.err = err.Error()
 		return m, nil
 	}
 	m.err = ""
-	if next == "" {
+	if next == models.PriorityNone {
 		m.statusMsg = "Priority cleared"
 	} else {
-		m.statusMsg = "Priority: " + next
+		m.statusMsg = "Priority: " + string(next)
+	}
+	return m, m.refreshList()
+}
+
+// handleTaskToggleTimer starts a timer on the selected task, or stops it if it is the one running.
+func (m *model) handleTaskToggleTimer() (tea.Model, tea.Cmd) {
+	t, ok := m.getSelectedTask()
+	if !ok {
+		return m, nil
+	}
+	if m.runningTimer != nil && m.runningTimer.TaskID == t.ID {
+		e, err := store.StopTimer(m.ctx, m.db)
+		if err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		m.err = ""
+		m.statusMsg = "Timer stopped: " + formatElapsed(e.Seconds) + " logged"
+	} else {
+		if _, err := store.StartTimer(m.ctx, m.db, t.ID); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		m.err = ""
+		m.statusMsg = "Timer started"
 	}
+	m.refreshTimer()
 	return m, m.refreshList()
 }
 
+func (m *model) handleTaskSetEstimate() (tea.Model, tea.Cmd) {
+	t, ok := m.getSelectedTask()
+	if !ok {
+		return m, nil
+	}
+	m.editTaskID = t.ID
+	m.inputMode = inputTaskEstimate
+	m.input.SetValue(store.FormatEstimate(t.Task))
+	m.input.Focus()
+	return m, textinput.Blink
+}
+
 func (m *model) handleTaskSetDueDate() (tea.Model, tea.Cmd) {
 	t, ok := m.getSelectedTask()
 	if !ok {
@@ -355,7 +630,7 @@ func (m *model) handleTaskSetDueDate() (tea.Model, tea.Cmd) {
 	m.editTaskID = t.ID
 	m.inputMode = inputTaskDueDate
 	if t.Task.DueDate != nil {
-		m.input.SetValue(t.Task.DueDate.Format("2006-01-02"))
+		m.input.SetValue(t.Task.DueDate.Format(dateLayout))
 	} else {
 		m.input.SetValue("")
 	}
@@ -366,7 +641,7 @@ func (m *model) handleTaskSetDueDate() (tea.Model, tea.Cmd) {
 func (m *model) handleInputSubmit(val string, mode inputKind) (*model, tea.Cmd) {
 	switch mode {
 	case inputNewWorkspace:
-		if _, err := store.CreateWorkspace(m.db, val); err != nil {
+		if _, err := store.CreateWorkspace(m.ctx, m.db, val); err != nil {
 			m.err = err.Error()
 			m.statusMsg = ""
 			return m, nil
@@ -379,27 +654,30 @@ func (m *model) handleInputSubmit(val string, mode inputKind) (*model, tea.Cmd)
 		if m.selectedWorkspace == nil {
 			return m, nil
 		}
-		if _, err := store.CreateProject(m.db, m.selectedWorkspace.ID, val); err != nil {
+		if m.pendingTemplate != nil {
+			m.newProjectName = val
+			m.templateVarQueue = store.TemplateVars(*m.pendingTemplate)
+			m.templateVarValues = map[string]string{}
+			return m.submitTemplateProject()
+		}
+		if _, err := store.CreateProject(m.ctx, m.db, m.selectedWorkspace.ID, val); err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
 		m.err = ""
 		return m, m.refreshList()
-	case inputNewTask:
-		if m.selectedWorkspace == nil {
-			return m, nil
-		}
-		if _, err := store.CreateTask(m.db, m.selectedWorkspace.ID, m.selectedProjectID, val, "", "todo", "", nil); err != nil {
-			m.err = err.Error()
+	case inputTemplateVar:
+		if m.pendingTemplate == nil || len(m.templateVarQueue) == 0 {
 			return m, nil
 		}
-		m.err = ""
-		return m, m.refreshList()
+		m.templateVarValues[m.templateVarQueue[0]] = val
+		m.templateVarQueue = m.templateVarQueue[1:]
+		return m.submitTemplateProject()
 	case inputEditWorkspace:
 		if m.editWorkspaceID == 0 {
 			return m, nil
 		}
-		if _, err := store.UpdateWorkspace(m.db, m.editWorkspaceID, val); err != nil {
+		if _, err := store.UpdateWorkspace(m.ctx, m.db, m.editWorkspaceID, val); err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
@@ -412,7 +690,7 @@ func (m *model) handleInputSubmit(val string, mode inputKind) (*model, tea.Cmd)
 		if m.editProjectID == 0 {
 			return m, nil
 		}
-		if _, err := store.UpdateProject(m.db, m.editProjectID, val); err != nil {
+		if _, err := store.UpdateProject(m.ctx, m.db, m.editProjectID, val); err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
@@ -424,12 +702,12 @@ func (m *model) handleInputSubmit(val string, mode inputKind) (*model, tea.Cmd)
 		if m.editTaskID == 0 {
 			return m, nil
 		}
-		task, err := store.GetTask(m.db, m.editTaskID)
+		task, err := store.GetTask(m.ctx, m.db, m.editTaskID)
 		if err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
-		if _, err := store.UpdateTask(m.db, m.editTaskID, val, task.Description, task.Status, task.Priority, task.DueDate); err != nil {
+		if _, err := store.UpdateTask(m.ctx, m.db, m.editTaskID, val, task.Description, task.Status, task.Priority, task.DueDate); err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
@@ -441,26 +719,20 @@ func (m *model) handleInputSubmit(val string, mode inputKind) (*model, tea.Cmd)
 		if m.editTaskID == 0 {
 			return m, nil
 		}
-		task, err := store.GetTask(m.db, m.editTaskID)
+		task, err := store.GetTask(m.ctx, m.db, m.editTaskID)
 		if err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
 		var due *time.Time
 		if val != "" {
-			for _, layout := range []string{"2006-01-02", "2006/01/02"} {
-				if t, err := time.Parse(layout, val); err == nil {
-					due = &t
-					break
-				}
-			}
-			if due == nil {
-				m.err = "Invalid date (use YYYY-MM-DD)"
+			if due, err = parseDueInput(val); err != nil {
+				m.err = "Invalid date (use " + dateHint() + ", today, tomorrow, a weekday or +3d)"
 				m.editTaskID = 0
 				return m, nil
 			}
 		}
-		if _, err := store.UpdateTask(m.db, m.editTaskID, task.Title, task.Description, task.Status, task.Priority, due); err != nil {
+		if _, err := store.UpdateTask(m.ctx, m.db, m.editTaskID, task.Title, task.Description, task.Status, task.Priority, due); err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
@@ -469,14 +741,36 @@ func (m *model) handleInputSubmit(val string, mode inputKind) (*model, tea.Cmd)
 		if due == nil {
 			m.statusMsg = "Due date cleared"
 		} else {
-			m.statusMsg = "Due: " + due.Format("2006-01-02")
+			m.statusMsg = "Due: " + due.Format(dateLayout)
+		}
+		return m, m.refreshList()
+	case inputTaskEstimate:
+		if m.editTaskID == 0 {
+			return m, nil
+		}
+		minutes, points, err := store.ParseEstimate(val)
+		if err != nil {
+			m.err = err.Error()
+			m.editTaskID = 0
+			return m, nil
+		}
+		if _, err := store.SetTaskEstimate(m.ctx, m.db, m.editTaskID, minutes, points); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		m.err = ""
+		m.editTaskID = 0
+		if val == "" {
+			m.statusMsg = "Estimate cleared"
+		} else {
+			m.statusMsg = "Estimate: " + val
 		}
 		return m, m.refreshList()
 	case inputWorkspaceColor:
 		if m.editWorkspaceID == 0 {
 			return m, nil
 		}
-		if _, err := store.SetWorkspaceColor(m.db, m.editWorkspaceID, val); err != nil {
+		if _, err := store.SetWorkspaceColor(m.ctx, m.db, m.editWorkspaceID, val); err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
@@ -489,7 +783,7 @@ func (m *model) handleInputSubmit(val string, mode inputKind) (*model, tea.Cmd)
 		if m.editProjectID == 0 {
 			return m, nil
 		}
-		if _, err := store.SetProjectColor(m.db, m.editProjectID, val); err != nil {
+		if _, err := store.SetProjectColor(m.ctx, m.db, m.editProjectID, val); err != nil {
 			m.err = err.Error()
 			return m, nil
 		}
@@ -508,7 +802,11 @@ func (m *model) handleDelete() (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		w := m.workspaces[m.workspaceCursor]
-		_ = store.DeleteWorkspace(m.db, w.ID)
+		if err := store.SnapshotBefore(m.ctx, m.db, "workspace-delete"); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		_ = store.DeleteWorkspace(m.ctx, m.db, w.ID)
 		if m.workspaceCursor >= len(m.workspaces)-1 {
 			m.workspaceCursor = max(0, len(m.workspaces)-2)
 		}
@@ -522,7 +820,7 @@ func (m *model) handleDelete() (tea.Model, tea.Cmd) {
 		if !ok || p.IsDefault || p.ID == nil {
 			return m, nil
 		}
-		_ = store.DeleteProject(m.db, *p.ID)
+		_ = store.DeleteProject(m.ctx, m.db, *p.ID)
 		return m, m.refreshList()
 	case screenTasks:
 		sel := m.list.SelectedItem()
@@ -533,7 +831,7 @@ func (m *model) handleDelete() (tea.Model, tea.Cmd) {
 		if !ok {
 			return m, nil
 		}
-		_ = store.DeleteTask(m.db, t.ID)
+		_ = store.DeleteTask(m.ctx, m.db, t.ID)
 		return m, m.refreshList()
 	}
 	return m, nil
@@ -558,7 +856,7 @@ func (m *model) handleSelect() (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		if m.moveTaskID != 0 {
-			_, err := store.SetTaskProject(m.db, m.moveTaskID, p.ID)
+			_, err := store.SetTaskProject(m.ctx, m.db, m.moveTaskID, p.ID)
 			if err != nil {
 				m.err = err.Error()
 				return m, nil
@@ -574,8 +872,14 @@ func (m *model) handleSelect() (tea.Model, tea.Cmd) {
 		m.screen = screenTasks
 		return m, m.refreshList()
 	case screenTasks:
-		// e = edit task (cycle status) could go here
-		return m, nil
+		t, ok := m.getSelectedTask()
+		if !ok {
+			return m, nil
+		}
+		m.detailTask = t.Task
+		m.attachmentCursor = 0
+		m.screen = screenTaskDetail
+		return m, m.refreshList()
 	}
 	return m, nil
 }
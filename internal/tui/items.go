@@ -1,6 +1,14 @@
 package tui
 
-import "github.com/cli-todo/internal/models"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+)
 
 // list.Item + Title/Description for bubbles list
 type workspaceItem struct {
@@ -8,46 +16,79 @@ type workspaceItem struct {
 }
 
 func (w workspaceItem) Title() string       { return w.Name }
-func (w workspaceItem) Description() string  { return "" }
-func (w workspaceItem) FilterValue() string   { return w.Name }
+func (w workspaceItem) Description() string { return "" }
+func (w workspaceItem) FilterValue() string { return w.Name }
 
 type projectItem struct {
-	ID          *int64
-	Name        string
-	IsDefault   bool
-	Color       string
+	ID        *int64
+	Name      string
+	IsDefault bool
+	Color     string
+	Capacity  string // remaining estimates of open tasks ("" if none)
 }
 
 func (p projectItem) Title() string       { return p.Name }
 func (p projectItem) Description() string { return "" }
 func (p projectItem) FilterValue() string { return p.Name }
 
+// itemKey identifies a project or task item across reloads.
+func itemKey(it list.Item) (string, bool) {
+	switch it := it.(type) {
+	case projectItem:
+		if it.ID == nil {
+			return "project:default", true
+		}
+		return fmt.Sprintf("project:%d", *it.ID), true
+	case taskItem:
+		return fmt.Sprintf("task:%d", it.ID), true
+	}
+	return "", false
+}
+
 type taskItem struct {
 	models.Task
+	// StatusInfo is the task's status from the workspace workflow (zero value if unknown).
+	StatusInfo models.Status
+	// Initial is true when the task is in the first status of the workflow.
+	Initial bool
 }
 
 func (t taskItem) Title() string {
 	var statusSym string
-	switch t.Task.Status {
-	case "todo":
-		statusSym = "[]"
-	case "in_progress":
-		statusSym = "[in_progress]"
-	case "done":
+	switch {
+	case t.StatusInfo.IsDone:
 		statusSym = "[x]"
+	case t.Initial:
+		statusSym = "[]"
 	default:
 		statusSym = "[" + t.Task.Status + "]"
 	}
+	if c := lipglossColor(t.StatusInfo.Color); c != "" {
+		statusSym = lipgloss.NewStyle().Foreground(lipgloss.Color(c)).Render(statusSym)
+	}
 	s := statusSym
+	if t.Task.Blocked {
+		s = "⊘ " + s
+	}
 	if t.Task.Priority != "" {
-		s += " " + t.Task.Priority + " "
+		s += " " + string(t.Task.Priority) + " "
 	}
 	return s + " " + t.Task.Title
 }
 func (t taskItem) Description() string {
+	var parts []string
 	if t.Task.DueDate != nil {
-		return "due: " + t.Task.DueDate.Format("2006-01-02")
+		parts = append(parts, "due: "+t.Task.DueDate.Format(dateLayout))
+	}
+	if est := store.FormatEstimate(t.Task); est != "" {
+		parts = append(parts, "~"+est)
+	}
+	for _, tag := range t.Task.Tags {
+		parts = append(parts, "@"+tag)
+	}
+	if len(parts) == 0 {
+		return t.Task.Description
 	}
-	return t.Task.Description
+	return strings.Join(parts, "  ")
 }
 func (t taskItem) FilterValue() string { return t.Task.Title }
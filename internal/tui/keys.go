@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cli-todo/internal/config"
+	"github.com/cli-todo/internal/quickadd"
+)
+
+// keyMap holds the keys of the rebindable actions. Navigation (arrows, j/k, Enter, Esc, ←) is fixed.
+type keyMap struct {
+	Quit, Add, Edit, Delete, Search, Color, Status, Priority, Due, Move, Timer, Estimate, Note, Open, Profile string
+}
+
+// newKeyMap applies bindings (action -> key) over the defaults in config.KeyBindings.
+func newKeyMap(bindings map[string]string) keyMap {
+	var k keyMap
+	fields := map[string]*string{
+		"quit": &k.Quit, "add": &k.Add, "edit": &k.Edit, "delete": &k.Delete, "search": &k.Search,
+		"color": &k.Color, "status": &k.Status, "priority": &k.Priority, "due": &k.Due, "move": &k.Move,
+		"timer": &k.Timer, "estimate": &k.Estimate, "note": &k.Note, "open": &k.Open, "profile": &k.Profile,
+	}
+	for action, field := range fields {
+		*field = config.KeyBindings[action]
+		if key := bindings[action]; key != "" {
+			*field = key
+		}
+	}
+	return k
+}
+
+// dateLayout is how dates are shown and typed (config date_format).
+var dateLayout = "2006-01-02"
+
+// dateHint spells dateLayout the way date_format is written, e.g. DD.MM.YYYY.
+func dateHint() string {
+	return strings.NewReplacer("2006", "YYYY", "01", "MM", "02", "DD").Replace(dateLayout)
+}
+
+// parseDueInput reads a typed due date: dateLayout, YYYY/MM/DD, or anything quickadd.ParseDue understands.
+func parseDueInput(s string) (*time.Time, error) {
+	for _, layout := range []string{dateLayout, "2006/01/02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+	return quickadd.ParseDue(s, time.Now())
+}
@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbletea"
+	"github.com/cli-todo/internal/store"
+)
+
+// handleNoteOpen opens the note textarea for the detail task.
+func (m *model) handleNoteOpen() (tea.Model, tea.Cmd) {
+	m.inputMode = inputTaskNote
+	m.noteArea.Reset()
+	m.noteArea.SetWidth(max(20, min(m.width-4, 80)))
+	return m, tea.Batch(m.noteArea.Focus(), textarea.Blink)
+}
+
+// updateNote handles keys while writing a note; Enter adds a line, ctrl+s saves.
+func (m *model) updateNote(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		switch k.String() {
+		case "esc", "ctrl+c":
+			m.closeNote()
+			return m, nil
+		case "ctrl+s":
+			body := strings.TrimSpace(m.noteArea.Value())
+			if body == "" {
+				return m, nil
+			}
+			m.closeNote()
+			if _, err := store.AddNote(m.ctx, m.db, m.detailTask.ID, body); err != nil {
+				m.err = err.Error()
+				return m, nil
+			}
+			m.statusMsg = "Note added"
+			return m, m.refreshList()
+		}
+	}
+	var cmd tea.Cmd
+	m.noteArea, cmd = m.noteArea.Update(msg)
+	return m, cmd
+}
+
+func (m *model) closeNote() {
+	m.inputMode = inputNone
+	m.noteArea.Blur()
+	m.noteArea.Reset()
+}
+
+func (m *model) viewNote() string {
+	s := titleStyle.Render("Todo") + "\n\n" + "Note on " + m.detailTask.Title + ":\n\n"
+	s += m.noteArea.View() + "\n\n"
+	return s + helpStyle.Render("ctrl+s save • Enter new line • Esc cancel")
+}
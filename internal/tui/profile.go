@@ -0,0 +1,140 @@
+package tui
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbletea"
+	"github.com/cli-todo/internal/store"
+)
+
+// handleProfileOpen shows the profile switcher.
+func (m *model) handleProfileOpen() (tea.Model, tea.Cmd) {
+	if len(m.opts.Profiles) == 0 {
+		m.statusMsg = "No profiles (add one with: todo profile add NAME PATH)"
+		return m, nil
+	}
+	m.profileNames = m.profileNames[:0]
+	for name := range m.opts.Profiles {
+		m.profileNames = append(m.profileNames, name)
+	}
+	sort.Strings(m.profileNames)
+	m.profileCursor = 0
+	for i, name := range m.profileNames {
+		if name == m.opts.Profile {
+			m.profileCursor = i
+		}
+	}
+	m.inputMode = inputPickProfile
+	return m, nil
+}
+
+// updateProfilePicker handles keys in the profile switcher.
+func (m *model) updateProfilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch k.String() {
+	case "esc", "ctrl+c":
+		m.inputMode = inputNone
+	case "up", "k":
+		if m.profileCursor > 0 {
+			m.profileCursor--
+		}
+	case "down", "j":
+		if m.profileCursor < len(m.profileNames)-1 {
+			m.profileCursor++
+		}
+	case "enter":
+		return m.switchProfile(m.profileNames[m.profileCursor])
+	}
+	return m, nil
+}
+
+// errAskPassphrase stops opening an encrypted database, so the passphrase is asked for in the TUI.
+var errAskPassphrase = errors.New("passphrase needed")
+
+// switchProfile opens the profile's database and starts over on its workspace list with fresh state.
+// A pinned workspace (and its tags) belongs to the previous database and is dropped. An encrypted
+// database that TODO_KEY or TODO_PASSPHRASE don't unlock asks for its passphrase first.
+func (m *model) switchProfile(name string) (tea.Model, tea.Cmd) {
+	m.inputMode = inputNone
+	if name == m.opts.Profile {
+		return m, nil
+	}
+	db, err := store.OpenWith(m.ctx, m.opts.Profiles[name], func(string) (string, error) { return "", errAskPassphrase })
+	if errors.Is(err, errAskPassphrase) {
+		m.profilePending = name
+		m.input.SetValue("")
+		m.input.Placeholder = ""
+		m.input.EchoMode = textinput.EchoPassword
+		m.input.Focus()
+		m.inputMode = inputProfilePassphrase
+		return m, textinput.Blink
+	}
+	return m.openProfile(name, db, err)
+}
+
+// updateProfilePassphrase handles keys while asking for the passphrase of an encrypted profile.
+func (m *model) updateProfilePassphrase(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		switch k.String() {
+		case "enter", "esc", "ctrl+c":
+			passphrase := m.input.Value()
+			m.input.SetValue("")
+			m.input.EchoMode = textinput.EchoNormal
+			m.inputMode = inputNone
+			if k.String() != "enter" {
+				return m, nil
+			}
+			db, err := store.OpenWith(m.ctx, m.opts.Profiles[m.profilePending], func(string) (string, error) { return passphrase, nil })
+			return m.openProfile(m.profilePending, db, err)
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// openProfile switches to the database of profile name, just opened.
+func (m *model) openProfile(name string, db *sql.DB, err error) (tea.Model, tea.Cmd) {
+	if err != nil {
+		m.err = "Profile " + name + ": " + err.Error()
+		return m, nil
+	}
+	if m.watcher != nil {
+		m.watcher.Close()
+	}
+	closeErr := store.Close(m.db)
+	opts := m.opts
+	opts.Profile = name
+	opts.Workspace, opts.Project, opts.Tags = "", "", nil
+	width, height := m.width, m.height
+	*m = *New(m.ctx, db, opts)
+	m.width, m.height = width, height
+	m.statusMsg = "Switched to profile " + name
+	if closeErr != nil {
+		m.err = closeErr.Error()
+	}
+	m.refreshTimer()
+	return m, m.refreshList()
+}
+
+func (m *model) viewProfilePicker() string {
+	s := titleStyle.Render("Todo") + "\n\nSwitch profile:\n\n"
+	for i, name := range m.profileNames {
+		cursor := "  "
+		if i == m.profileCursor {
+			cursor = "> "
+		}
+		current := ""
+		if name == m.opts.Profile {
+			current = " (current)"
+		}
+		s += cursor + name + current + "  " + helpStyle.UnsetMarginTop().Render(m.opts.Profiles[name]) + "\n"
+	}
+	return s + "\n" + helpStyle.Render("↑/↓ choose • Enter switch • Esc cancel")
+}
@@ -0,0 +1,155 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cli-todo/internal/models"
+	"github.com/cli-todo/internal/store"
+)
+
+const searchLimit = 15
+
+var searchMatchStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
+
+// handleSearchOpen opens the global search overlay (all workspaces).
+func (m *model) handleSearchOpen() (tea.Model, tea.Cmd) {
+	m.inputMode = inputSearch
+	m.searchHits = nil
+	m.searchCursor = 0
+	m.input.SetValue("")
+	m.input.Placeholder = "Search titles and descriptions..."
+	m.input.Focus()
+	return m, textinput.Blink
+}
+
+// updateSearch handles keys in the search overlay; results follow the input as you type.
+func (m *model) updateSearch(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		switch k.String() {
+		case "esc", "ctrl+c":
+			m.closeSearch()
+			return m, nil
+		case "up", "ctrl+p":
+			if m.searchCursor > 0 {
+				m.searchCursor--
+			}
+			return m, nil
+		case "down", "ctrl+n":
+			if m.searchCursor < len(m.searchHits)-1 {
+				m.searchCursor++
+			}
+			return m, nil
+		case "enter":
+			if m.searchCursor >= len(m.searchHits) {
+				return m, nil
+			}
+			hit := m.searchHits[m.searchCursor]
+			m.closeSearch()
+			return m.jumpToTask(hit.Task)
+		}
+	}
+	prev := m.input.Value()
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() != prev {
+		m.runSearch()
+	}
+	return m, cmd
+}
+
+func (m *model) runSearch() {
+	m.searchCursor = 0
+	query := store.SearchQuery(m.input.Value())
+	if query == "" {
+		m.searchHits = nil
+		return
+	}
+	hits, err := store.SearchTasks(m.ctx, m.db, query, nil, nil, searchLimit)
+	if err != nil {
+		m.err = err.Error()
+		return
+	}
+	m.err = ""
+	m.searchHits = hits
+}
+
+func (m *model) closeSearch() {
+	m.inputMode = inputNone
+	m.input.SetValue("")
+	m.input.Placeholder = "Name..."
+	m.searchHits = nil
+}
+
+// jumpToTask opens the task's workspace and list and selects the task.
+func (m *model) jumpToTask(t models.Task) (tea.Model, tea.Cmd) {
+	w, err := store.GetWorkspace(m.ctx, m.db, t.WorkspaceID)
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	m.moveTaskID = 0
+	m.screen = screenWorkspaces
+	m.refreshList()
+	for i := range m.workspaces {
+		if m.workspaces[i].ID == w.ID {
+			m.workspaceCursor = i
+		}
+	}
+	m.selectedWorkspace = &w
+	m.screen = screenProjects
+	m.refreshList()
+	m.selectedProjectID = t.ProjectID
+	m.screen = screenTasks
+	m.refreshList()
+	m.list.ResetFilter()
+	for i, item := range m.list.Items() {
+		if ti, ok := item.(taskItem); ok && ti.ID == t.ID {
+			m.list.Select(i)
+			break
+		}
+	}
+	return m, nil
+}
+
+func (m *model) viewSearch() string {
+	s := titleStyle.Render("Todo") + "\n\n" + "Search: " + m.input.View() + "\n\n"
+	if len(m.searchHits) == 0 && strings.TrimSpace(m.input.Value()) != "" {
+		s += helpStyle.Render("  No matches.") + "\n"
+	}
+	hl := func(text string) string {
+		var b strings.Builder
+		for {
+			i := strings.Index(text, store.HighlightStart)
+			if i < 0 {
+				break
+			}
+			j := strings.Index(text[i:], store.HighlightEnd)
+			if j < 0 {
+				break
+			}
+			b.WriteString(text[:i])
+			b.WriteString(searchMatchStyle.Render(text[i+len(store.HighlightStart) : i+j]))
+			text = text[i+j+len(store.HighlightEnd):]
+		}
+		b.WriteString(text)
+		return b.String()
+	}
+	for i, h := range m.searchHits {
+		cursor := "  "
+		if i == m.searchCursor {
+			cursor = "> "
+		}
+		list := h.ProjectName
+		if list == "" {
+			list = "Default"
+		}
+		s += cursor + hl(h.Title) + "  " + helpStyle.UnsetMarginTop().Render(h.WorkspaceName+" → "+list+" ["+h.Task.Status+"]") + "\n"
+		if h.Snippet != "" {
+			s += "    " + hl(strings.ReplaceAll(h.Snippet, "\n", " ")) + "\n"
+		}
+	}
+	return s + "\n" + helpStyle.Render("↑/↓ choose • Enter go to task • Esc cancel")
+}
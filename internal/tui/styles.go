@@ -1,10 +1,29 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
 
 var (
 	titleStyle  = lipgloss.NewStyle().Bold(true).MarginBottom(1)
 	errorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
 	helpStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).MarginTop(1)
 	statusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	timerStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
 )
+
+// applyTheme adjusts the styles above for the configured theme: dark (the defaults), light (darker
+// colors that stay readable on a white background) or mono (no colors at all).
+func applyTheme(theme string) {
+	switch theme {
+	case "light":
+		errorStyle = errorStyle.Foreground(lipgloss.Color("1"))
+		helpStyle = helpStyle.Foreground(lipgloss.Color("244"))
+		statusStyle = statusStyle.Foreground(lipgloss.Color("2"))
+		timerStyle = timerStyle.Foreground(lipgloss.Color("3"))
+		searchMatchStyle = searchMatchStyle.Foreground(lipgloss.Color("3"))
+	case "mono":
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
@@ -0,0 +1,109 @@
+package tui
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbletea"
+	"github.com/cli-todo/internal/store"
+)
+
+// handleTemplatePickOpen starts the new project flow on the projects screen: with stored templates,
+// pick a template (or a blank project) first; otherwise ask for the name right away.
+func (m *model) handleTemplatePickOpen() (tea.Model, tea.Cmd) {
+	templates, err := store.ListTemplates(m.ctx, m.db)
+	if err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	m.clearTemplateDraft()
+	m.templates = templates
+	m.input.SetValue("")
+	m.input.Focus()
+	if len(templates) == 0 {
+		m.inputMode = inputNewProject
+		return m, textinput.Blink
+	}
+	m.inputMode = inputPickTemplate
+	return m, nil
+}
+
+// updateTemplatePicker handles keys in the template picker; entry 0 is a blank project.
+func (m *model) updateTemplatePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch k.String() {
+	case "esc", "ctrl+c":
+		m.inputMode = inputNone
+		m.clearTemplateDraft()
+	case "up", "k":
+		if m.templateCursor > 0 {
+			m.templateCursor--
+		}
+	case "down", "j":
+		if m.templateCursor < len(m.templates) {
+			m.templateCursor++
+		}
+	case "enter":
+		if m.templateCursor > 0 {
+			tpl := m.templates[m.templateCursor-1]
+			m.pendingTemplate = &tpl
+		}
+		m.inputMode = inputNewProject
+		return m, textinput.Blink
+	}
+	return m, nil
+}
+
+// submitTemplateProject continues the template flow after the project name and each variable,
+// asking for the next missing variable or creating the project once all are known.
+func (m *model) submitTemplateProject() (*model, tea.Cmd) {
+	if len(m.templateVarQueue) > 0 {
+		m.inputMode = inputTemplateVar
+		m.input.Placeholder = "{{" + m.templateVarQueue[0] + "}}"
+		return m, textinput.Blink
+	}
+	tpl := *m.pendingTemplate
+	name, vars := m.newProjectName, m.templateVarValues
+	m.clearTemplateDraft()
+	if _, err := store.CreateProjectFromTemplate(m.ctx, m.db, m.selectedWorkspace.ID, name, tpl, vars, time.Now()); err != nil {
+		m.err = err.Error()
+		return m, nil
+	}
+	m.err = ""
+	m.statusMsg = "Created " + name + " from template " + tpl.Name
+	return m, m.refreshList()
+}
+
+func (m *model) clearTemplateDraft() {
+	m.templates = nil
+	m.templateCursor = 0
+	m.pendingTemplate = nil
+	m.newProjectName = ""
+	m.templateVarQueue = nil
+	m.templateVarValues = nil
+	m.input.Placeholder = "Name..."
+}
+
+func (m *model) viewTemplatePicker() string {
+	s := titleStyle.Render("Todo") + "\n\nNew project/list:\n\n"
+	for i := 0; i <= len(m.templates); i++ {
+		cursor := "  "
+		if i == m.templateCursor {
+			cursor = "> "
+		}
+		if i == 0 {
+			s += cursor + "Blank project\n"
+			continue
+		}
+		tpl := m.templates[i-1]
+		detail := tpl.Description
+		if detail == "" {
+			detail = "template"
+		}
+		s += cursor + tpl.Name + "  " + helpStyle.UnsetMarginTop().Render(detail) + "\n"
+	}
+	return s + "\n" + helpStyle.Render("↑/↓ choose • Enter next • Esc cancel")
+}
@@ -3,8 +3,11 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/cli-todo/internal/quickadd"
+	"github.com/cli-todo/internal/store"
 )
 
 // namedColors maps common names to hex for lipgloss (which expects hex with # or ANSI numbers).
@@ -42,21 +45,48 @@ func isHex(s string) bool {
 	return true
 }
 
+// formatElapsed renders seconds as H:MM:SS.
+func formatElapsed(s int64) string {
+	s = max(0, s)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s%3600/60, s%60)
+}
+
 func (m *model) viewInput() string {
+	if m.inputMode == inputSearch {
+		return m.viewSearch()
+	}
+	if m.inputMode == inputTaskNote {
+		return m.viewNote()
+	}
+	if m.inputMode == inputPickProfile {
+		return m.viewProfilePicker()
+	}
+	if m.inputMode == inputPickTemplate {
+		return m.viewTemplatePicker()
+	}
 	prompt := "Name: "
 	switch m.inputMode {
 	case inputNewWorkspace:
 		prompt = "New workspace name: "
 	case inputNewProject:
 		prompt = "New project/list name: "
+		if m.pendingTemplate != nil {
+			prompt = "New project from " + m.pendingTemplate.Name + ", name: "
+		}
+	case inputTemplateVar:
+		if len(m.templateVarQueue) > 0 {
+			prompt = m.pendingTemplate.Name + " needs " + m.templateVarQueue[0] + ": "
+		}
 	case inputNewTask:
 		prompt = "New task title: "
 	case inputNewTaskDue:
-		prompt = "Due date (YYYY-MM-DD, optional): "
+		prompt = "Due date (YYYY-MM-DD, tomorrow, fri, +3d; optional): "
 	case inputNewTaskPriority:
 		prompt = "Priority (low/medium/high, optional): "
+	case inputNewTaskEstimate:
+		prompt = "Estimate (e.g. 1h30m or 3pt, optional): "
 	case inputNewTaskStatus:
-		prompt = "Status (todo/in_progress/done, optional): "
+		prompt = "Status (" + strings.ReplaceAll(m.statusChoices(), " / ", "/") + ", optional): "
 	case inputEditWorkspace:
 		prompt = "Edit workspace name: "
 	case inputEditProject:
@@ -69,12 +99,59 @@ func (m *model) viewInput() string {
 		prompt = "Workspace color (e.g. green, blue, #ff0000; empty to clear): "
 	case inputProjectColor:
 		prompt = "Project color (e.g. green, blue, #ff0000; empty to clear): "
+	case inputTaskEstimate:
+		prompt = "Estimate (e.g. 1h30m or 3pt; empty to clear): "
+	case inputProfilePassphrase:
+		prompt = "Passphrase for profile " + m.profilePending + ": "
 	}
 	help := "Press Enter to save • Esc to cancel"
-	if m.inputMode == inputNewTask || m.inputMode == inputNewTaskDue || m.inputMode == inputNewTaskPriority || m.inputMode == inputNewTaskStatus {
+	if m.inputMode == inputNewTask || m.inputMode == inputNewTaskDue || m.inputMode == inputNewTaskPriority || m.inputMode == inputNewTaskEstimate || m.inputMode == inputNewTaskStatus {
 		help = "Enter = create or next • Tab = next field • Esc = cancel"
 	}
-	return titleStyle.Render("Todo") + "\n\n" + prompt + m.input.View() + "\n\n" + helpStyle.Render(help)
+	if m.inputMode == inputProfilePassphrase {
+		help = "Press Enter to unlock • Esc to cancel"
+	}
+	preview := ""
+	if m.inputMode == inputNewTask {
+		preview = "\n" + m.quickAddPreview()
+	}
+	if m.err != "" {
+		preview += "\n" + errorStyle.Render("Error: "+m.err)
+	}
+	return titleStyle.Render("Todo") + "\n\n" + prompt + m.input.View() + preview + "\n\n" + helpStyle.Render(help)
+}
+
+// quickAddPreview shows how the new task line will be read: title, list, tags, priority, due, estimate.
+func (m *model) quickAddPreview() string {
+	line := strings.TrimSpace(m.input.Value())
+	if line == "" {
+		return helpStyle.UnsetMarginTop().Render("  #list @tag !high ^tomorrow ~30m")
+	}
+	qa, err := quickadd.Parse(line, time.Now())
+	if err != nil {
+		return errorStyle.Render("  " + err.Error())
+	}
+	parts := []string{qa.Title}
+	if qa.Project != "" {
+		if p, ok := quickadd.MatchProject(qa.Project, m.projects); ok {
+			parts = append(parts, "list: "+p.Name)
+		} else {
+			return errorStyle.Render("  no list matches #" + qa.Project)
+		}
+	}
+	for _, tag := range qa.Tags {
+		parts = append(parts, "@"+tag)
+	}
+	if qa.Priority != "" {
+		parts = append(parts, string(qa.Priority))
+	}
+	if qa.Due != nil {
+		parts = append(parts, "due "+qa.Due.Format("Mon 2006-01-02"))
+	}
+	if qa.Estimate != "" {
+		parts = append(parts, "~"+qa.Estimate)
+	}
+	return statusStyle.Render("  → " + strings.Join(parts, " • "))
 }
 
 func (m *model) viewContent() string {
@@ -123,31 +200,130 @@ func (m *model) viewContent() string {
 					name = lipgloss.NewStyle().Foreground(lipgloss.Color(c)).Render(p.Name)
 				}
 			}
+			if p.Capacity != "" {
+				name += "  " + helpStyle.UnsetMarginTop().Render(p.Capacity)
+			}
 			s += cursor + name + "\n"
 		}
+	} else if m.screen == screenTaskDetail {
+		s += m.viewTaskDetail()
 	} else {
 		s += m.list.View()
+		if m.viewCapacity != "" {
+			s += "\n" + helpStyle.Render("Remaining: "+m.viewCapacity)
+		}
 	}
 	return s
 }
 
-func (m *model) viewFooter() string {
-	help := "↑/↓ move • Enter open/select • a add • e edit • c color • d delete • ← back • q quit"
-	if m.screen == screenProjects {
-		if m.moveTaskID != 0 {
-			help = "↑/↓ move • Enter move here • ← cancel"
-		} else {
-			help = "↑/↓ move • Enter open/select • a add • e edit • c color • d delete • ← back • q quit"
+// viewTaskDetail renders the fields of the detail task and its dependencies.
+func (m *model) viewTaskDetail() string {
+	t := m.detailTask
+	s := titleStyle.Render(" "+t.Title+" ") + "\n\n"
+	list := "Default"
+	if t.ProjectID != nil {
+		for _, p := range m.projects {
+			if p.ID == *t.ProjectID {
+				list = p.Name
+				break
+			}
+		}
+	}
+	s += fmt.Sprintf("  ID:        %d\n", t.ID)
+	s += "  UUID:      " + t.UUID + "\n"
+	s += "  List:      " + list + "\n"
+	s += "  Status:    " + t.Status + "\n"
+	if t.Priority != "" {
+		s += "  Priority:  " + string(t.Priority) + "\n"
+	}
+	if t.DueDate != nil {
+		s += "  Due:       " + t.DueDate.Format(dateLayout) + "\n"
+	}
+	if est := store.FormatEstimate(t); est != "" {
+		s += "  Estimate:  " + est + "\n"
+	}
+	if m.detailLogged > 0 {
+		s += "  Logged:    " + formatElapsed(m.detailLogged) + "\n"
+	}
+	for _, f := range m.detailFields {
+		if v, ok := t.Fields[f.Name]; ok {
+			s += fmt.Sprintf("  %-11s%s\n", f.Name+":", store.FormatFieldValue(v))
 		}
 	}
+	if t.Description != "" {
+		s += "\n  " + strings.ReplaceAll(t.Description, "\n", "\n  ") + "\n"
+	}
+	if len(m.detailBlockers) > 0 {
+		label := "Blocked by"
+		if !t.Blocked {
+			label = "Depends on (all done)"
+		}
+		s += "\n" + titleStyle.Render("  "+label) + "\n"
+		for _, b := range m.detailBlockers {
+			s += fmt.Sprintf("  ⊘ %d  [%s]  %s\n", b.ID, b.Status, b.Title)
+		}
+	}
+	if len(m.detailBlocks) > 0 {
+		s += "\n" + titleStyle.Render("  Blocks") + "\n"
+		for _, b := range m.detailBlocks {
+			s += fmt.Sprintf("  → %d  [%s]  %s\n", b.ID, b.Status, b.Title)
+		}
+	}
+	if len(m.detailAttachments) > 0 {
+		s += "\n" + titleStyle.Render("  Attachments") + "\n"
+		for i, a := range m.detailAttachments {
+			cursor := "  "
+			if i == m.attachmentCursor {
+				cursor = "> "
+			}
+			detail := a.Target
+			if a.Kind == store.AttachBlob || a.Kind == store.AttachFile {
+				detail = store.FormatBytes(a.Size)
+			}
+			if detail == a.Name {
+				detail = ""
+			}
+			s += cursor + a.Name + "  " + helpStyle.UnsetMarginTop().Render(strings.TrimSpace("["+a.Kind+"] "+detail)) + "\n"
+		}
+	}
+	if len(m.detailNotes) > 0 {
+		s += "\n" + titleStyle.Render("  Notes") + "\n"
+		for _, n := range m.detailNotes {
+			s += "  " + helpStyle.UnsetMarginTop().Render(n.CreatedAt.Local().Format(dateLayout+" 15:04")) + "\n"
+			s += "    " + strings.ReplaceAll(n.Body, "\n", "\n    ") + "\n"
+		}
+	}
+	return s
+}
+
+func (m *model) viewFooter() string {
+	k := m.keys
+	help := "↑/↓ move • Enter open/select • " + k.Add + " add • " + k.Edit + " edit • " + k.Color + " color • " + k.Delete + " delete • " + k.Search + " search • ← back • " + k.Quit + " quit"
+	if m.screen == screenWorkspaces && len(m.opts.Profiles) > 0 {
+		help += " • " + k.Profile + " profile"
+	}
+	if m.screen == screenProjects && m.moveTaskID != 0 {
+		help = "↑/↓ move • Enter move here • ← cancel"
+	}
 	if m.screen == screenTasks {
-		help = "↑/↓ move • Enter select • a add • e edit • s status • p priority • u due date • m move • d delete • ← back • q quit"
+		help = "↑/↓ move • Enter details • " + k.Add + " add • " + k.Edit + " edit • " + k.Status + " status • " + k.Priority + " priority • " + k.Due + " due date • " +
+			k.Move + " move • " + k.Timer + " timer • " + k.Estimate + " estimate • " + k.Delete + " delete • " + k.Search + " search • ← back • " + k.Quit + " quit"
+	}
+	if m.screen == screenTaskDetail {
+		help = k.Note + " add note • ↑/↓ choose attachment • " + k.Open + " open • ← back • " + k.Quit + " quit"
 	}
 	s := helpStyle.Render(help)
 	if m.statusMsg != "" {
 		s += "\n" + statusStyle.Render(m.statusMsg)
 	}
+	if m.runningTimer != nil {
+		elapsed := int64(time.Since(m.runningTimer.StartedAt).Seconds())
+		s += "\n" + timerStyle.Render("⏱ "+m.runningTaskTitle+"  "+formatElapsed(elapsed))
+	}
 	dbLine := "DB: " + m.dbPath
+	if m.opts.Profile != "" {
+		dbLine = "Profile: " + m.opts.Profile
+	}
 	if m.screen == screenWorkspaces {
 		dbLine += "  •  Workspaces: " + fmt.Sprintf("%d", len(m.workspaces))
 	}